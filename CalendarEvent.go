@@ -106,22 +106,29 @@ func (c *CalendarEvent) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("error on json.Unmarshal: %v | Data: %v", err, string(data))
 	}
 
+	// Fields may be missing, e.g. if they are not part of the API version or not $selected,
+	// hence only present values are parsed. Missing original timezones default to UTC.
 	c.ID = tmp.ID
 	c.CreatedDateTime, err = time.Parse(time.RFC3339Nano, tmp.CreatedDateTime)
-	if err != nil {
+	if err != nil && tmp.CreatedDateTime != "" {
 		return fmt.Errorf("cannot time.Parse with RFC3339Nano createdDateTime %v: %v", tmp.CreatedDateTime, err)
 	}
 	c.LastModifiedDateTime, err = time.Parse(time.RFC3339Nano, tmp.LastModifiedDateTime)
-	if err != nil {
+	if err != nil && tmp.LastModifiedDateTime != "" {
 		return fmt.Errorf("cannot time.Parse with RFC3339Nano lastModifiedDateTime %v: %v", tmp.LastModifiedDateTime, err)
 	}
-	c.OriginalStartTimeZone, err = mapTimeZoneStrings(tmp.OriginalStartTimeZone)
-	if err != nil {
-		return fmt.Errorf("cannot time.LoadLocation originalStartTimeZone %v: %v", tmp.OriginalStartTimeZone, err)
+	c.OriginalStartTimeZone, c.OriginalEndTimeZone = time.UTC, time.UTC
+	if tmp.OriginalStartTimeZone != "" {
+		c.OriginalStartTimeZone, err = mapTimeZoneStrings(tmp.OriginalStartTimeZone)
+		if err != nil {
+			return fmt.Errorf("cannot time.LoadLocation originalStartTimeZone %v: %v", tmp.OriginalStartTimeZone, err)
+		}
 	}
-	c.OriginalEndTimeZone, err = mapTimeZoneStrings(tmp.OriginalEndTimeZone)
-	if err != nil {
-		return fmt.Errorf("cannot time.LoadLocation originalEndTimeZone %v: %v", tmp.OriginalEndTimeZone, err)
+	if tmp.OriginalEndTimeZone != "" {
+		c.OriginalEndTimeZone, err = mapTimeZoneStrings(tmp.OriginalEndTimeZone)
+		if err != nil {
+			return fmt.Errorf("cannot time.LoadLocation originalEndTimeZone %v: %v", tmp.OriginalEndTimeZone, err)
+		}
 	}
 	c.ICalUID = tmp.ICalUID
 	c.Subject = tmp.Subject
@@ -139,13 +146,17 @@ func (c *CalendarEvent) UnmarshalJSON(data []byte) error {
 	c.OrganizerEMail = tmp.Organizer.EmailAddress.Address
 
 	// Parse event start & endtime with timezone
-	c.StartTime, err = parseTimeAndLocation(tmp.Start["dateTime"], tmp.Start["timeZone"]) // the timeZone is normally ALWAYS UTC, microsoft converts time date & time to that
-	if err != nil {
-		return fmt.Errorf("cannot parse start-dateTime %v AND timeZone %v: %v", tmp.Start["dateTime"], tmp.Start["timeZone"], err)
+	if tmp.Start != nil {
+		c.StartTime, err = parseTimeAndLocation(tmp.Start["dateTime"], tmp.Start["timeZone"]) // the timeZone is normally ALWAYS UTC, microsoft converts time date & time to that
+		if err != nil {
+			return fmt.Errorf("cannot parse start-dateTime %v AND timeZone %v: %v", tmp.Start["dateTime"], tmp.Start["timeZone"], err)
+		}
 	}
-	c.EndTime, err = parseTimeAndLocation(tmp.End["dateTime"], tmp.End["timeZone"]) // the timeZone is normally ALWAYS UTC, microsoft converts time date & time to that
-	if err != nil {
-		return fmt.Errorf("cannot parse end-dateTime %v AND timeZone %v: %v", tmp.End["dateTime"], tmp.End["timeZone"], err)
+	if tmp.End != nil {
+		c.EndTime, err = parseTimeAndLocation(tmp.End["dateTime"], tmp.End["timeZone"]) // the timeZone is normally ALWAYS UTC, microsoft converts time date & time to that
+		if err != nil {
+			return fmt.Errorf("cannot parse end-dateTime %v AND timeZone %v: %v", tmp.End["dateTime"], tmp.End["timeZone"], err)
+		}
 	}
 
 	// Hint: OriginalStartTimeZone & end are UTC (set by microsoft) if it is a full-day event, this will be handled in the next section
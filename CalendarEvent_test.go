@@ -0,0 +1,40 @@
+package msgraph
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCalendarEvent_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "all fields",
+			data: `{"id":"event-1","createdDateTime":"2021-01-02T03:04:05.1234567Z","lastModifiedDateTime":"2021-01-02T03:04:05Z",` +
+				`"originalStartTimeZone":"tzone://Microsoft/Custom","originalEndTimeZone":"tzone://Microsoft/Custom","subject":"Meeting","responseStatus":{"response":"organizer","time":"0001-01-01T00:00:00Z"},` +
+				`"start":{"dateTime":"2021-01-03T10:00:00.0000000","timeZone":"UTC"},"end":{"dateTime":"2021-01-03T11:00:00.0000000","timeZone":"UTC"}}`,
+		}, {
+			name: "only selected fields",
+			data: `{"id":"event-1","subject":"Meeting","responseStatus":null}`,
+		}, {
+			name:    "invalid createdDateTime",
+			data:    `{"id":"event-1","createdDateTime":"yesterday"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var event CalendarEvent
+			err := json.Unmarshal([]byte(tt.data), &event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CalendarEvent.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (event.ID != "event-1" || event.Subject != "Meeting" || event.OriginalStartTimeZone == nil || event.StartTime.After(event.EndTime)) {
+				t.Errorf("CalendarEvent.UnmarshalJSON() = %v", event)
+			}
+		})
+	}
+}
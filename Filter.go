@@ -0,0 +1,250 @@
+package msgraph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is an OData $filter expression, built with e.g. Eq, StartsWith, Any and And, whose
+// literals are escaped correctly. Pass it to ListWithFilter with Filter.String:
+//
+//	filter := msgraph.And(msgraph.StartsWith("displayName", "O'Brien"), msgraph.Eq("accountEnabled", true))
+//	users, err := graphClient.ListUsers(msgraph.ListWithFilter(filter.String()))
+//
+// The zero Filter is empty and ignored by And and Or, hence Filters can be built conditionally.
+//
+// See https://docs.microsoft.com/en-us/graph/query-parameters#filter-parameter
+type Filter struct {
+	expr string
+	or   bool // the expression is a disjunction, hence must be parenthesized within a conjunction
+}
+
+// String returns the OData representation of the Filter, e.g. "startswith(displayName,'Bob')"
+func (f Filter) String() string {
+	return f.expr
+}
+
+// IsEmpty returns true if the Filter does not contain an expression
+func (f Filter) IsEmpty() bool {
+	return f.expr == ""
+}
+
+// GUID is a literal of the OData type Edm.Guid, which is not quoted unlike strings, e.g. for the
+// skuId of assignedLicenses. IDs of directory objects are strings and must not be passed as GUID.
+type GUID string
+
+// Eq returns the Filter "property eq value". See FilterLiteral for the supported types of value.
+func Eq(property string, value interface{}) Filter {
+	return compare(property, "eq", value)
+}
+
+// Ne returns the Filter "property ne value". Requires advanced query capabilities for directory
+// objects, hence ListWithCount and the ConsistencyLevel header "eventual".
+func Ne(property string, value interface{}) Filter {
+	return compare(property, "ne", value)
+}
+
+// Gt returns the Filter "property gt value"
+func Gt(property string, value interface{}) Filter {
+	return compare(property, "gt", value)
+}
+
+// Ge returns the Filter "property ge value"
+func Ge(property string, value interface{}) Filter {
+	return compare(property, "ge", value)
+}
+
+// Lt returns the Filter "property lt value"
+func Lt(property string, value interface{}) Filter {
+	return compare(property, "lt", value)
+}
+
+// Le returns the Filter "property le value"
+func Le(property string, value interface{}) Filter {
+	return compare(property, "le", value)
+}
+
+func compare(property, operator string, value interface{}) Filter {
+	return Filter{expr: property + " " + operator + " " + FilterLiteral(value)}
+}
+
+// StartsWith returns the Filter "startswith(property,'prefix')"
+func StartsWith(property, prefix string) Filter {
+	return Filter{expr: "startswith(" + property + "," + FilterLiteral(prefix) + ")"}
+}
+
+// EndsWith returns the Filter "endswith(property,'suffix')". Requires advanced query capabilities
+// for directory objects, hence ListWithCount and the ConsistencyLevel header "eventual".
+func EndsWith(property, suffix string) Filter {
+	return Filter{expr: "endswith(" + property + "," + FilterLiteral(suffix) + ")"}
+}
+
+// In returns the Filter "property in (value1,value2,...)", hence the property equals one of the
+// values. Without values no item matches, hence the Filter is the literal "false", which is kept by
+// And and Or unlike an empty Filter.
+func In(property string, values ...interface{}) Filter {
+	if len(values) == 0 {
+		return Filter{expr: "false"}
+	}
+	var literals = make([]string, len(values))
+	for idx, value := range values {
+		literals[idx] = FilterLiteral(value)
+	}
+	return Filter{expr: property + " in (" + strings.Join(literals, ",") + ")"}
+}
+
+// Any returns the lambda Filter "collection/any(variable:condition)", hence at least one item of
+// the collection matches the condition, which refers to the items with the given variable:
+//
+//	msgraph.Any("assignedLicenses", "l", msgraph.Eq("l/skuId", msgraph.GUID(skuID)))
+//	msgraph.Any("proxyAddresses", "a", msgraph.StartsWith("a", "smtp:"))
+//
+// An empty condition returns "collection/any()", hence the collection is not empty.
+func Any(collection, variable string, condition Filter) Filter {
+	return lambda(collection, "any", variable, condition)
+}
+
+// All returns the lambda Filter "collection/all(variable:condition)", hence all items of the
+// collection match the condition, see Any.
+func All(collection, variable string, condition Filter) Filter {
+	return lambda(collection, "all", variable, condition)
+}
+
+func lambda(collection, operator, variable string, condition Filter) Filter {
+	if condition.IsEmpty() {
+		return Filter{expr: collection + "/" + operator + "()"}
+	}
+	return Filter{expr: collection + "/" + operator + "(" + variable + ":" + condition.expr + ")"}
+}
+
+// And returns the conjunction of the given Filters, empty Filters are ignored. Disjunctions are
+// parenthesized, e.g. "accountEnabled eq true and (city eq 'Vienna' or city eq 'Graz')".
+func And(filters ...Filter) Filter {
+	var exprs []string
+	for _, f := range filters {
+		switch {
+		case f.IsEmpty():
+		case f.or:
+			exprs = append(exprs, "("+f.expr+")")
+		default:
+			exprs = append(exprs, f.expr)
+		}
+	}
+	return Filter{expr: strings.Join(exprs, " and ")}
+}
+
+// Or returns the disjunction of the given Filters, empty Filters are ignored. A single remaining
+// Filter is returned unchanged, hence a nested disjunction is still parenthesized by And.
+func Or(filters ...Filter) Filter {
+	var nonEmpty []Filter
+	var exprs []string
+	for _, f := range filters {
+		if !f.IsEmpty() {
+			nonEmpty = append(nonEmpty, f)
+			exprs = append(exprs, f.expr)
+		}
+	}
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+	return Filter{expr: strings.Join(exprs, " or "), or: len(exprs) > 1}
+}
+
+// Not returns the negation "not (filter)" of the given Filter. Requires advanced query
+// capabilities for directory objects, hence ListWithCount and the ConsistencyLevel header "eventual".
+func Not(f Filter) Filter {
+	if f.IsEmpty() {
+		return f
+	}
+	return Filter{expr: "not (" + f.expr + ")"}
+}
+
+// FilterLiteral returns the OData literal of value as used within a Filter:
+//
+//   - strings are quoted with single quotes, single quotes within the string are doubled: 'Bob'
+//   - bools, integers and floats are unquoted: true, 42, 1.5
+//   - time.Time is converted to UTC and formatted as DateTimeOffset: 2021-01-02T03:04:05Z
+//   - GUID is unquoted: 00000000-0000-0000-0000-000000000000
+//   - nil is null
+//
+// All other types are formatted with fmt.Sprint and quoted like strings.
+func FilterLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case GUID:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return FilterLiteral(fmt.Sprint(v))
+	}
+}
+
+// Search is a $search expression of clauses like "displayName:alice", built with SearchTerm,
+// SearchAnd and SearchOr. Pass it to ListWithSearch with Search.String:
+//
+//	search := msgraph.SearchOr(msgraph.SearchTerm("displayName", "alice"), msgraph.SearchTerm("mail", "alice"))
+//	users, err := graphClient.ListUsers(msgraph.ListWithSearch(search.String()))
+//
+// See https://docs.microsoft.com/en-us/graph/search-query-parameter
+type Search struct {
+	expr string
+	or   bool // the expression is a disjunction, hence must be parenthesized within a conjunction
+}
+
+// String returns the $search representation, e.g. "\"displayName:alice\" OR \"mail:alice\""
+func (s Search) String() string {
+	return s.expr
+}
+
+// SearchTerm returns the clause "property:value", hence the tokenized property contains a word
+// starting with value. Double quotes and backslashes within value are escaped.
+func SearchTerm(property, value string) Search {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return Search{expr: `"` + property + ":" + escaped + `"`}
+}
+
+// SearchAnd returns the conjunction of the given Search expressions, empty ones are ignored
+func SearchAnd(searches ...Search) Search {
+	var exprs []string
+	for _, s := range searches {
+		switch {
+		case s.expr == "":
+		case s.or:
+			exprs = append(exprs, "("+s.expr+")")
+		default:
+			exprs = append(exprs, s.expr)
+		}
+	}
+	return Search{expr: strings.Join(exprs, " AND ")}
+}
+
+// SearchOr returns the disjunction of the given Search expressions, empty ones are ignored. A
+// single remaining expression is returned unchanged, see Or.
+func SearchOr(searches ...Search) Search {
+	var nonEmpty []Search
+	var exprs []string
+	for _, s := range searches {
+		if s.expr != "" {
+			nonEmpty = append(nonEmpty, s)
+			exprs = append(exprs, s.expr)
+		}
+	}
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+	return Search{expr: strings.Join(exprs, " OR "), or: len(exprs) > 1}
+}
@@ -0,0 +1,74 @@
+package msgraph
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFilter_String(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"apostrophe", Eq("surname", "O'Brien"), "surname eq 'O''Brien'"},
+		{"bool", Ne("accountEnabled", false), "accountEnabled ne false"},
+		{"numbers", And(Gt("a", 1), Ge("b", int64(-2)), Lt("c", 1.5), Le("d", float32(0.25))), "a gt 1 and b ge -2 and c lt 1.5 and d le 0.25"},
+		{"null", Eq("manager", nil), "manager eq null"},
+		{"date-time", Ge("createdDateTime", time.Date(2021, 1, 2, 4, 4, 5, 0, time.FixedZone("CET", 3600))), "createdDateTime ge 2021-01-02T03:04:05Z"},
+		{"starts with", StartsWith("displayName", "x'y"), "startswith(displayName,'x''y')"},
+		{"ends with", EndsWith("mail", "@contoso.com"), "endswith(mail,'@contoso.com')"},
+		{"in", In("department", "Sales", "R&D"), "department in ('Sales','R&D')"},
+		{"in without values", And(Eq("accountEnabled", true), In("department")), "accountEnabled eq true and false"},
+		{"any GUID", Any("assignedLicenses", "l", Eq("l/skuId", GUID("b05e124f-c7cc-45a0-a6aa-8cf78c946968"))),
+			"assignedLicenses/any(l:l/skuId eq b05e124f-c7cc-45a0-a6aa-8cf78c946968)"},
+		{"any not empty", Any("assignedLicenses", "", Filter{}), "assignedLicenses/any()"},
+		{"all", All("proxyAddresses", "a", StartsWith("a", "smtp:")), "proxyAddresses/all(a:startswith(a,'smtp:'))"},
+		{"and with or", And(Eq("accountEnabled", true), Or(Eq("city", "Vienna"), Eq("city", "Graz")), Filter{}),
+			"accountEnabled eq true and (city eq 'Vienna' or city eq 'Graz')"},
+		{"or with single filter", And(Eq("a", 1), Or(Filter{}, Eq("b", 2))), "a eq 1 and b eq 2"},
+		{"or with single nested or", And(Eq("x", 1), Or(Filter{}, Or(Eq("a", 1), Eq("b", 2)))), "x eq 1 and (a eq 1 or b eq 2)"},
+		{"not", Not(Or(Eq("a", 1), Eq("b", 2))), "not (a eq 1 or b eq 2)"},
+		{"empty", And(Filter{}, Not(Filter{})), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("Filter.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch_String(t *testing.T) {
+	search := SearchAnd(
+		SearchOr(SearchTerm("displayName", `al"ice`), SearchTerm("mail", `a\b`)),
+		SearchTerm("department", "Sales"),
+		Search{},
+	)
+	if want := `("displayName:al\"ice" OR "mail:a\\b") AND "department:Sales"`; search.String() != want {
+		t.Errorf("Search.String() = %v, want %v", search.String(), want)
+	}
+
+	// a single nested disjunction is still parenthesized
+	search = SearchAnd(SearchTerm("department", "Sales"), SearchOr(Search{}, SearchOr(SearchTerm("displayName", "alice"), SearchTerm("mail", "alice"))))
+	if want := `"department:Sales" AND ("displayName:alice" OR "mail:alice")`; search.String() != want {
+		t.Errorf("Search.String() = %v, want %v", search.String(), want)
+	}
+}
+
+func TestListWithFilter_Filter(t *testing.T) {
+	var query string
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("$filter")
+		w.Write([]byte(`{"value":[]}`))
+	}))
+	filter := And(StartsWith("displayName", "O'Brien & Sons"), Eq("accountEnabled", true))
+	if _, err := g.ListUsers(ListWithFilter(filter.String())); err != nil {
+		t.Fatalf("GraphClient.ListUsers() error = %v", err)
+	}
+	if want := "startswith(displayName,'O''Brien & Sons') and accountEnabled eq true"; query != want {
+		t.Errorf("$filter = %v, want %v", query, want)
+	}
+}
@@ -5,22 +5,29 @@ package msgraph
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
 
 const (
-	odataSearchParamKey = "$search"
-	odataFilterParamKey = "$filter"
-	odataSelectParamKey = "$select"
+	odataSearchParamKey  = "$search"
+	odataFilterParamKey  = "$filter"
+	odataSelectParamKey  = "$select"
+	odataOrderByParamKey = "$orderby"
+	odataTopParamKey     = "$top"
+	odataSkipParamKey    = "$skip"
+	odataCountParamKey   = "$count"
+	odataExpandParamKey  = "$expand"
+
+	odataSkipTokenParamKey = "$skiptoken"
 )
 
 // GraphClient represents a msgraph API connection instance.
@@ -28,18 +35,34 @@ const (
 // An instance can also be json-unmarshalled and will immediately be initialized, hence a Token will be
 // grabbed. If grabbing a token fails the JSON-Unmarshal returns an error.
 type GraphClient struct {
-	apiCall sync.Mutex // lock it when performing an API-call to synchronize it
+	tokenMu sync.RWMutex // guards token, API-calls only hold it while reading or refreshing the token
 
 	TenantID      string // See https://docs.microsoft.com/en-us/azure/azure-resource-manager/resource-group-create-service-principal-portal#get-tenant-id
 	ApplicationID string // See https://docs.microsoft.com/en-us/azure/azure-resource-manager/resource-group-create-service-principal-portal#get-application-id-and-authentication-key
 	ClientSecret  string // See https://docs.microsoft.com/en-us/azure/azure-resource-manager/resource-group-create-service-principal-portal#get-application-id-and-authentication-key
 
-	token Token // the current token to be used
+	token         Token         // the current token to be used
+	tokenProvider TokenProvider // acquires the tokens, the client credentials flow with the ClientSecret if not set
+	tokenCache    TokenCache    // caches the tokens if set, see WithTokenCache
+
+	tokenEndpointVersion TokenEndpointVersion // the version of the token endpoint, see WithTokenEndpointVersion
+	scopes               []string             // the scopes requested from TokenEndpointV2, see WithScopes
 
 	// azureADAuthEndpoint is used for this instance of GraphClient. For available endpoints see https://docs.microsoft.com/en-us/azure/active-directory/develop/authentication-national-cloud#azure-ad-authentication-endpoints
 	azureADAuthEndpoint string
 	// serviceRootEndpoint is the basic API-url used for this instance of GraphClient, namely Microsoft Graph service root endpoints. For available endpoints see https://docs.microsoft.com/en-us/graph/deployments#microsoft-graph-and-graph-explorer-service-root-endpoints.
 	serviceRootEndpoint string
+
+	httpClient *http.Client      // the http.Client for all requests if set, see WithHTTPClient
+	transport  http.RoundTripper // the http.RoundTripper of the default http.Clients, see WithRoundTripper
+
+	apiVersion string // the msgraph API version, see WithAPIVersion
+
+	retryPolicy *RetryPolicy  // the RetryPolicy for API-calls, DefaultRetryPolicy if not set
+	concurrency chan struct{} // semaphore limiting the concurrent API-calls if set, see WithMaxConcurrency
+
+	logger    Logger // the Logger for requests and responses, nothing is logged if not set
+	logBodies bool   // log request and response bodies too, see WithBodyLogging
 }
 
 func (g *GraphClient) String() string {
@@ -48,6 +71,8 @@ func (g *GraphClient) String() string {
 		firstPart = g.ClientSecret[0:3]
 		lastPart = g.ClientSecret[len(g.ClientSecret)-3:]
 	}
+	g.tokenMu.RLock()
+	defer g.tokenMu.RUnlock()
 	return fmt.Sprintf("GraphClient(TenantID: %v, ApplicationID: %v, ClientSecret: %v...%v, Token validity: [%v - %v])",
 		g.TenantID, g.ApplicationID, firstPart, lastPart, g.token.NotBefore, g.token.ExpiresOn)
 }
@@ -57,8 +82,10 @@ func (g *GraphClient) String() string {
 // default ms graph API global endpoint is used.
 //
 // This method does not have to be used to create a new GraphClient. If not used, the default global ms Graph API endpoint is used.
-func NewGraphClient(tenantID, applicationID, clientSecret string) (*GraphClient, error) {
-	return NewGraphClientWithCustomEndpoint(tenantID, applicationID, clientSecret, AzureADAuthEndpointGlobal, ServiceRootEndpointGlobal)
+//
+// Optional settings, e.g. a custom http.Client, can be passed as GraphClientOption.
+func NewGraphClient(tenantID, applicationID, clientSecret string, opts ...GraphClientOption) (*GraphClient, error) {
+	return NewGraphClientWithCustomEndpoint(tenantID, applicationID, clientSecret, AzureADAuthEndpointGlobal, ServiceRootEndpointGlobal, opts...)
 }
 
 // NewGraphClientCustomEndpoint creates a new GraphClient instance with the
@@ -71,7 +98,9 @@ func NewGraphClient(tenantID, applicationID, clientSecret string) (*GraphClient,
 //
 // Returns an error if the token cannot be initialized. This func does not have
 // to be used to create a new GraphClient.
-func NewGraphClientWithCustomEndpoint(tenantID, applicationID, clientSecret string, azureADAuthEndpoint string, serviceRootEndpoint string) (*GraphClient, error) {
+//
+// Optional settings, e.g. a custom http.Client, can be passed as GraphClientOption.
+func NewGraphClientWithCustomEndpoint(tenantID, applicationID, clientSecret string, azureADAuthEndpoint string, serviceRootEndpoint string, opts ...GraphClientOption) (*GraphClient, error) {
 	g := GraphClient{
 		TenantID:            tenantID,
 		ApplicationID:       applicationID,
@@ -79,14 +108,20 @@ func NewGraphClientWithCustomEndpoint(tenantID, applicationID, clientSecret stri
 		azureADAuthEndpoint: azureADAuthEndpoint,
 		serviceRootEndpoint: serviceRootEndpoint,
 	}
-	g.apiCall.Lock()         // lock because we will refresh the token
-	defer g.apiCall.Unlock() // unlock after token refresh
-	return &g, g.refreshToken()
+	for idx := range opts {
+		opts[idx](&g)
+	}
+	g.makeSureURLsAreSet()
+	g.tokenMu.Lock()         // lock because we will refresh the token
+	defer g.tokenMu.Unlock() // unlock after token refresh
+	return &g, g.refreshToken(context.Background())
 }
 
 // makeSureURLsAreSet ensures that the two fields g.azureADAuthEndpoint and g.serviceRootEndpoint
 // of the graphClient are set and therefore not empty. If they are currently empty
 // they will be set to the constants AzureADAuthEndpointGlobal and ServiceRootEndpointGlobal.
+// It is called once when the GraphClient is created, API-calls only read the fields, hence they
+// can be performed concurrently.
 func (g *GraphClient) makeSureURLsAreSet() {
 	if g.azureADAuthEndpoint == "" { // If AzureADAuthEndpoint is not set, use the global endpoint
 		g.azureADAuthEndpoint = AzureADAuthEndpointGlobal
@@ -96,41 +131,65 @@ func (g *GraphClient) makeSureURLsAreSet() {
 	}
 }
 
-// refreshToken refreshes the current Token. Grabs a new one and saves it within the GraphClient instance
-func (g *GraphClient) refreshToken() error {
-	g.makeSureURLsAreSet()
-	if g.TenantID == "" {
-		return fmt.Errorf("tenant ID is empty")
+// getToken returns the current Token and refreshes it if necessary. Concurrent API-calls only
+// share a read-lock while the Token is valid. If it wants to be refreshed, the first API-call
+// refreshes it while holding the write-lock and all others wait for and use the new Token.
+func (g *GraphClient) getToken(ctx context.Context) (Token, error) {
+	g.tokenMu.RLock()
+	token := g.token
+	g.tokenMu.RUnlock()
+	if !token.WantsToBeRefreshed() {
+		return token, nil
 	}
-	resource := fmt.Sprintf("/%v/oauth2/token", g.TenantID)
-	data := url.Values{}
-	data.Add("grant_type", "client_credentials")
-	data.Add("client_id", g.ApplicationID)
-	data.Add("client_secret", g.ClientSecret)
-	data.Add("resource", g.serviceRootEndpoint)
 
-	u, err := url.ParseRequestURI(g.azureADAuthEndpoint)
-	if err != nil {
-		return fmt.Errorf("unable to parse URI: %v", err)
+	g.tokenMu.Lock()
+	defer g.tokenMu.Unlock()
+	if !g.token.WantsToBeRefreshed() { // refreshed by another API-call in the meantime
+		return g.token, nil
 	}
-
-	u.Path = resource
-	req, err := http.NewRequest("POST", u.String(), bytes.NewBufferString(data.Encode()))
-
-	if err != nil {
-		return fmt.Errorf("HTTP Request Error: %v", err)
+	if err := g.refreshToken(ctx); err != nil {
+		return Token{}, err
 	}
+	return g.token, nil
+}
 
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+// refreshToken refreshes the current Token. Grabs a new one from the TokenProvider and saves it
+// within the GraphClient instance. The caller must hold the write-lock of g.tokenMu.
+//
+// If a TokenCache is set, a still valid Token is taken from it instead and every acquired Token is
+// cached. Errors of the TokenCache are only logged.
+func (g *GraphClient) refreshToken(ctx context.Context) error {
+	provider := g.getTokenProvider()
+	var cacheKey string
+	if g.tokenCache != nil {
+		cacheKey = g.TokenCacheKey()
+	}
+	if cacheKey != "" {
+		cached, err := g.tokenCache.Get(cacheKey)
+		if err == nil {
+			if seeder, ok := provider.(tokenCacheSeeder); ok {
+				seeder.seedToken(cached)
+			}
+			if !cached.WantsToBeRefreshed() {
+				g.token = cached
+				return nil
+			}
+		} else if !errors.Is(err, ErrTokenNotCached) {
+			g.log(LogLevelWarn, "msgraph cannot get Token from TokenCache", "error", err)
+		}
+	}
 
-	var newToken Token
-	err = g.performRequest(req, &newToken) // perform the prepared request
+	token, err := provider.Token(ctx)
 	if err != nil {
-		return fmt.Errorf("error on getting msgraph Token: %v", err)
+		return fmt.Errorf("error on getting msgraph Token: %w", err)
+	}
+	g.token = token
+	if cacheKey != "" {
+		if err := g.tokenCache.Set(cacheKey, token); err != nil {
+			g.log(LogLevelWarn, "msgraph cannot store Token in TokenCache", "error", err)
+		}
 	}
-	g.token = newToken
-	return err
+	return nil
 }
 
 // makeGETAPICall performs an API-Call to the msgraph API.
@@ -153,90 +212,185 @@ func (g *GraphClient) makeDELETEAPICall(apiCall string, reqParams getRequestPara
 	return g.makeAPICall(apiCall, http.MethodDelete, reqParams, nil, v)
 }
 
-// makeAPICall performs an API-Call to the msgraph API. This func uses sync.Mutex to synchronize all API-calls.
+// makeAPICall performs an API-Call to the msgraph API. API-calls run concurrently, their amount
+// can be limited with WithMaxConcurrency.
 //
 // Parameter httpMethod may be http.MethodGet, http.MethodPost or http.MethodPatch
 //
 // Parameter body may be nil to not provide any content - e.g. when using a http GET request.
 func (g *GraphClient) makeAPICall(apiCall string, httpMethod string, reqParams getRequestParams, body io.Reader, v interface{}) error {
-	g.makeSureURLsAreSet()
-	g.apiCall.Lock()
-	defer g.apiCall.Unlock() // unlock when the func returns
-	// Check token
-	if g.token.WantsToBeRefreshed() { // Token not valid anymore?
-		err := g.refreshToken()
-		if err != nil {
-			return err
-		}
+	reqURL, err := g.buildAPIURL(reqParams.APIVersion(), apiCall)
+	if err != nil {
+		return err
 	}
 
+	// Query options $filter, $orderby, $count, $skip, and $top can be applied only on collections,
+	// which are loaded page by page by following the @odata.nextLink
+	if listParams, isCollection := reqParams.(*listQueryOptions); isCollection && httpMethod == http.MethodGet {
+		return g.makePagedAPICall(reqURL, listParams, v)
+	}
+
+	reqURL.RawQuery = reqParams.Values().Encode() // set query parameters
+	return g.performAPIRequest(reqParams.Context(), httpMethod, reqURL.String(), reqParams.Headers(), body, v)
+}
+
+// buildAPIURL returns the absolute URL of the given apiCall, hence the service root endpoint
+// followed by the API version and the apiCall, e.g. https://graph.microsoft.com/beta/users. The
+// API version of the GraphClient is used if apiVersion is empty.
+func (g *GraphClient) buildAPIURL(apiVersion, apiCall string) (*url.URL, error) {
 	reqURL, err := url.ParseRequestURI(g.serviceRootEndpoint)
 	if err != nil {
-		return fmt.Errorf("unable to parse URI %v: %v", g.serviceRootEndpoint, err)
+		return nil, fmt.Errorf("unable to parse URI %v: %v", g.serviceRootEndpoint, err)
 	}
 
 	// Add Version to API-Call, the leading slash is always added by the calling func
-	reqURL.Path = "/" + APIVersion + apiCall
+	if apiVersion == "" {
+		apiVersion = g.getAPIVersion()
+	}
+	reqURL.Path = "/" + apiVersion + apiCall
+	return reqURL, nil
+}
 
-	req, err := http.NewRequestWithContext(reqParams.Context(), httpMethod, reqURL.String(), body)
+// parseServiceRootURL parses the given absolute URL, e.g. an @odata.nextLink, and returns an error
+// if it does not belong to the Service Root Endpoint, hence the Token is never sent to another host.
+func (g *GraphClient) parseServiceRootURL(rawURL string) (*url.URL, error) {
+	reqURL, err := url.Parse(rawURL)
 	if err != nil {
-		return fmt.Errorf("HTTP request error: %v", err)
+		return nil, fmt.Errorf("unable to parse URI %v: %v", redactURL(rawURL), err)
+	}
+	root, err := url.Parse(g.serviceRootEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse URI %v: %v", g.serviceRootEndpoint, err)
+	}
+	if reqURL.Scheme != root.Scheme || reqURL.Host != root.Host {
+		return nil, fmt.Errorf("URL %v does not belong to the service root endpoint %v", redactURL(rawURL), g.serviceRootEndpoint)
 	}
+	return reqURL, nil
+}
 
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", g.token.GetAccessToken())
+// getAPIVersion returns the API version set with WithAPIVersion, APIVersion if none is set
+func (g *GraphClient) getAPIVersion() string {
+	if g.apiVersion == "" {
+		return APIVersion
+	}
+	return g.apiVersion
+}
 
-	for key, vals := range reqParams.Headers() {
-		for idx := range vals {
-			req.Header.Add(key, vals[idx])
+// performAPIRequest prepares a http.Request for the given absolute reqURL, authenticates it with
+// the current Token - which is refreshed if necessary - and performs it. Throttled requests are
+// retried according to the RetryPolicy of the GraphClient, hence the body is read upfront.
+func (g *GraphClient) performAPIRequest(ctx context.Context, httpMethod string, reqURL string, headers http.Header, body io.Reader, v interface{}) error {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		if bodyBytes, err = ioutil.ReadAll(body); err != nil {
+			return fmt.Errorf("HTTP request body read error: %w", err)
+		}
+	}
+
+	var retryPolicy = g.getRetryPolicy()
+	for attempt := 1; ; attempt++ {
+		err := g.performAPIRequestAttempt(ctx, httpMethod, reqURL, headers, bodyBytes, v)
+		delay, retry := retryPolicy.retryDelay(httpMethod, attempt, err)
+		if !retry {
+			return err
+		}
+		g.log(LogLevelInfo, "retrying msgraph request", "method", httpMethod, "url", redactURL(reqURL),
+			"attempt", attempt+1, "delay", delay, "error", err)
+		if !sleepContext(ctx, delay) {
+			return err
 		}
 	}
+}
 
-	var getParams = reqParams.Values()
+// performAPIRequestAttempt performs a single attempt of performAPIRequest. If bodyBytes is nil,
+// the request is sent without body.
+func (g *GraphClient) performAPIRequestAttempt(ctx context.Context, httpMethod string, reqURL string, headers http.Header, bodyBytes []byte, v interface{}) error {
+	release, err := g.acquireConcurrency(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	// Query options $filter, $orderby, $count, $skip, and $top can be applied only on collections
-	if _, isCollection := reqParams.(*listQueryOptions); isCollection {
-		getParams.Add("$top", strconv.Itoa(MaxPageSize))
+	req, err := g.newAPIRequest(ctx, httpMethod, reqURL, headers, bodyBytes)
+	if err != nil {
+		return err
 	}
-	/*
-		if httpMethod == http.MethodGet {
-			// TODO: Improve performance with using $skip & paging instead of retrieving all results with $top
-			// TODO: MaxPageSize is currently 999, if there are any time more than 999 entries this will make the program unpredictable... hence start to use paging (!)
-			getParams.Add("$top", strconv.Itoa(MaxPageSize))
-		}
-	*/
-	req.URL.RawQuery = getParams.Encode() // set query parameters
 	return g.performRequest(req, v)
 }
 
+// acquireConcurrency waits for a free slot if the concurrent API-calls are limited, see
+// WithMaxConcurrency, and returns the func that frees the slot again.
+func (g *GraphClient) acquireConcurrency(ctx context.Context) (release func(), err error) {
+	if g.concurrency == nil {
+		return func() {}, nil
+	}
+	select {
+	case g.concurrency <- struct{}{}:
+		return func() { <-g.concurrency }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newAPIRequest creates a http.Request for the given absolute reqURL that is authenticated with
+// the current Token, which is refreshed if necessary. The given headers replace the default ones,
+// e.g. the Content-Type application/json. If bodyBytes is nil, the request is sent without body.
+func (g *GraphClient) newAPIRequest(ctx context.Context, httpMethod string, reqURL string, headers http.Header, bodyBytes []byte) (*http.Request, error) {
+	var body io.Reader
+	if bodyBytes != nil {
+		body = bytes.NewReader(bodyBytes)
+	}
+
+	token, err := g.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, httpMethod, reqURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request error: %v", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", token.GetAccessToken())
+
+	for key, vals := range headers {
+		req.Header.Del(key)
+		for idx := range vals {
+			req.Header.Add(key, vals[idx])
+		}
+	}
+	return req, nil
+}
+
 // performRequest performs a pre-prepared http.Request and does the proper error-handling for it.
 // does a json.Unmarshal into the v interface{} and returns the error of it if everything went well so far.
 func (g *GraphClient) performRequest(req *http.Request, v interface{}) error {
-	httpClient := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	resp, err := httpClient.Do(req)
+	g.logRequest(req)
+	start := time.Now()
+	resp, err := g.graphHTTPClient().Do(req)
 	if err != nil {
-		return fmt.Errorf("HTTP response error: %v of http.Request: %v", err, req.URL)
+		g.log(LogLevelError, "msgraph request failed", "method", req.Method, "url", redactURL(req.URL.String()),
+			"duration", time.Since(start), "error", err)
+		return fmt.Errorf("HTTP response error: %w of http.Request: %v", err, req.URL)
 	}
 	defer resp.Body.Close() // close body when func returns
 
 	body, err := ioutil.ReadAll(resp.Body) // read body first to append it to the error (if any)
+	g.logResponse(req, resp, time.Since(start), body)
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		// Hint: this will mostly be the case if the tenant ID cannot be found, the Application ID cannot be found or the clientSecret is incorrect.
-		// The cause will be described in the body, hence we have to return the body too for proper error-analysis
-		return fmt.Errorf("StatusCode is not OK: %v. Body: %v ", resp.StatusCode, string(body))
+		// The cause will be described in the body, hence it is parsed into the GraphError and kept for proper error-analysis
+		return newGraphError(resp.StatusCode, resp.Header, body)
 	}
 	if err != nil {
 		return fmt.Errorf("HTTP response read error: %v of http.Request: %v", err, req.URL)
 	}
 
-	if !strings.HasSuffix(req.URL.Path, `oauth2/token`) {
-		fmt.Printf("Status: %s Code: %d\nBody: %s\n\n", resp.Status, resp.StatusCode, body)
-	}
-
-	// Control whether content should be returned by passing nil value for v instead of http Method
-	if v == nil {
+	// Control whether content should be returned by passing nil value for v instead of http Method.
+	// Responses without content, e.g. 204 - No Content, leave v untouched.
+	if v == nil || len(body) == 0 {
 		return nil
 	}
 	/* no content returned when http PATCH or DELETE is used, e.g. User.DeleteUser()
@@ -247,6 +401,39 @@ func (g *GraphClient) performRequest(req *http.Request, v interface{}) error {
 	return json.Unmarshal(body, &v) // return the error of the json unmarshal
 }
 
+// logRequest logs the body of the request if enabled with WithBodyLogging
+func (g *GraphClient) logRequest(req *http.Request) {
+	if g.logger == nil || !g.logBodies || req.GetBody == nil {
+		return
+	}
+	if body, err := req.GetBody(); err == nil {
+		bodyBytes, _ := ioutil.ReadAll(body)
+		g.log(LogLevelDebug, "msgraph request", "method", req.Method, "url", redactURL(req.URL.String()), "body", redactBody(bodyBytes))
+	}
+}
+
+// logResponse logs the metadata of the response and its body if enabled with WithBodyLogging.
+// Responses with a status code that is not 2xx are logged with LogLevelWarn, except for the
+// authorization_pending and slow_down errors expected while polling in the device code flow.
+func (g *GraphClient) logResponse(req *http.Request, resp *http.Response, duration time.Duration, body []byte) {
+	if g.logger == nil {
+		return
+	}
+	level := LogLevelDebug
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		level = LogLevelWarn
+		if err := newGraphError(resp.StatusCode, resp.Header, body); isOAuthError(err, "authorization_pending") || isOAuthError(err, "slow_down") {
+			level = LogLevelDebug
+		}
+	}
+	keysAndValues := []interface{}{"method", req.Method, "url", redactURL(req.URL.String()), "status", resp.StatusCode,
+		"duration", duration, "request-id", resp.Header.Get("request-id")}
+	if g.logBodies {
+		keysAndValues = append(keysAndValues, "body", redactBody(body))
+	}
+	g.log(level, "msgraph response", keysAndValues...)
+}
+
 // UnmarshalJSON implements the json unmarshal to be used by the json-library.
 // This method additionally to loading the TenantID, ApplicationID and ClientSecret
 // immediately gets a Token from msgraph (hence initialize this GraphAPI instance)
@@ -282,30 +469,11 @@ func (g *GraphClient) UnmarshalJSON(data []byte) error {
 	g.makeSureURLsAreSet()
 
 	// get a token and return the error (if any)
-	err = g.refreshToken()
+	g.tokenMu.Lock()
+	err = g.refreshToken(context.Background())
+	g.tokenMu.Unlock()
 	if err != nil {
-		return fmt.Errorf("can't get Token: %v", err)
+		return fmt.Errorf("can't get Token: %w", err)
 	}
 	return nil
 }
-
-/* unused:
-func (g *GraphClient) performRaw(req *http.Request) (*http.Response, error) {
-	g.makeSureURLsAreSet()
-	g.apiCall.Lock()
-	defer g.apiCall.Unlock() // unlock when the func returns
-	// Check token
-	if g.token.WantsToBeRefreshed() { // Token not valid anymore?
-		err := g.refreshToken()
-		if err != nil {
-			return nil, err
-		}
-	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", g.token.GetAccessToken())
-	httpClient := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	return httpClient.Do(req)
-}
-*/
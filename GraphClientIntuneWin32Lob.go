@@ -143,7 +143,8 @@ func (g *GraphClient) Win32LobAppContentFileUpload(intuneWinFile io.Reader, file
 	}
 	blockCount := int(math.Ceil(float64(fileSize) / blocksize))
 
-	fmt.Printf("\n>> %s LEN: %d COUNT: %d SURI: %s\n\n", xmlMeta.Name, len(xmlMeta.Data), blockCount, fileContent.AzureStorageUri)
+	g.log(LogLevelDebug, "uploading intunewin file", "name", xmlMeta.Name, "size", len(xmlMeta.Data), "blocks", blockCount,
+		"storageUri", redactURL(fileContent.AzureStorageUri))
 
 	doneChan := make(chan error, blockCount)
 	ticker := time.NewTicker(time.Minute * 12)
@@ -162,7 +163,7 @@ func (g *GraphClient) Win32LobAppContentFileUpload(intuneWinFile io.Reader, file
 		default:
 			block = xmlMeta.Data[start:stop]
 		}
-		go win32LobAppUploadBlock(xmlMeta.Name, fileContent.AzureStorageUri, blockID, block, doneChan)
+		go g.win32LobAppUploadBlock(xmlMeta.Name, fileContent.AzureStorageUri, blockID, block, doneChan)
 	}
 uploadLoop:
 	for {
@@ -186,7 +187,7 @@ uploadLoop:
 		return fmt.Errorf("error uploading %d of %d: %v", len(errs), blockCount, errs)
 	}
 	fileContent.Refresh()
-	err = win32LobAppUploadFinalize(xmlMeta.Name, fileContent.AzureStorageUri, blockIDs)
+	err = g.win32LobAppUploadFinalize(xmlMeta.Name, fileContent.AzureStorageUri, blockIDs)
 	if err != nil {
 		return fmt.Errorf("error finalizing upload: %w", err)
 	}
@@ -252,10 +253,10 @@ func stdBase64Encode(v string) string {
 	return base64.StdEncoding.EncodeToString([]byte(v))
 }
 
-func win32LobAppUploadBlock(xmlName, storageURI, blockID string, data []byte, doneChan chan error) {
+func (g *GraphClient) win32LobAppUploadBlock(xmlName, storageURI, blockID string, data []byte, doneChan chan error) {
 	var count int
-	client := http.Client{}
-	err := win32UploadBlock(xmlName, storageURI, blockID, data, &client)
+	client := g.storageHTTPClient()
+	err := g.win32UploadBlock(xmlName, storageURI, blockID, data, client)
 retryLoop:
 	for err != nil {
 		switch err {
@@ -264,9 +265,9 @@ retryLoop:
 				break retryLoop
 			}
 			count++
-			fmt.Println("Received 403 Auth Error, Retrying, Attempt:", count)
+			g.log(LogLevelWarn, "received 403 auth error on block upload, retrying", "name", xmlName, "blockId", blockID, "attempt", count)
 			time.Sleep(time.Second * 2)
-			err = win32UploadBlock(xmlName, storageURI, blockID, data, &client)
+			err = g.win32UploadBlock(xmlName, storageURI, blockID, data, client)
 		default:
 			break retryLoop
 		}
@@ -274,7 +275,7 @@ retryLoop:
 	doneChan <- err
 }
 
-func win32UploadBlock(xmlName, storageURI, blockID string, data []byte, client *http.Client) error {
+func (g *GraphClient) win32UploadBlock(xmlName, storageURI, blockID string, data []byte, client *http.Client) error {
 	params := url.Values{}
 	params.Add(`comp`, `block`)
 	params.Add(`blockid`, blockID)
@@ -290,14 +291,15 @@ func win32UploadBlock(xmlName, storageURI, blockID string, data []byte, client *
 		return fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
-	fmt.Println(xmlName, "Upload Status:", resp.Status, "Code:", resp.StatusCode, "SURI:", storageURI)
+	g.log(LogLevelDebug, "uploaded block", "name", xmlName, "blockId", blockID, "status", resp.StatusCode,
+		"storageUri", redactURL(storageURI))
 	if resp.StatusCode == 403 {
 		return errStatusAuth
 	}
 	return nil
 }
 
-func win32LobAppUploadFinalize(xmlName, storageURI string, blockIDs []string) error {
+func (g *GraphClient) win32LobAppUploadFinalize(xmlName, storageURI string, blockIDs []string) error {
 	params := url.Values{}
 	params.Add(`comp`, `blocklist`)
 	U := storageURI + `&` + params.Encode()
@@ -311,8 +313,7 @@ func win32LobAppUploadFinalize(xmlName, storageURI string, blockIDs []string) er
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := g.storageHTTPClient().Do(req)
 	if err != nil {
 		return fmt.Errorf("error sending request: %w", err)
 	}
@@ -321,8 +322,8 @@ func win32LobAppUploadFinalize(xmlName, storageURI string, blockIDs []string) er
 	if err != nil {
 		return fmt.Errorf("error reading response: %w", err)
 	}
-	fmt.Println(xmlName, "Finalize XML:", xml)
-	fmt.Println(xmlName, "Finalize Status:", resp.Status, "Code:", resp.StatusCode, "SURI:", storageURI)
+	g.log(LogLevelDebug, "finalized upload", "name", xmlName, "blockList", xml, "status", resp.StatusCode,
+		"storageUri", redactURL(storageURI))
 	if resp.StatusCode == 403 {
 		return errStatusAuth
 	}
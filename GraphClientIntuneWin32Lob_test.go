@@ -0,0 +1,92 @@
+package msgraph
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/jbvmio/go-msgraph/msgraphtest"
+)
+
+// newTestIntuneWinFile returns an intunewin package with the given encrypted content
+func newTestIntuneWinFile(t *testing.T, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"IntuneWinPackage/Metadata/Detection.xml", []byte(`<ApplicationInfo><Name>setup.exe</Name>` +
+			`<UnencryptedContentSize>42</UnencryptedContentSize><FileName>IntunePackage.intunewin</FileName>` +
+			`<SetupFile>setup.exe</SetupFile><EncryptionInfo><EncryptionKey>a2V5</EncryptionKey><MacKey>bWFj</MacKey>` +
+			`<InitializationVector>aXY=</InitializationVector><Mac>bWFj</Mac><ProfileIdentifier>ProfileVersion1</ProfileIdentifier>` +
+			`<FileDigest>ZGlnZXN0</FileDigest><FileDigestAlgorithm>SHA256</FileDigestAlgorithm></EncryptionInfo></ApplicationInfo>`)},
+		{"IntuneWinPackage/Contents/IntunePackage.intunewin", content},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatalf("Cannot create %v: %v", f.name, err)
+		}
+		w.Write(f.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Cannot close the intunewin package: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGraphClient_Win32LobAppContentFileUpload(t *testing.T) {
+	srv := msgraphtest.NewServer()
+	defer srv.Close()
+	g, err := NewGraphClientWithCustomEndpoint(srv.TenantID, srv.ApplicationID, srv.ClientSecret, srv.URL, srv.URL)
+	if err != nil {
+		t.Fatalf("Cannot initialize a GraphClient for the fake msgraph API: %v", err)
+	}
+
+	content := bytes.Repeat([]byte("encrypted"), 1000)
+	intuneWin := newTestIntuneWinFile(t, content)
+	xmlMeta, err := GetIntuneWin32AppMetadata(bytes.NewReader(intuneWin), false)
+	if err != nil {
+		t.Fatalf("GetIntuneWin32AppMetadata() error = %v", err)
+	}
+
+	app, err := g.CreateWin32LobApp(NewWin32LobAppRequest(xmlMeta))
+	if err != nil {
+		t.Fatalf("CreateWin32LobApp() error = %v", err)
+	}
+	defer g.DeleteWin32LobApp(app.ID)
+	if app.ID == "" || app.DisplayName != "setup.exe" {
+		t.Errorf("CreateWin32LobApp() = %v, want an ID and DisplayName setup.exe", app)
+	}
+
+	file, err := app.CreateContentFile(NewMobileAppContentFileRequest(xmlMeta))
+	if err != nil {
+		t.Fatalf("CreateContentFile() error = %v", err)
+	}
+	if file.Context.AppID != app.ID || file.Context.ContentVersion != "1" {
+		t.Errorf("CreateContentFile() Context = %v, want AppID %v and ContentVersion 1", file.Context, app.ID)
+	}
+
+	if err := file.UploadIntuneWin(bytes.NewReader(intuneWin)); err != nil {
+		t.Fatalf("UploadIntuneWin() error = %v", err)
+	}
+	if uploaded, ok := srv.Blob(file.ID); !ok || !bytes.Equal(uploaded, content) {
+		t.Errorf("UploadIntuneWin() uploaded %d bytes (committed: %v), want %d bytes", len(uploaded), ok, len(content))
+	}
+	committed, err := g.GetWin32LobApp(app.ID)
+	if err != nil {
+		t.Fatalf("GetWin32LobApp() error = %v", err)
+	}
+	if committed.CommittedContentVersion != "1" {
+		t.Errorf("GetWin32LobApp() CommittedContentVersion = %q, want 1", committed.CommittedContentVersion)
+	}
+
+	if err := g.DeleteWin32LobApp(app.ID); err != nil {
+		t.Fatalf("DeleteWin32LobApp() error = %v", err)
+	}
+	if _, err := g.GetWin32LobApp(app.ID); !IsNotFound(err) {
+		t.Errorf("GetWin32LobApp() after delete error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,304 @@
+package msgraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CreateSubscription creates the given Subscription, e.g. from NewUsersSubscription, and returns
+// the created Subscription with its ID. If the ExpirationDateTime is not set, the Subscription
+// expires after the maximum lifetime of its resource. Microsoft validates the NotificationURL
+// before the Subscription is created, hence it must answer the validation request already, see
+// SubscriptionHandler.
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/subscription-post-subscriptions
+func (g *GraphClient) CreateSubscription(sub Subscription, opts ...CreateQueryOption) (Subscription, error) {
+	if sub.ExpirationDateTime.IsZero() {
+		sub.ExpirationDateTime = time.Now().Add(sub.MaxLifetime() - subscriptionExpirationMargin).UTC()
+	}
+	bodyBytes, err := json.Marshal(sub)
+	if err != nil {
+		return sub, err
+	}
+	created := Subscription{graphClient: g}
+	err = g.makePOSTAPICall("/subscriptions", compileCreateQueryOptions(opts), bytes.NewReader(bodyBytes), &created)
+	return created, err
+}
+
+// ListSubscriptions returns all Subscriptions of the application, or of the signed-in user with
+// delegated access.
+// Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/subscription-list
+func (g *GraphClient) ListSubscriptions(opts ...ListQueryOption) (Subscriptions, error) {
+	var marsh struct {
+		Subscriptions Subscriptions `json:"value"`
+	}
+	err := g.makeGETAPICall("/subscriptions", compileListQueryOptions(opts), &marsh)
+	marsh.Subscriptions.setGraphClient(g)
+	return marsh.Subscriptions, err
+}
+
+// GetSubscription returns the Subscription with the given ID. If the Subscription cannot be found,
+// e.g. because it has expired, the returned GraphError wraps ErrFindSubscription.
+// Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/subscription-get
+func (g *GraphClient) GetSubscription(subscriptionID string, opts ...GetQueryOption) (Subscription, error) {
+	resource := fmt.Sprintf("/subscriptions/%v", subscriptionID)
+	sub := Subscription{graphClient: g}
+	err := g.makeGETAPICall(resource, compileGetQueryOptions(opts), &sub)
+	return sub, wrapGraphErrorOnStatus(err, http.StatusNotFound, ErrFindSubscription)
+}
+
+// RenewSubscription extends the Subscription with the given ID to the given expiration, which must
+// not exceed the maximum lifetime of its resource, see SubscriptionMaxLifetime. If the Subscription
+// cannot be found, e.g. because it has already expired, the returned GraphError wraps ErrFindSubscription.
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/subscription-update
+func (g *GraphClient) RenewSubscription(subscriptionID string, expiration time.Time, opts ...UpdateQueryOption) (Subscription, error) {
+	resource := fmt.Sprintf("/subscriptions/%v", subscriptionID)
+	bodyBytes, err := json.Marshal(struct {
+		ExpirationDateTime time.Time `json:"expirationDateTime"`
+	}{ExpirationDateTime: expiration.UTC()})
+	if err != nil {
+		return Subscription{}, err
+	}
+	// the updated Subscription is returned, but keep the ID and expiration in case the body is empty
+	sub := Subscription{ID: subscriptionID, ExpirationDateTime: expiration.UTC(), graphClient: g}
+	err = g.makePATCHAPICall(resource, compileUpdateQueryOptions(opts), bytes.NewReader(bodyBytes), &sub)
+	return sub, wrapGraphErrorOnStatus(err, http.StatusNotFound, ErrFindSubscription)
+}
+
+// DeleteSubscription deletes the Subscription with the given ID, hence no further notifications are sent.
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/subscription-delete
+func (g *GraphClient) DeleteSubscription(subscriptionID string, opts ...DeleteQueryOption) error {
+	resource := fmt.Sprintf("/subscriptions/%v", subscriptionID)
+	err := g.makeDELETEAPICall(resource, compileDeleteQueryOptions(opts), nil)
+	return wrapGraphErrorOnStatus(err, http.StatusNotFound, ErrFindSubscription)
+}
+
+// SubscriptionRenewer renews Subscriptions before their maximum lifetime elapses, hence they do not
+// expire as long as the SubscriptionRenewer runs. Create it with GraphClient.NewSubscriptionRenewer:
+//
+//	renewer := graphClient.NewSubscriptionRenewer(msgraph.RenewerWithErrorHandler(func(sub msgraph.Subscription, err error) {
+//		log.Printf("cannot renew %v: %v", sub.ID, err)
+//	}))
+//	renewer.Add(sub)
+//	go renewer.Run(ctx)
+//
+// Subscriptions are renewed to their maximum lifetime, see SubscriptionMaxLifetime. Subscriptions
+// that cannot be found anymore are removed from the SubscriptionRenewer, failed renewals are retried.
+type SubscriptionRenewer struct {
+	graphClient   *GraphClient
+	renewBefore   time.Duration // renew this long before expiration, a tenth of the maximum lifetime if not set
+	retryInterval time.Duration // retry failed renewals after this interval
+	onRenew       func(sub Subscription)
+	onError       func(sub Subscription, err error)
+
+	mu            sync.Mutex
+	subscriptions map[string]*renewal // the renewals by subscription ID
+	wake          chan struct{}       // wakes up Run after the subscriptions have changed
+}
+
+// renewal is a Subscription of a SubscriptionRenewer and the time it is renewed next
+type renewal struct {
+	subscription Subscription
+	renewAt      time.Time
+}
+
+// SubscriptionRenewerOption configures optional settings of a SubscriptionRenewer, see GraphClient.NewSubscriptionRenewer
+type SubscriptionRenewerOption func(r *SubscriptionRenewer)
+
+var (
+	// RenewerWithRenewBefore - renew the Subscriptions this long before they expire. Defaults to a
+	// tenth of the maximum lifetime of the resource, e.g. 7 hours for events. Capped at half of the
+	// maximum lifetime, hence Subscriptions are not renewed over and over again.
+	RenewerWithRenewBefore = func(renewBefore time.Duration) SubscriptionRenewerOption {
+		return func(r *SubscriptionRenewer) {
+			r.renewBefore = renewBefore
+		}
+	}
+
+	// RenewerWithRetryInterval - retry failed renewals after the given interval, defaults to one minute.
+	// Values <= 0 are ignored, hence failed renewals are never retried immediately.
+	RenewerWithRetryInterval = func(retryInterval time.Duration) SubscriptionRenewerOption {
+		return func(r *SubscriptionRenewer) {
+			if retryInterval > 0 {
+				r.retryInterval = retryInterval
+			}
+		}
+	}
+
+	// RenewerWithRenewHandler - call fn with the renewed Subscription after each successful renewal.
+	RenewerWithRenewHandler = func(fn func(sub Subscription)) SubscriptionRenewerOption {
+		return func(r *SubscriptionRenewer) {
+			r.onRenew = fn
+		}
+	}
+
+	// RenewerWithErrorHandler - call fn whenever a Subscription cannot be renewed. If the error
+	// wraps ErrFindSubscription, the Subscription has been removed from the SubscriptionRenewer.
+	RenewerWithErrorHandler = func(fn func(sub Subscription, err error)) SubscriptionRenewerOption {
+		return func(r *SubscriptionRenewer) {
+			r.onError = fn
+		}
+	}
+)
+
+// NewSubscriptionRenewer returns a new SubscriptionRenewer, which renews its Subscriptions with
+// this GraphClient once Run is called.
+func (g *GraphClient) NewSubscriptionRenewer(opts ...SubscriptionRenewerOption) *SubscriptionRenewer {
+	r := &SubscriptionRenewer{
+		graphClient:   g,
+		retryInterval: time.Minute,
+		subscriptions: map[string]*renewal{},
+		wake:          make(chan struct{}, 1),
+	}
+	for idx := range opts {
+		opts[idx](r)
+	}
+	return r
+}
+
+// Add adds the Subscription to the SubscriptionRenewer, e.g. after CreateSubscription. A
+// Subscription with the same ID is replaced.
+func (r *SubscriptionRenewer) Add(sub Subscription) {
+	r.mu.Lock()
+	r.subscriptions[sub.ID] = &renewal{subscription: sub, renewAt: r.renewAt(sub)}
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Remove removes the Subscription with the given ID from the SubscriptionRenewer, e.g. before
+// DeleteSubscription. The Subscription is not deleted.
+func (r *SubscriptionRenewer) Remove(subscriptionID string) {
+	r.mu.Lock()
+	delete(r.subscriptions, subscriptionID)
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Subscriptions returns the current Subscriptions of the SubscriptionRenewer with their latest
+// ExpirationDateTime.
+func (r *SubscriptionRenewer) Subscriptions() Subscriptions {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subs := make(Subscriptions, 0, len(r.subscriptions))
+	for _, ren := range r.subscriptions {
+		subs = append(subs, ren.subscription)
+	}
+	return subs
+}
+
+// Run renews the Subscriptions until ctx is done and returns the error of ctx. Subscriptions can be
+// added and removed while Run is running.
+func (r *SubscriptionRenewer) Run(ctx context.Context) error {
+	for {
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if next, ok := r.next(); ok {
+			timer = time.NewTimer(time.Until(next))
+			timerC = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-r.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-timerC:
+			r.renewDue(ctx)
+		}
+	}
+}
+
+// notify wakes up Run, hence it recalculates the next renewal
+func (r *SubscriptionRenewer) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the time of the next renewal, false if there are no Subscriptions
+func (r *SubscriptionRenewer) next() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var next time.Time
+	for _, ren := range r.subscriptions {
+		if next.IsZero() || ren.renewAt.Before(next) {
+			next = ren.renewAt
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// renewAt returns the time the Subscription is renewed next
+func (r *SubscriptionRenewer) renewAt(sub Subscription) time.Time {
+	maxLifetime := sub.MaxLifetime()
+	renewBefore := r.renewBefore
+	if renewBefore <= 0 {
+		renewBefore = maxLifetime / 10
+	}
+	if renewBefore > maxLifetime/2 {
+		renewBefore = maxLifetime / 2
+	}
+	return sub.ExpirationDateTime.Add(-renewBefore)
+}
+
+// renewDue renews all Subscriptions whose renewal is due
+func (r *SubscriptionRenewer) renewDue(ctx context.Context) {
+	now := time.Now()
+	var due Subscriptions
+	r.mu.Lock()
+	for _, ren := range r.subscriptions {
+		if !ren.renewAt.After(now) {
+			due = append(due, ren.subscription)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, sub := range due {
+		expiration := time.Now().Add(sub.MaxLifetime() - subscriptionExpirationMargin)
+		renewed, err := r.graphClient.RenewSubscription(sub.ID, expiration, UpdateWithContext(ctx))
+		if ctx.Err() != nil {
+			return // Run is stopping, the renewal is retried on the next Run
+		}
+		if err == nil {
+			// keep the properties of the Subscription if the response only contains some of them
+			sub.ExpirationDateTime = renewed.ExpirationDateTime
+			sub.setGraphClient(r.graphClient)
+		}
+
+		r.mu.Lock()
+		ren, ok := r.subscriptions[sub.ID]
+		switch {
+		case !ok: // removed in the meantime
+		case err == nil:
+			ren.subscription, ren.renewAt = sub, r.renewAt(sub)
+		case IsNotFound(err):
+			delete(r.subscriptions, sub.ID)
+		default:
+			ren.renewAt = time.Now().Add(r.retryInterval)
+		}
+		r.mu.Unlock()
+
+		switch {
+		case err == nil && r.onRenew != nil:
+			r.onRenew(sub)
+		case err != nil && r.onError != nil:
+			r.onError(sub, err)
+		}
+	}
+}
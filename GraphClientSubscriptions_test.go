@@ -0,0 +1,170 @@
+package msgraph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jbvmio/go-msgraph/msgraphtest"
+)
+
+// newFakeGraphClient returns a GraphClient for a new msgraphtest.Server
+func newFakeGraphClient(t *testing.T) (*GraphClient, *msgraphtest.Server) {
+	t.Helper()
+	srv := msgraphtest.NewServer()
+	t.Cleanup(srv.Close)
+	g, err := NewGraphClientWithCustomEndpoint(srv.TenantID, srv.ApplicationID, srv.ClientSecret, srv.URL, srv.URL)
+	if err != nil {
+		t.Fatalf("Cannot initialize a GraphClient for the fake msgraph API: %v", err)
+	}
+	return g, srv
+}
+
+func TestGraphClient_Subscriptions(t *testing.T) {
+	g, srv := newFakeGraphClient(t)
+
+	created, err := g.CreateSubscription(Subscription{Resource: "users", ChangeType: ChangeTypeUpdated, NotificationURL: "https://example.com/notify"})
+	if err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	if created.ID == "" || created.Resource != "users" || created.graphClient != g {
+		t.Errorf("CreateSubscription() = %v, want an ID and the resource", created)
+	}
+	if until := time.Until(created.ExpirationDateTime); until < 41700*time.Minute || until > 41760*time.Minute {
+		t.Errorf("CreateSubscription() expires in %v, want the maximum lifetime of users", until)
+	}
+
+	// the expiration of constructed Subscriptions depends on IncludeResourceData set afterwards
+	events := NewUserEventsSubscription("alice@contoso.com", ChangeTypeCreated, "https://example.com/notify", "secret")
+	events.IncludeResourceData = true
+	if created, err := g.CreateSubscription(events); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	} else if until := time.Until(created.ExpirationDateTime); until < 1430*time.Minute || until > 1440*time.Minute {
+		t.Errorf("CreateSubscription() with resource data expires in %v, want the maximum lifetime of events with resource data", until)
+	} else if err := created.Delete(); err != nil {
+		t.Fatalf("Subscription.Delete() error = %v", err)
+	}
+
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	renewed, err := created.Renew(expiration)
+	if err != nil {
+		t.Fatalf("Subscription.Renew() error = %v", err)
+	}
+	if !renewed.ExpirationDateTime.Equal(expiration) || renewed.Resource != "users" {
+		t.Errorf("Subscription.Renew() = %v, want expiration %v", renewed, expiration)
+	}
+
+	subs, err := g.ListSubscriptions()
+	if err != nil || len(subs) != 1 || !subs[0].ExpirationDateTime.Equal(expiration) {
+		t.Errorf("ListSubscriptions() = %v, error = %v, want the renewed subscription", subs, err)
+	}
+
+	if err := renewed.Delete(); err != nil {
+		t.Fatalf("Subscription.Delete() error = %v", err)
+	}
+	if _, err := g.GetSubscription(created.ID); !errors.Is(err, ErrFindSubscription) {
+		t.Errorf("GetSubscription() after delete error = %v, want %v", err, ErrFindSubscription)
+	}
+	if _, err := g.RenewSubscription(created.ID, expiration); !errors.Is(err, ErrFindSubscription) {
+		t.Errorf("RenewSubscription() after delete error = %v, want %v", err, ErrFindSubscription)
+	}
+	if n := len(srv.List("/subscriptions")); n != 0 {
+		t.Errorf("fake msgraph API has %v subscriptions after delete, want none", n)
+	}
+}
+
+func TestSubscriptionRenewer(t *testing.T) {
+	g, srv := newFakeGraphClient(t)
+	failures := 0
+	srv.Handle(http.MethodPatch, "/subscriptions/failing", func(w http.ResponseWriter, r *http.Request) {
+		failures++
+		if failures == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	events, err := g.CreateSubscription(NewUserEventsSubscription("alice@contoso.com", ChangeTypeCreated, "https://example.com/notify", ""))
+	if err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	events.ExpirationDateTime = time.Now().Add(time.Minute) // due for renewal
+	users, err := g.CreateSubscription(NewUsersSubscription(ChangeTypeUpdated, "https://example.com/notify", ""))
+	if err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+
+	var mu sync.Mutex
+	renewedIDs, errs := map[string]int{}, map[string]error{}
+	done := make(chan struct{}, 10)
+	renewer := g.NewSubscriptionRenewer(
+		RenewerWithRetryInterval(10*time.Millisecond),
+		RenewerWithRenewHandler(func(sub Subscription) {
+			mu.Lock()
+			renewedIDs[sub.ID]++
+			mu.Unlock()
+			done <- struct{}{}
+		}),
+		RenewerWithErrorHandler(func(sub Subscription, err error) {
+			mu.Lock()
+			errs[sub.ID] = err
+			mu.Unlock()
+			done <- struct{}{}
+		}),
+	)
+	renewer.Add(events)
+	renewer.Add(users)
+	renewer.Add(Subscription{ID: "failing", Resource: "groups", ExpirationDateTime: time.Now()})
+	renewer.Add(Subscription{ID: "deleted", Resource: "groups", ExpirationDateTime: time.Now()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() { result <- renewer.Run(ctx) }()
+	// events and failing are renewed, failing only after its retry, deleted is removed
+	for i := 0; i < 4; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("SubscriptionRenewer did not renew the subscriptions, renewed: %v, errors: %v", renewedIDs, errs)
+		}
+	}
+	cancel()
+	if err := <-result; err != context.Canceled {
+		t.Errorf("SubscriptionRenewer.Run() = %v, want %v", err, context.Canceled)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if renewedIDs[events.ID] != 1 || renewedIDs["failing"] != 1 || renewedIDs[users.ID] != 0 {
+		t.Errorf("SubscriptionRenewer renewed %v, want events and failing once", renewedIDs)
+	}
+	if errs["failing"] == nil || !errors.Is(errs["deleted"], ErrFindSubscription) {
+		t.Errorf("SubscriptionRenewer errors = %v, want a retried error of failing and ErrFindSubscription of deleted", errs)
+	}
+	// a non-positive retry interval is ignored, hence failed renewals do not busy-loop
+	if r := g.NewSubscriptionRenewer(RenewerWithRetryInterval(10*time.Millisecond), RenewerWithRetryInterval(0)); r.retryInterval != 10*time.Millisecond {
+		t.Errorf("RenewerWithRetryInterval(0) retryInterval = %v, want the previous 10ms", r.retryInterval)
+	}
+	if r := g.NewSubscriptionRenewer(RenewerWithRetryInterval(-time.Second)); r.retryInterval != time.Minute {
+		t.Errorf("RenewerWithRetryInterval(-1s) retryInterval = %v, want the default of one minute", r.retryInterval)
+	}
+
+	subs := map[string]Subscription{}
+	for _, sub := range renewer.Subscriptions() {
+		subs[sub.ID] = sub
+	}
+	if _, ok := subs["deleted"]; ok || len(subs) != 3 {
+		t.Errorf("SubscriptionRenewer.Subscriptions() = %v, want all but deleted", subs)
+	}
+	if until := time.Until(subs[events.ID].ExpirationDateTime); until < 4200*time.Minute {
+		t.Errorf("renewed subscription expires in %v, want the maximum lifetime of events", until)
+	}
+	stored, _ := srv.Get("/subscriptions/" + events.ID)
+	if stored["expirationDateTime"] == nil || stored["resource"] != events.Resource {
+		t.Errorf("fake msgraph API subscription = %v, want the renewed subscription", stored)
+	}
+}
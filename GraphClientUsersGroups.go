@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/http"
 )
 
 // ListUsers returns a list of all users
@@ -37,19 +38,52 @@ func (g *GraphClient) ListGroups(opts ...ListQueryOption) (Groups, error) {
 	return marsh.Groups, err
 }
 
+// IterateUsers returns a UserIterator over all users, which loads the users page by page
+// instead of all at once like ListUsers does.
+// Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters
+//
+// Reference: https://developer.microsoft.com/en-us/graph/docs/api-reference/v1.0/api/user_list
+func (g *GraphClient) IterateUsers(opts ...ListQueryOption) *UserIterator {
+	return &UserIterator{pages: g.makePageIterator("/users", compileListQueryOptions(opts))}
+}
+
+// IterateGroups returns a GroupIterator over all groups, which loads the groups page by page
+// instead of all at once like ListGroups does.
+// Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters
+//
+// Reference: https://developer.microsoft.com/en-us/graph/docs/api-reference/v1.0/api/group_list
+func (g *GraphClient) IterateGroups(opts ...ListQueryOption) *GroupIterator {
+	return &GroupIterator{pages: g.makePageIterator("/groups", compileListQueryOptions(opts))}
+}
+
 // GetUser returns the user object associated to the given user identified by either
-// the given ID or userPrincipalName
+// the given ID or userPrincipalName. If the user cannot be found, the returned GraphError
+// wraps ErrFindUser. The identifier "me" returns the signed-in user, see GetMe.
 // Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters
 //
 // Reference: https://developer.microsoft.com/en-us/graph/docs/api-reference/v1.0/api/user_get
 func (g *GraphClient) GetUser(identifier string, opts ...GetQueryOption) (User, error) {
 	resource := fmt.Sprintf("/users/%v", identifier)
+	if identifier == "me" {
+		resource = "/me"
+	}
 	user := User{graphClient: g}
 	err := g.makeGETAPICall(resource, compileGetQueryOptions(opts), &user)
-	return user, err
+	return user, wrapGraphErrorOnStatus(err, http.StatusNotFound, ErrFindUser)
+}
+
+// GetMe returns the signed-in user, hence it requires a delegated TokenProvider, e.g.
+// NewDeviceCodeProvider or NewAuthorizationCodeProvider. The returned User can be used like any
+// other, e.g. to list the calendars of the signed-in user.
+// Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/user-get
+func (g *GraphClient) GetMe(opts ...GetQueryOption) (User, error) {
+	return g.GetUser("me", opts...)
 }
 
-// GetGroup returns the group object identified by the given groupID.
+// GetGroup returns the group object identified by the given groupID. If the group cannot be
+// found, the returned GraphError wraps ErrFindGroup.
 // Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters
 //
 // Reference: https://developer.microsoft.com/en-us/graph/docs/api-reference/v1.0/api/group_get
@@ -57,7 +91,7 @@ func (g *GraphClient) GetGroup(groupID string, opts ...GetQueryOption) (Group, e
 	resource := fmt.Sprintf("/groups/%v", groupID)
 	group := Group{graphClient: g}
 	err := g.makeGETAPICall(resource, compileGetQueryOptions(opts), &group)
-	return group, err
+	return group, wrapGraphErrorOnStatus(err, http.StatusNotFound, ErrFindGroup)
 }
 
 // CreateUser creates a new user given a user object and returns and updated object
@@ -0,0 +1,379 @@
+package msgraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Batch collects multiple sub-requests that are sent to the msgraph API as JSON batch requests,
+// hence with a single round trip per MaxBatchSize sub-requests. Create it with GraphClient.Batch,
+// add sub-requests with Get, Post, Patch and Delete and send them with Execute:
+//
+//	batch := graphClient.Batch()
+//	var user msgraph.User
+//	userItem := batch.Get("/users/"+userID, &user)
+//	var members msgraph.Users
+//	membersItem := batch.Get("/groups/"+groupID+"/members", &members)
+//	err := batch.Execute(ctx)      // error of the batch requests themselves
+//	err = userItem.Err()           // error of the sub-request, e.g. a *GraphError
+//
+// See https://docs.microsoft.com/en-us/graph/json-batching
+type Batch struct {
+	g     *GraphClient
+	items []*BatchItem
+}
+
+// BatchItem is a single sub-request of a Batch. The result is available after Batch.Execute.
+type BatchItem struct {
+	batch     *Batch
+	index     int // the position within batch.items
+	id        string
+	method    string
+	apiCall   string // relative to the APIVersion, e.g. /users/{id}
+	body      interface{}
+	headers   map[string]string
+	dependsOn []*BatchItem
+	v         interface{} // the response body is json-unmarshalled into v, if set
+
+	statusCode int
+	header     http.Header
+	respBody   json.RawMessage
+	err        error
+}
+
+// batchRequest is the body of a JSON batch request
+type batchRequest struct {
+	Requests []batchSubRequest `json:"requests"`
+}
+
+type batchSubRequest struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      json.RawMessage   `json:"body,omitempty"`
+	DependsOn []string          `json:"dependsOn,omitempty"`
+}
+
+// batchResponse is the body of the response to a JSON batch request
+type batchResponse struct {
+	Responses []batchSubResponse `json:"responses"`
+}
+
+type batchSubResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Batch creates a new, empty Batch of sub-requests for this GraphClient.
+func (g *GraphClient) Batch() *Batch {
+	return &Batch{g: g}
+}
+
+// Get adds a GET sub-request for the given apiCall, e.g. /users/{id} or /groups/{id}/members. Query
+// parameters may be part of the apiCall. If v is not nil, the response body is json-unmarshalled
+// into v. Collections can be unmarshalled into a pointer to a slice, e.g. *Users. Only the first
+// page of a collection is returned, the @odata.nextLink is not followed within a Batch.
+func (b *Batch) Get(apiCall string, v interface{}) *BatchItem {
+	return b.add(http.MethodGet, apiCall, nil, v)
+}
+
+// Post adds a POST sub-request for the given apiCall with the json-marshalled body. If v is not
+// nil, the response body is json-unmarshalled into v.
+func (b *Batch) Post(apiCall string, body interface{}, v interface{}) *BatchItem {
+	return b.add(http.MethodPost, apiCall, body, v)
+}
+
+// Patch adds a PATCH sub-request for the given apiCall with the json-marshalled body. If v is not
+// nil, the response body is json-unmarshalled into v.
+func (b *Batch) Patch(apiCall string, body interface{}, v interface{}) *BatchItem {
+	return b.add(http.MethodPatch, apiCall, body, v)
+}
+
+// Delete adds a DELETE sub-request for the given apiCall.
+func (b *Batch) Delete(apiCall string) *BatchItem {
+	return b.add(http.MethodDelete, apiCall, nil, nil)
+}
+
+// add appends a new BatchItem. The ids are unique within the Batch.
+func (b *Batch) add(method, apiCall string, body interface{}, v interface{}) *BatchItem {
+	item := &BatchItem{
+		batch:   b,
+		index:   len(b.items),
+		id:      strconv.Itoa(len(b.items) + 1),
+		method:  method,
+		apiCall: apiCall,
+		body:    body,
+		headers: map[string]string{},
+		v:       v,
+	}
+	if body != nil {
+		item.headers["Content-Type"] = "application/json"
+	}
+	b.items = append(b.items, item)
+	return item
+}
+
+// Len returns the amount of sub-requests within the Batch
+func (b *Batch) Len() int {
+	return len(b.items)
+}
+
+// Items returns all sub-requests of the Batch in the order they have been added
+func (b *Batch) Items() []*BatchItem {
+	return b.items
+}
+
+// DependsOn lets the msgraph API execute this sub-request only after the given sub-requests
+// succeeded. The given items must belong to the same Batch and must have been added before this
+// one. If one of them fails, this sub-request fails with 424 - Failed Dependency.
+func (i *BatchItem) DependsOn(items ...*BatchItem) *BatchItem {
+	i.dependsOn = append(i.dependsOn, items...)
+	return i
+}
+
+// WithHeader adds a header to the sub-request, e.g. ConsistencyLevel: eventual
+func (i *BatchItem) WithHeader(key, value string) *BatchItem {
+	i.headers[key] = value
+	return i
+}
+
+// ID returns the id of the sub-request within the JSON batch request
+func (i *BatchItem) ID() string {
+	return i.id
+}
+
+// StatusCode returns the status code of the response to the sub-request, 0 if it has not been sent
+func (i *BatchItem) StatusCode() int {
+	return i.statusCode
+}
+
+// Header returns the headers of the response to the sub-request
+func (i *BatchItem) Header() http.Header {
+	return i.header
+}
+
+// Body returns the raw body of the response to the sub-request
+func (i *BatchItem) Body() json.RawMessage {
+	return i.respBody
+}
+
+// Err returns the error of the sub-request: a *GraphError if the msgraph API responded with a
+// status code that is not 2xx, or an error if the response body cannot be json-unmarshalled.
+func (i *BatchItem) Err() error {
+	return i.err
+}
+
+// Execute sends all sub-requests of the Batch to the msgraph API. Batches with more than
+// MaxBatchSize sub-requests are split into multiple JSON batch requests, which are sent one after
+// another. Throttled sub-requests are retried according to the RetryPolicy of the GraphClient.
+//
+// The returned error only reports failures of the JSON batch requests themselves, the results of
+// the sub-requests are available with BatchItem.Err, BatchItem.StatusCode and the given v.
+func (b *Batch) Execute(ctx context.Context) error {
+	bodies, err := b.prepare()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(b.items); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(b.items) {
+			end = len(b.items)
+		}
+		if err := b.executeChunk(ctx, b.items[start:end], bodies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare resets the results of all items, validates their dependencies and returns their
+// json-marshalled bodies.
+func (b *Batch) prepare() (map[*BatchItem]json.RawMessage, error) {
+	var bodies = map[*BatchItem]json.RawMessage{}
+	for _, item := range b.items {
+		item.statusCode, item.header, item.respBody, item.err = 0, nil, nil, nil
+		for _, dep := range item.dependsOn {
+			if dep == nil || dep.batch != b || dep.index >= item.index {
+				return nil, fmt.Errorf("batch item %v can only depend on items of the same Batch that have been added before", item.id)
+			}
+		}
+		if item.body == nil {
+			continue
+		}
+		body, err := json.Marshal(item.body)
+		if err != nil {
+			return nil, fmt.Errorf("cannot json-marshal the body of batch item %v: %w", item.id, err)
+		}
+		bodies[item] = body
+	}
+	return bodies, nil
+}
+
+// executeChunk sends the given items, at most MaxBatchSize, as a JSON batch request and retries
+// the throttled ones according to the RetryPolicy of the GraphClient.
+func (b *Batch) executeChunk(ctx context.Context, items []*BatchItem, bodies map[*BatchItem]json.RawMessage) error {
+	var retryPolicy = b.g.getRetryPolicy()
+	for attempt := 1; ; attempt++ {
+		if err := b.send(ctx, items, bodies); err != nil {
+			return err
+		}
+		var delay time.Duration
+		if items, delay = retryableItems(items, retryPolicy, attempt); len(items) == 0 {
+			return nil
+		}
+		b.g.log(LogLevelInfo, "retrying msgraph batch items", "items", len(items), "attempt", attempt+1, "delay", delay)
+		if !sleepContext(ctx, delay) {
+			return nil // the items keep the errors of the last attempt
+		}
+	}
+}
+
+// send performs a single JSON batch request for the given items and sets their results. Items that
+// depend on an item of an earlier JSON batch request which failed are not sent but fail with
+// 424 - Failed Dependency, dependencies on successful ones are dropped.
+func (b *Batch) send(ctx context.Context, items []*BatchItem, bodies map[*BatchItem]json.RawMessage) error {
+	var req batchRequest
+	var pending = map[string]*BatchItem{}
+	for _, item := range items {
+		pending[item.id] = item
+	}
+	for _, item := range items {
+		sub := batchSubRequest{ID: item.id, Method: item.method, URL: item.apiCall, Headers: item.headers, Body: bodies[item]}
+		for _, dep := range item.dependsOn {
+			if pending[dep.id] == dep {
+				sub.DependsOn = append(sub.DependsOn, dep.id)
+			} else if dep.err != nil {
+				item.setFailedDependency(dep)
+				delete(pending, item.id)
+				break
+			}
+		}
+		if pending[item.id] != nil {
+			req.Requests = append(req.Requests, sub)
+		}
+	}
+	if len(req.Requests) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	reqURL, err := b.g.buildAPIURL("", "/$batch")
+	if err != nil {
+		return err
+	}
+	var resp batchResponse
+	if err := b.g.performAPIRequest(ctx, http.MethodPost, reqURL.String(), http.Header{}, bytes.NewReader(data), &resp); err != nil {
+		return err
+	}
+
+	for _, subResp := range resp.Responses {
+		if item := pending[subResp.ID]; item != nil {
+			item.setResponse(b.g, subResp)
+			delete(pending, subResp.ID)
+		}
+	}
+	for _, item := range pending {
+		item.err = fmt.Errorf("no response for batch item %v", item.id)
+	}
+	return nil
+}
+
+// retryableItems returns the items that should be retried after the given attempt and the delay
+// before the retry, hence the throttled items and the ones that failed because they depend on them.
+func retryableItems(items []*BatchItem, retryPolicy RetryPolicy, attempt int) ([]*BatchItem, time.Duration) {
+	var retry []*BatchItem
+	var retrySet = map[*BatchItem]bool{}
+	var maxDelay time.Duration
+	for _, item := range items {
+		if delay, ok := retryPolicy.retryDelay(item.method, attempt, item.err); ok {
+			retry = append(retry, item)
+			retrySet[item] = true
+			if delay > maxDelay {
+				maxDelay = delay
+			}
+			continue
+		}
+		if item.statusCode != http.StatusFailedDependency {
+			continue
+		}
+		for _, dep := range item.dependsOn {
+			if retrySet[dep] {
+				retry = append(retry, item)
+				retrySet[item] = true
+				break
+			}
+		}
+	}
+	return retry, maxDelay
+}
+
+// setResponse sets the result of the item from the given response of the JSON batch request
+func (i *BatchItem) setResponse(g *GraphClient, resp batchSubResponse) {
+	i.statusCode = resp.Status
+	i.header = http.Header{}
+	for key, val := range resp.Headers {
+		i.header.Set(key, val)
+	}
+	i.respBody = resp.Body
+	i.err = nil
+	if resp.Status < 200 || resp.Status > 299 {
+		i.err = newGraphError(resp.Status, i.header, resp.Body)
+		return
+	}
+	if i.v == nil || len(resp.Body) == 0 {
+		return
+	}
+	if i.err = unmarshalBatchBody(resp.Body, i.v); i.err == nil {
+		setGraphClientOf(i.v, g)
+	}
+}
+
+// setFailedDependency lets the item fail with 424 - Failed Dependency because dep failed
+func (i *BatchItem) setFailedDependency(dep *BatchItem) {
+	i.statusCode = http.StatusFailedDependency
+	i.header, i.respBody = nil, nil
+	i.err = &GraphError{
+		StatusCode: http.StatusFailedDependency,
+		Code:       "FailedDependency",
+		Message:    fmt.Sprintf("batch item %v depends on the failed batch item %v", i.id, dep.id),
+	}
+}
+
+// unmarshalBatchBody json-unmarshals the body of a sub-response into v. If v is a pointer to a
+// slice, the items of the collection within the body are unmarshalled.
+func unmarshalBatchBody(body json.RawMessage, v interface{}) error {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice {
+		var page odataPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		return unmarshalItems(page.Value, v)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// setGraphClientOf sets the GraphClient of v if it supports it, e.g. *User or *Users
+func setGraphClientOf(v interface{}, g *GraphClient) {
+	switch t := v.(type) {
+	case *Users:
+		t.setGraphClient(g)
+	case *Groups:
+		t.setGraphClient(g)
+	case *Calendars:
+		t.setGraphClient(g)
+	case interface{ setGraphClient(*GraphClient) }:
+		t.setGraphClient(g)
+	}
+}
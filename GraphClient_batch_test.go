@@ -0,0 +1,257 @@
+package msgraph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchHandler serves /beta/$batch and answers every sub-request with respond. Sub-requests whose
+// dependencies within the same JSON batch request failed are answered with 424 - Failed
+// Dependency. All received JSON batch requests are recorded in requests.
+type batchHandler struct {
+	t        *testing.T
+	respond  func(sub batchSubRequest) batchSubResponse
+	mu       sync.Mutex
+	requests []batchRequest
+}
+
+func (b *batchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/beta/$batch" || r.Method != http.MethodPost {
+		b.t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		b.t.Errorf("cannot decode JSON batch request: %v", err)
+	}
+	b.mu.Lock()
+	b.requests = append(b.requests, req)
+	b.mu.Unlock()
+
+	if len(req.Requests) > MaxBatchSize {
+		b.t.Errorf("JSON batch request contains %v sub-requests, maximum is %v", len(req.Requests), MaxBatchSize)
+	}
+	var resp batchResponse
+	var status = map[string]int{}
+	for _, sub := range req.Requests {
+		var subResp batchSubResponse
+		for _, dep := range sub.DependsOn {
+			if s, ok := status[dep]; !ok {
+				b.t.Errorf("sub-request %v depends on %v, which is not part of the JSON batch request", sub.ID, dep)
+			} else if s > 299 {
+				subResp = batchSubResponse{Status: http.StatusFailedDependency, Body: json.RawMessage(`{"error":{"code":"FailedDependency"}}`)}
+			}
+		}
+		if subResp.Status == 0 {
+			subResp = b.respond(sub)
+		}
+		subResp.ID = sub.ID
+		status[sub.ID] = subResp.Status
+		resp.Responses = append(resp.Responses, subResp)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// sentIDs returns the ids of the sub-requests of the JSON batch request with the given index
+func (b *batchHandler) sentIDs(idx int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var ids []string
+	for _, sub := range b.requests[idx].Requests {
+		ids = append(ids, sub.ID)
+	}
+	return ids
+}
+
+func TestBatch_Execute(t *testing.T) {
+	handler := &batchHandler{t: t, respond: func(sub batchSubRequest) batchSubResponse {
+		switch {
+		case sub.Method == http.MethodGet && sub.URL == "/users/user-1":
+			return batchSubResponse{Status: http.StatusOK, Body: json.RawMessage(`{"id":"user-1","displayName":"User 1"}`)}
+		case sub.Method == http.MethodGet && sub.URL == "/groups/group-1/members":
+			return batchSubResponse{Status: http.StatusOK, Body: json.RawMessage(`{"value":[{"id":"user-1"},{"id":"user-2"}]}`)}
+		case sub.Method == http.MethodPost && sub.URL == "/groups":
+			if sub.Headers["Content-Type"] != "application/json" || !strings.Contains(string(sub.Body), `"displayName":"Group 2"`) {
+				t.Errorf("unexpected POST sub-request: %+v", sub)
+			}
+			return batchSubResponse{Status: http.StatusCreated, Body: json.RawMessage(`{"id":"group-2","displayName":"Group 2"}`)}
+		case sub.Method == http.MethodDelete && sub.URL == "/groups/group-2":
+			return batchSubResponse{Status: http.StatusNoContent}
+		}
+		return batchSubResponse{Status: http.StatusNotFound, Headers: map[string]string{"request-id": "req-" + sub.ID},
+			Body: json.RawMessage(`{"error":{"code":"Request_ResourceNotFound","message":"not found"}}`)}
+	}}
+	g := newTestGraphClient(t, handler)
+
+	batch := g.Batch()
+	var user User
+	userItem := batch.Get("/users/user-1", &user)
+	var members Users
+	membersItem := batch.Get("/groups/group-1/members", &members)
+	var group Group
+	createItem := batch.Post("/groups", map[string]string{"displayName": "Group 2"}, &group)
+	deleteItem := batch.Delete("/groups/group-2").DependsOn(createItem)
+	notFoundItem := batch.Get("/users/unknown", nil)
+	failedDepItem := batch.Patch("/users/unknown", map[string]string{"displayName": "x"}, nil).DependsOn(notFoundItem)
+
+	if err := batch.Execute(context.Background()); err != nil {
+		t.Fatalf("Batch.Execute() error = %v", err)
+	}
+	if len(handler.requests) != 1 {
+		t.Errorf("Batch.Execute() sent %v JSON batch requests, want 1", len(handler.requests))
+	}
+	if userItem.Err() != nil || user.ID != "user-1" || user.DisplayName != "User 1" || user.graphClient != g {
+		t.Errorf("GET /users/user-1: err = %v, user = %v", userItem.Err(), user)
+	}
+	if membersItem.Err() != nil || len(members) != 2 || members[1].ID != "user-2" || members[1].graphClient != g {
+		t.Errorf("GET /groups/group-1/members: err = %v, members = %v", membersItem.Err(), members)
+	}
+	if createItem.Err() != nil || createItem.StatusCode() != http.StatusCreated || group.ID != "group-2" {
+		t.Errorf("POST /groups: err = %v, status = %v, group = %v", createItem.Err(), createItem.StatusCode(), group)
+	}
+	if deleteItem.Err() != nil || deleteItem.StatusCode() != http.StatusNoContent {
+		t.Errorf("DELETE /groups/group-2: err = %v, status = %v", deleteItem.Err(), deleteItem.StatusCode())
+	}
+	var graphErr *GraphError
+	if !errors.As(notFoundItem.Err(), &graphErr) || !IsNotFound(notFoundItem.Err()) ||
+		graphErr.Code != "Request_ResourceNotFound" || graphErr.RequestID != "req-5" {
+		t.Errorf("GET /users/unknown: err = %#v, want a GraphError with status 404", notFoundItem.Err())
+	}
+	if failedDepItem.StatusCode() != http.StatusFailedDependency || failedDepItem.Err() == nil {
+		t.Errorf("PATCH /users/unknown: err = %v, status = %v, want 424", failedDepItem.Err(), failedDepItem.StatusCode())
+	}
+}
+
+func TestBatch_ExecuteChunks(t *testing.T) {
+	handler := &batchHandler{t: t, respond: func(sub batchSubRequest) batchSubResponse {
+		if sub.URL == "/users/unknown" {
+			return batchSubResponse{Status: http.StatusNotFound}
+		}
+		return batchSubResponse{Status: http.StatusOK, Body: json.RawMessage(`{"id":"` + sub.ID + `"}`)}
+	}}
+	g := newTestGraphClient(t, handler)
+
+	batch := g.Batch()
+	var items []*BatchItem
+	for i := 0; i < 2*MaxBatchSize-1; i++ {
+		items = append(items, batch.Get(fmt.Sprintf("/users/user-%v", i), nil))
+	}
+	unknown := batch.Get("/users/unknown", nil) // the last item of the second JSON batch request
+	for i := 0; i < 5; i++ {
+		items = append(items, batch.Get(fmt.Sprintf("/users/user-%v", i+2*MaxBatchSize), nil))
+	}
+	crossChunk := batch.Get("/users/cross-chunk", nil).DependsOn(items[0], items[len(items)-1])
+	failedDep := batch.Get("/users/failed-dependency", nil).DependsOn(unknown)
+
+	if err := batch.Execute(context.Background()); err != nil {
+		t.Fatalf("Batch.Execute() error = %v", err)
+	}
+	if len(handler.requests) != 3 {
+		t.Fatalf("Batch.Execute() sent %v JSON batch requests, want 3", len(handler.requests))
+	}
+	// the dependency on the item of the first JSON batch request is dropped, the one within the same is kept
+	last := handler.requests[2].Requests
+	if crossSub := last[len(last)-1]; crossSub.ID != crossChunk.ID() || len(crossSub.DependsOn) != 1 || crossSub.DependsOn[0] != items[len(items)-1].ID() {
+		t.Errorf("sub-request %+v should only depend on %v", crossSub, items[len(items)-1].ID())
+	}
+	if crossChunk.Err() != nil {
+		t.Errorf("cross chunk dependency: err = %v", crossChunk.Err())
+	}
+	// the dependency failed in the previous JSON batch request, hence it is not sent at all
+	for _, id := range handler.sentIDs(2) {
+		if id == failedDep.ID() {
+			t.Errorf("item %v with a failed dependency has been sent", id)
+		}
+	}
+	if failedDep.StatusCode() != http.StatusFailedDependency || failedDep.Err() == nil {
+		t.Errorf("failed dependency: err = %v, status = %v, want 424", failedDep.Err(), failedDep.StatusCode())
+	}
+	if batch.Len() != len(batch.Items()) || batch.Len() != 2*MaxBatchSize+7 {
+		t.Errorf("Batch.Len() = %v, want %v", batch.Len(), 2*MaxBatchSize+7)
+	}
+}
+
+func TestBatch_ExecuteRetriesThrottledItems(t *testing.T) {
+	var mu sync.Mutex
+	var attempts = map[string]int{}
+	handler := &batchHandler{t: t, respond: func(sub batchSubRequest) batchSubResponse {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[sub.URL]++
+		if sub.URL == "/users/throttled" && attempts[sub.URL] < 3 {
+			return batchSubResponse{Status: http.StatusTooManyRequests, Headers: map[string]string{"Retry-After": "0"}}
+		}
+		if sub.URL == "/users/always-throttled" {
+			return batchSubResponse{Status: http.StatusTooManyRequests}
+		}
+		return batchSubResponse{Status: http.StatusOK, Body: json.RawMessage(`{}`)}
+	}}
+	g := newTestGraphClient(t, handler, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+
+	batch := g.Batch()
+	ok := batch.Get("/users/ok", nil)
+	throttled := batch.Get("/users/throttled", nil)
+	dependent := batch.Patch("/users/dependent", map[string]string{}, nil).DependsOn(throttled)
+	alwaysThrottled := batch.Get("/users/always-throttled", nil)
+
+	if err := batch.Execute(context.Background()); err != nil {
+		t.Fatalf("Batch.Execute() error = %v", err)
+	}
+	if ok.Err() != nil || throttled.Err() != nil || dependent.Err() != nil {
+		t.Errorf("items not successful after retries: %v, %v, %v", ok.Err(), throttled.Err(), dependent.Err())
+	}
+	if !IsThrottled(alwaysThrottled.Err()) {
+		t.Errorf("always throttled item: err = %v, want 429", alwaysThrottled.Err())
+	}
+	want := map[string]int{"/users/ok": 1, "/users/throttled": 3, "/users/dependent": 1, "/users/always-throttled": 3}
+	for url, n := range want {
+		if attempts[url] != n {
+			t.Errorf("%v has been sent %v times, want %v", url, attempts[url], n)
+		}
+	}
+	if len(handler.requests) != 3 || len(handler.sentIDs(1)) != 3 || len(handler.sentIDs(2)) != 3 {
+		t.Errorf("unexpected JSON batch requests: %+v", handler.requests)
+	}
+}
+
+func TestBatch_ExecuteInvalidDependency(t *testing.T) {
+	g := newTestGraphClient(t, &batchHandler{t: t})
+	other := g.Batch().Get("/users/other", nil)
+
+	tests := []struct {
+		name  string
+		batch func() *Batch
+	}{
+		{name: "other Batch", batch: func() *Batch {
+			b := g.Batch()
+			b.Get("/users/user-1", nil).DependsOn(other)
+			return b
+		}},
+		{name: "added later", batch: func() *Batch {
+			b := g.Batch()
+			first := b.Get("/users/user-1", nil)
+			first.DependsOn(b.Get("/users/user-2", nil))
+			return b
+		}},
+		{name: "invalid body", batch: func() *Batch {
+			b := g.Batch()
+			b.Post("/groups", func() {}, nil)
+			return b
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.batch().Execute(context.Background()); err == nil {
+				t.Errorf("Batch.Execute() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package msgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Removed marks an object returned by a delta query that has been removed since the previous delta
+// query, e.g. User.Removed. All other fields except the ID are empty in that case.
+//
+// See https://docs.microsoft.com/en-us/graph/delta-query-overview
+type Removed struct {
+	// Reason is "changed" if the object has been deleted but can still be restored, or "deleted"
+	// if it has been deleted permanently
+	Reason string `json:"reason"`
+}
+
+// makeDeltaAPICall performs a delta query of the collection apiCall, e.g. /users. If deltaLink is
+// empty, all objects are enumerated, otherwise only the ones that changed since the delta query
+// that returned the deltaLink. All pages are loaded by following the @odata.nextLink until the
+// @odata.deltaLink appears, which is returned.
+//
+// The items of all pages are json-unmarshalled into v, which must be a pointer to a slice.
+func (g *GraphClient) makeDeltaAPICall(apiCall string, deltaLink string, reqParams *listQueryOptions, v interface{}) (string, error) {
+	if reqParams.pageSize > 0 { // delta queries do not support $top
+		reqParams.queryHeaders.Add("Prefer", fmt.Sprintf("odata.maxpagesize=%d", reqParams.PageSize()))
+	}
+	reqParams.maxItems = 0 // all pages must be loaded to receive the deltaLink
+
+	var pages = &pageIterator{g: g, reqParams: reqParams, nextLink: deltaLink}
+	if pages.nextLink == "" {
+		pages.nextLink = reqParams.nextLink
+	}
+	if pages.nextLink == "" {
+		reqURL, err := g.buildAPIURL(reqParams.APIVersion(), apiCall+"/delta")
+		if err != nil {
+			return "", err
+		}
+		reqURL.RawQuery = reqParams.Values().Encode() // set query parameters
+		pages.nextLink = reqURL.String()
+	}
+
+	var items = []json.RawMessage{}
+	for page, ok := pages.next(reqParams.Context()); ok; page, ok = pages.next(reqParams.Context()) {
+		items = append(items, page...)
+	}
+	if pages.err != nil {
+		return "", pages.err
+	}
+	if pages.deltaLink == "" {
+		return "", fmt.Errorf("delta query of %v did not return an @odata.deltaLink", apiCall)
+	}
+	return pages.deltaLink, unmarshalItems(items, v)
+}
+
+// ListUsersDelta returns the users that have been added, changed or removed since the delta query
+// that returned the given deltaLink, as well as the deltaLink for the next delta query. If deltaLink
+// is empty, all users are returned. Removed users only contain the ID and User.Removed.
+//
+// The deltaLink is a plain URL and can be saved to resume with the next run. If it is not valid
+// anymore, the returned error satisfies IsResyncRequired and all users must be enumerated again.
+// Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters,
+// which are only applied if deltaLink is empty, because the deltaLink already contains them.
+// ListWithPageSize is passed as odata.maxpagesize preference, ListWithMaxItems is ignored.
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/user-delta
+func (g *GraphClient) ListUsersDelta(deltaLink string, opts ...ListQueryOption) (Users, string, error) {
+	var users Users
+	nextDeltaLink, err := g.makeDeltaAPICall("/users", deltaLink, compileListQueryOptions(opts), &users)
+	users.setGraphClient(g)
+	return users, nextDeltaLink, err
+}
+
+// ListGroupsDelta returns the groups that have been added, changed or removed since the delta query
+// that returned the given deltaLink, as well as the deltaLink for the next delta query. If deltaLink
+// is empty, all groups are returned. Removed groups only contain the ID and Group.Removed.
+//
+// The deltaLink is a plain URL and can be saved to resume with the next run. If it is not valid
+// anymore, the returned error satisfies IsResyncRequired and all groups must be enumerated again.
+// Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters,
+// which are only applied if deltaLink is empty, because the deltaLink already contains them.
+// ListWithPageSize is passed as odata.maxpagesize preference, ListWithMaxItems is ignored.
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/group-delta
+func (g *GraphClient) ListGroupsDelta(deltaLink string, opts ...ListQueryOption) (Groups, string, error) {
+	var groups Groups
+	nextDeltaLink, err := g.makeDeltaAPICall("/groups", deltaLink, compileListQueryOptions(opts), &groups)
+	groups.setGraphClient(g)
+	return groups, nextDeltaLink, err
+}
+
+// IsResyncRequired returns true if err is a GraphError with status code 410 - Gone, hence the
+// deltaLink of a delta query is not valid anymore and a full synchronization must be started
+// with an empty deltaLink.
+func IsResyncRequired(err error) bool {
+	return hasGraphErrorStatus(err, http.StatusGone)
+}
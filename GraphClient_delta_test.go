@@ -0,0 +1,107 @@
+package msgraph
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// deltaHandler serves the delta query of /beta/{collection}/delta. An initial query returns two
+// pages with the given initial items, a query with the $deltatoken "token-1" returns the changes.
+// Every served request is reported to onRequest.
+func deltaHandler(t *testing.T, collection, initial1, initial2, changes string, onRequest func(r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/beta/"+collection+"/delta" {
+			t.Errorf("unexpected request path %v", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if onRequest != nil {
+			onRequest(r)
+		}
+		baseURL := fmt.Sprintf("http://%s/beta/%s/delta", r.Host, collection)
+		query := r.URL.Query()
+		switch {
+		case query.Get("$deltatoken") == "token-1":
+			fmt.Fprintf(w, `{"value":[%s],"@odata.deltaLink":"%s?$deltatoken=token-2"}`, changes, baseURL)
+		case query.Get("$deltatoken") != "":
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprint(w, `{"error":{"code":"resyncRequired","message":"resync required"}}`)
+		case query.Get("$skiptoken") == "page-2":
+			fmt.Fprintf(w, `{"value":[%s],"@odata.deltaLink":"%s?$deltatoken=token-1"}`, initial2, baseURL)
+		default:
+			fmt.Fprintf(w, `{"value":[%s],"@odata.nextLink":"%s?$skiptoken=page-2"}`, initial1, baseURL)
+		}
+	})
+}
+
+func TestGraphClient_ListUsersDelta(t *testing.T) {
+	var requests []*http.Request
+	g := newTestGraphClient(t, deltaHandler(t, "users",
+		`{"id":"1","displayName":"user 1"},{"id":"2","displayName":"user 2"}`,
+		`{"id":"3","displayName":"user 3"}`,
+		`{"id":"2","displayName":"user 2 renamed"},{"id":"3","@removed":{"reason":"deleted"}}`,
+		func(r *http.Request) { requests = append(requests, r) }))
+
+	users, deltaLink, err := g.ListUsersDelta("", ListWithSelect("id,displayName"), ListWithPageSize(2))
+	if err != nil {
+		t.Fatalf("GraphClient.ListUsersDelta() error = %v", err)
+	}
+	if len(users) != 3 || users[2].ID != "3" || users[0].Removed != nil || users[0].graphClient != g {
+		t.Errorf("GraphClient.ListUsersDelta() users = %v, want all 3 users", users)
+	}
+	if !strings.HasSuffix(deltaLink, "$deltatoken=token-1") {
+		t.Errorf("GraphClient.ListUsersDelta() deltaLink = %v, want $deltatoken=token-1", deltaLink)
+	}
+	if len(requests) != 2 || requests[0].URL.Query().Get("$select") != "id,displayName" || requests[0].URL.Query().Get("$top") != "" {
+		t.Errorf("unexpected initial delta query %v", requests[0].URL)
+	}
+	for _, r := range requests {
+		if r.Header.Get("Prefer") != "odata.maxpagesize=2" {
+			t.Errorf("request %v has Prefer header %q, want odata.maxpagesize=2", r.URL, r.Header.Get("Prefer"))
+		}
+	}
+
+	users, deltaLink, err = g.ListUsersDelta(deltaLink)
+	if err != nil {
+		t.Fatalf("GraphClient.ListUsersDelta(deltaLink) error = %v", err)
+	}
+	if len(users) != 2 || users[0].DisplayName != "user 2 renamed" || users[0].Removed != nil {
+		t.Errorf("GraphClient.ListUsersDelta(deltaLink) users = %v, want the changed user 2", users)
+	}
+	if !reflect.DeepEqual(users[1].Removed, &Removed{Reason: "deleted"}) || users[1].ID != "3" {
+		t.Errorf("GraphClient.ListUsersDelta(deltaLink) user = %v, Removed = %v, want the removed user 3", users[1], users[1].Removed)
+	}
+	if !strings.HasSuffix(deltaLink, "$deltatoken=token-2") {
+		t.Errorf("GraphClient.ListUsersDelta(deltaLink) deltaLink = %v, want $deltatoken=token-2", deltaLink)
+	}
+
+	if _, _, err = g.ListUsersDelta(deltaLink); !IsResyncRequired(err) {
+		t.Errorf("GraphClient.ListUsersDelta(expiredDeltaLink) error = %v, want IsResyncRequired", err)
+	}
+}
+
+func TestGraphClient_ListGroupsDelta(t *testing.T) {
+	g := newTestGraphClient(t, deltaHandler(t, "groups",
+		`{"id":"1","displayName":"group 1"}`,
+		`{"id":"2","displayName":"group 2","createdDateTime":"2020-01-02T03:04:05Z"}`,
+		`{"id":"1","@removed":{"reason":"changed"}}`, nil))
+
+	groups, deltaLink, err := g.ListGroupsDelta("")
+	if err != nil {
+		t.Fatalf("GraphClient.ListGroupsDelta() error = %v", err)
+	}
+	if len(groups) != 2 || groups[1].DisplayName != "group 2" || groups[1].CreatedDateTime.Year() != 2020 || groups[1].graphClient != g {
+		t.Errorf("GraphClient.ListGroupsDelta() groups = %v, want both groups", groups)
+	}
+
+	groups, _, err = g.ListGroupsDelta(deltaLink)
+	if err != nil {
+		t.Fatalf("GraphClient.ListGroupsDelta(deltaLink) error = %v", err)
+	}
+	if len(groups) != 1 || groups[0].ID != "1" || !reflect.DeepEqual(groups[0].Removed, &Removed{Reason: "changed"}) {
+		t.Errorf("GraphClient.ListGroupsDelta(deltaLink) groups = %v, want the removed group 1", groups)
+	}
+}
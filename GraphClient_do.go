@@ -0,0 +1,208 @@
+package msgraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DoOption configures a single request of GraphClient.Do and GraphClient.DoStream
+type DoOption func(opts *doOptions)
+
+type doOptions struct {
+	apiVersion string
+	headers    http.Header
+}
+
+var (
+	// DoWithHeader - adds the given header to the request, e.g. the ConsistencyLevel "eventual". The
+	// header replaces a default header with the same key, e.g. Content-Type.
+	DoWithHeader = func(key, value string) DoOption {
+		return func(opts *doOptions) {
+			opts.headers.Add(key, value)
+		}
+	}
+
+	// DoWithAPIVersion - use the given API version instead of the one of the GraphClient, e.g. APIVersionV1
+	DoWithAPIVersion = func(apiVersion string) DoOption {
+		return func(opts *doOptions) {
+			opts.apiVersion = apiVersion
+		}
+	}
+)
+
+// Do performs a request against an arbitrary endpoint of the msgraph API that is not wrapped by
+// this package yet. The request is authenticated, retried and its errors are parsed into a
+// *GraphError just like the ones of the wrapped API-calls:
+//
+//	var devices struct {
+//		Value []struct{ ID, DisplayName string }
+//	}
+//	err := graphClient.Do(ctx, http.MethodGet, "/devices", url.Values{"$top": {"10"}}, nil, &devices)
+//
+// Parameter path is relative to the API version, e.g. "/users/{id}/photo". Absolute URLs, e.g. an
+// @odata.nextLink, are accepted if they point to the Service Root Endpoint of the GraphClient, hence
+// the Token is never sent elsewhere. The query is merged into the query of path and may be nil.
+//
+// Parameter body may be nil to send no content, a []byte, json.RawMessage or io.Reader which are
+// sent as they are, or any other value which is json encoded. The response is json decoded into
+// out unless out is nil or the response has no content, e.g. 204 - No Content.
+func (g *GraphClient) Do(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}, opts ...DoOption) error {
+	reqURL, headers, bodyBytes, err := g.prepareDo(path, query, body, opts)
+	if err != nil {
+		return err
+	}
+	var bodyReader io.Reader
+	if bodyBytes != nil {
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+	return g.performAPIRequest(ctx, method, reqURL, headers, bodyReader, out)
+}
+
+// DoStream performs a request like Do, but returns the *http.Response of a successful request
+// instead of decoding its body, e.g. to stream a file or photo. The caller must close the body of
+// the response, which also frees the slot of WithMaxConcurrency. Responses whose status code is not
+// 2xx are retried or returned as *GraphError like the ones of Do. Unlike Do, the request has no
+// default timeout, hence long downloads are not cut off; use ctx to limit its duration.
+func (g *GraphClient) DoStream(ctx context.Context, method, path string, query url.Values, body interface{}, opts ...DoOption) (*http.Response, error) {
+	reqURL, headers, bodyBytes, err := g.prepareDo(path, query, body, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var retryPolicy = g.getRetryPolicy()
+	for attempt := 1; ; attempt++ {
+		resp, err := g.performStreamAttempt(ctx, method, reqURL, headers, bodyBytes)
+		delay, retry := retryPolicy.retryDelay(method, attempt, err)
+		if !retry {
+			return resp, err
+		}
+		g.log(LogLevelInfo, "retrying msgraph request", "method", method, "url", redactURL(reqURL),
+			"attempt", attempt+1, "delay", delay, "error", err)
+		if !sleepContext(ctx, delay) {
+			return nil, err
+		}
+	}
+}
+
+// performStreamAttempt performs a single attempt of DoStream. The body of a successful response is
+// not read, the slot of WithMaxConcurrency is freed when it is closed.
+func (g *GraphClient) performStreamAttempt(ctx context.Context, method, reqURL string, headers http.Header, bodyBytes []byte) (*http.Response, error) {
+	release, err := g.acquireConcurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := g.newAPIRequest(ctx, method, reqURL, headers, bodyBytes)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	g.logRequest(req)
+	start := time.Now()
+	resp, err := g.storageHTTPClient().Do(req) // without timeout, which would cut off reading the body
+	if err != nil {
+		release()
+		g.log(LogLevelError, "msgraph request failed", "method", req.Method, "url", redactURL(req.URL.String()),
+			"duration", time.Since(start), "error", err)
+		return nil, fmt.Errorf("HTTP response error: %w of http.Request: %v", err, req.URL)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer release()
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		g.logResponse(req, resp, time.Since(start), body)
+		return nil, newGraphError(resp.StatusCode, resp.Header, body)
+	}
+	g.logResponse(req, resp, time.Since(start), nil)
+	resp.Body = &releasingReadCloser{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingReadCloser calls release once the wrapped body is closed
+type releasingReadCloser struct {
+	io.ReadCloser
+	release  func()
+	released bool
+}
+
+func (r *releasingReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	if !r.released {
+		r.released = true
+		r.release()
+	}
+	return err
+}
+
+// prepareDo returns the absolute URL, headers and body of a request of Do and DoStream
+func (g *GraphClient) prepareDo(path string, query url.Values, body interface{}, opts []DoOption) (string, http.Header, []byte, error) {
+	options := doOptions{headers: http.Header{}}
+	for idx := range opts {
+		opts[idx](&options)
+	}
+
+	reqURL, err := g.doURL(options.apiVersion, path)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(query) > 0 {
+		values := reqURL.Query()
+		for key, vals := range query {
+			for idx := range vals {
+				values.Add(key, vals[idx])
+			}
+		}
+		reqURL.RawQuery = values.Encode()
+	}
+
+	var bodyBytes []byte
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		bodyBytes = b
+	case json.RawMessage:
+		bodyBytes = b
+	case io.Reader:
+		if bodyBytes, err = ioutil.ReadAll(b); err != nil {
+			return "", nil, nil, fmt.Errorf("HTTP request body read error: %w", err)
+		}
+	default:
+		if bodyBytes, err = json.Marshal(b); err != nil {
+			return "", nil, nil, fmt.Errorf("cannot encode request body: %w", err)
+		}
+	}
+	if body != nil && bodyBytes == nil {
+		bodyBytes = []byte{}
+	}
+	return reqURL.String(), options.headers, bodyBytes, nil
+}
+
+// doURL returns the absolute URL of path, which is either relative to the API version or an
+// absolute URL of the Service Root Endpoint.
+func (g *GraphClient) doURL(apiVersion, path string) (*url.URL, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return g.parseServiceRootURL(path)
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	rawQuery := ""
+	if idx := strings.Index(path, "?"); idx >= 0 {
+		path, rawQuery = path[:idx], path[idx+1:]
+	}
+	reqURL, err := g.buildAPIURL(apiVersion, path)
+	if err != nil {
+		return nil, err
+	}
+	reqURL.RawQuery = rawQuery
+	return reqURL, nil
+}
@@ -0,0 +1,130 @@
+package msgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGraphClient_Do(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests, bodies = append(requests, r), append(bodies, string(body))
+		switch {
+		case r.URL.Path == "/beta/devices":
+			fmt.Fprint(w, `{"value":[{"id":"device-1"}]}`)
+		case r.URL.Path == "/v1.0/groups/group-1" && r.Method == http.MethodPatch:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/beta/throttled" && len(requests) == 3:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case r.URL.Path == "/beta/throttled":
+			fmt.Fprint(w, `{"id":"retried"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":"Request_ResourceNotFound","message":"not found"}}`)
+		}
+	}))
+	ctx := context.Background()
+
+	var devices struct {
+		Value []struct{ ID string }
+	}
+	err := g.Do(ctx, http.MethodGet, "devices?$select=id", url.Values{"$top": {"1"}}, nil, &devices, DoWithHeader("ConsistencyLevel", "eventual"))
+	if err != nil || len(devices.Value) != 1 || devices.Value[0].ID != "device-1" {
+		t.Fatalf("GraphClient.Do() = %v, %v, want device-1", devices, err)
+	}
+	if query := requests[0].URL.Query(); query.Get("$select") != "id" || query.Get("$top") != "1" {
+		t.Errorf("GraphClient.Do() query = %v, want $select=id and $top=1", query)
+	}
+	if requests[0].Header.Get("ConsistencyLevel") != "eventual" || requests[0].Header.Get("Authorization") == "" {
+		t.Errorf("GraphClient.Do() headers = %v, want ConsistencyLevel and Authorization", requests[0].Header)
+	}
+
+	// body is json encoded, 204 - No Content is not decoded
+	var out map[string]interface{}
+	if err := g.Do(ctx, http.MethodPatch, "/groups/group-1", nil, map[string]string{"displayName": "new"}, &out, DoWithAPIVersion(APIVersionV1)); err != nil || out != nil {
+		t.Errorf("GraphClient.Do(PATCH) = %v, %v, want no error and no content", out, err)
+	}
+	if bodies[1] != `{"displayName":"new"}` || requests[1].Header.Get("Content-Type") != "application/json" {
+		t.Errorf("GraphClient.Do(PATCH) body = %v, want the json encoded body", bodies[1])
+	}
+
+	// throttled requests are retried
+	var retried struct{ ID string }
+	if err := g.Do(ctx, http.MethodGet, "/throttled", nil, nil, &retried, DoWithHeader("Content-Type", "text/plain")); err != nil || retried.ID != "retried" {
+		t.Errorf("GraphClient.Do() of throttled request = %v, %v, want retried", retried, err)
+	}
+	if len(requests) != 4 || requests[3].Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("GraphClient.Do() %v requests, Content-Type = %q, want 4 requests with text/plain", len(requests), requests[3].Header.Get("Content-Type"))
+	}
+
+	if err := g.Do(ctx, http.MethodGet, "/unknown", nil, nil, nil); !IsNotFound(err) {
+		t.Errorf("GraphClient.Do() of unknown path: error = %v, want a GraphError not found", err)
+	}
+	count := len(requests)
+	if err := g.Do(ctx, http.MethodGet, "https://example.com/beta/devices", nil, nil, nil); err == nil || len(requests) != count {
+		t.Errorf("GraphClient.Do() with a foreign URL: error = %v, want an error without request", err)
+	}
+}
+
+func TestGraphClient_DoStream(t *testing.T) {
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/beta/users/user-1/photo/$value" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		fmt.Fprint(w, "jpeg-bytes")
+	}), WithMaxConcurrency(1))
+	ctx := context.Background()
+
+	// the concurrency slot is freed once the body is closed, hence the second call does not block
+	for i := 0; i < 2; i++ {
+		resp, err := g.DoStream(ctx, http.MethodGet, "/users/user-1/photo/$value", nil, nil)
+		if err != nil {
+			t.Fatalf("GraphClient.DoStream() error = %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "jpeg-bytes" || resp.Header.Get("Content-Type") != "image/jpeg" {
+			t.Errorf("GraphClient.DoStream() body = %q, want jpeg-bytes", body)
+		}
+	}
+
+	resp, err := g.DoStream(ctx, http.MethodGet, "/users/unknown/photo/$value", nil, json.RawMessage(`{}`))
+	if resp != nil || !IsNotFound(err) {
+		t.Errorf("GraphClient.DoStream() of unknown path = %v, %v, want a GraphError not found", resp, err)
+	}
+}
+
+func TestGraphClient_DoStreamSlowBody(t *testing.T) {
+	timeout := defaultHTTPTimeout
+	defaultHTTPTimeout = 100 * time.Millisecond
+	defer func() { defaultHTTPTimeout = timeout }()
+
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "first-chunk ")
+		w.(http.Flusher).Flush()
+		time.Sleep(3 * defaultHTTPTimeout)
+		fmt.Fprint(w, "last-chunk")
+	}))
+
+	// the body takes longer than the timeout of API-calls, only the context limits DoStream
+	resp, err := g.DoStream(context.Background(), http.MethodGet, "/drives/drive-1/items/item-1/content", nil, nil)
+	if err != nil {
+		t.Fatalf("GraphClient.DoStream() error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || string(body) != "first-chunk last-chunk" {
+		t.Errorf("GraphClient.DoStream() body = %q, error = %v, want the complete body", body, err)
+	}
+}
@@ -0,0 +1,136 @@
+package msgraph
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// LogLevel represents the severity of a message logged by a GraphClient
+type LogLevel int
+
+const (
+	// LogLevelDebug is used for every request and response, including the bodies if enabled with WithBodyLogging
+	LogLevelDebug LogLevel = iota
+	// LogLevelInfo is used for noteworthy events, e.g. retries of throttled requests
+	LogLevelInfo
+	// LogLevelWarn is used for responses with a status code that is not 2xx
+	LogLevelWarn
+	// LogLevelError is used for requests that failed without a response, e.g. on network errors
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// Logger receives the messages logged by a GraphClient. keysAndValues contains alternating keys
+// and values with the request and response metadata, e.g. "method", "GET", "status", 200. This
+// makes it easy to adapt structured loggers like *slog.Logger.
+//
+// Secrets are never passed to the Logger: tokens, client secrets, passwords and the signature of
+// SAS URIs are redacted.
+type Logger interface {
+	Log(level LogLevel, msg string, keysAndValues ...interface{})
+}
+
+// LoggerFunc is an adapter to allow the use of ordinary functions as Logger
+type LoggerFunc func(level LogLevel, msg string, keysAndValues ...interface{})
+
+// Log calls f(level, msg, keysAndValues...)
+func (f LoggerFunc) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	f(level, msg, keysAndValues...)
+}
+
+// NewStdLogger returns a Logger that prints all messages with at least minLevel to the given
+// *log.Logger, e.g.: WARN msgraph response method="GET" status="404"
+func NewStdLogger(logger *log.Logger, minLevel LogLevel) Logger {
+	return LoggerFunc(func(level LogLevel, msg string, keysAndValues ...interface{}) {
+		if level < minLevel {
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString(level.String() + " " + msg)
+		for i := 0; i+1 < len(keysAndValues); i += 2 {
+			fmt.Fprintf(&sb, " %v=%q", keysAndValues[i], fmt.Sprint(keysAndValues[i+1]))
+		}
+		logger.Print(sb.String())
+	})
+}
+
+var (
+	// WithLogger - log the metadata of every request and response to the given Logger, e.g. method,
+	// url, status, duration and request-id. By default a GraphClient does not log anything.
+	WithLogger = func(logger Logger) GraphClientOption {
+		return func(g *GraphClient) {
+			g.logger = logger
+		}
+	}
+
+	// WithBodyLogging - additionally log the request and response bodies with LogLevelDebug.
+	// Secrets within the bodies are redacted. Only has an effect if a Logger is set with WithLogger.
+	WithBodyLogging = func() GraphClientOption {
+		return func(g *GraphClient) {
+			g.logBodies = true
+		}
+	}
+)
+
+// log passes the message to the Logger of the GraphClient, if any
+func (g *GraphClient) log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	if g.logger != nil {
+		g.logger.Log(level, msg, keysAndValues...)
+	}
+}
+
+const redacted = "REDACTED"
+
+// sensitiveParams are the names of query parameters and form fields whose values are redacted
+var sensitiveParams = []string{"sig", "access_token", "refresh_token", "id_token", "client_secret", "client_assertion", "assertion", "code", "device_code", "user_code", "password"}
+
+// sensitiveJSONProperties are the names of json properties whose values are redacted. Unlike
+// sensitiveParams, "code" is not redacted, hence the code of OData errors is logged.
+var sensitiveJSONProperties = []string{"access_token", "refresh_token", "id_token", "client_secret", "client_assertion", "assertion", "device_code", "user_code", "password"}
+
+var (
+	redactJSONRegexp = regexp.MustCompile(`("(?:` + strings.Join(sensitiveJSONProperties, "|") + `)"\s*:\s*")(?:[^"\\]|\\.)*(")`)
+	redactFormRegexp = regexp.MustCompile(`((?:^|&)(?:` + strings.Join(sensitiveParams, "|") + `)=)[^&"\s]*`)
+	redactSASRegexp  = regexp.MustCompile(`([?&]sig=)[^&"\s]*`)
+)
+
+// redactURL returns rawURL with the values of all sensitive query parameters redacted, e.g. the
+// signature of a SAS URI
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redactSASRegexp.ReplaceAllString(rawURL, "${1}"+redacted)
+	}
+	query := u.Query()
+	for _, param := range sensitiveParams {
+		if query.Get(param) != "" {
+			query.Set(param, redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactBody returns the json or form encoded body with the values of all sensitive properties
+// redacted, including the signatures of SAS URIs within the body
+func redactBody(body []byte) string {
+	s := redactJSONRegexp.ReplaceAllString(string(body), "${1}"+redacted+"${2}")
+	s = redactFormRegexp.ReplaceAllString(s, "${1}"+redacted)
+	return redactSASRegexp.ReplaceAllString(s, "${1}"+redacted)
+}
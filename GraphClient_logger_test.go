@@ -0,0 +1,177 @@
+package msgraph
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logEntry is a single message received by a recordingLogger
+type logEntry struct {
+	level         LogLevel
+	msg           string
+	keysAndValues map[string]interface{}
+}
+
+// recordingLogger records all messages passed to it
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (r *recordingLogger) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	entry := logEntry{level: level, msg: msg, keysAndValues: map[string]interface{}{}}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		entry.keysAndValues[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "SAS URI",
+			url:  "https://storage.blob.core.windows.net/container/file?sv=2019-02-02&sig=c2VjcmV0&se=2021",
+			want: "https://storage.blob.core.windows.net/container/file?se=2021&sig=REDACTED&sv=2019-02-02",
+		}, {
+			name: "no sensitive parameters",
+			url:  "https://graph.microsoft.com/beta/users?%24top=999",
+			want: "https://graph.microsoft.com/beta/users?%24top=999",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactURL(tt.url); got != tt.want {
+				t.Errorf("redactURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "token response",
+			body: `{"token_type":"Bearer","access_token":"eyJ0eXAi\"secret","refresh_token": "secret"}`,
+			want: `{"token_type":"Bearer","access_token":"REDACTED","refresh_token": "REDACTED"}`,
+		}, {
+			name: "token request",
+			body: `grant_type=client_credentials&client_id=app&client_secret=secret&resource=https%3A%2F%2Fgraph.microsoft.com`,
+			want: `grant_type=client_credentials&client_id=app&client_secret=REDACTED&resource=https%3A%2F%2Fgraph.microsoft.com`,
+		}, {
+			name: "SAS URI and password in json",
+			body: `{"azureStorageUri":"https://storage/file?sv=2019&sig=secret","passwordProfile":{"password":"secret"}}`,
+			want: `{"azureStorageUri":"https://storage/file?sv=2019&sig=REDACTED","passwordProfile":{"password":"REDACTED"}}`,
+		}, {
+			name: "device code response",
+			body: `{"device_code":"secret","user_code":"ABC-123","verification_uri":"https://microsoft.com/devicelogin","expires_in":900}`,
+			want: `{"device_code":"REDACTED","user_code":"REDACTED","verification_uri":"https://microsoft.com/devicelogin","expires_in":900}`,
+		}, {
+			name: "device code polling",
+			body: `grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Adevice_code&client_id=app&device_code=secret`,
+			want: `grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Adevice_code&client_id=app&device_code=REDACTED`,
+		}, {
+			name: "authorization code request",
+			body: `grant_type=authorization_code&client_id=app&code=secret&code_verifier=verifier`,
+			want: `grant_type=authorization_code&client_id=app&code=REDACTED&code_verifier=verifier`,
+		}, {
+			name: "OData error",
+			body: `{"error":{"code":"Request_ResourceNotFound","message":"Resource 'x' does not exist."}}`,
+			want: `{"error":{"code":"Request_ResourceNotFound","message":"Resource 'x' does not exist."}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactBody([]byte(tt.body)); got != tt.want {
+				t.Errorf("redactBody() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("request-id", "req-id")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		fmt.Fprint(w, `{"id": "user-id"}`)
+	}), WithLogger(logger), WithBodyLogging())
+
+	if _, err := g.GetUser("user-id"); err != nil {
+		t.Fatalf("GraphClient.GetUser() error = %v", err)
+	}
+	if _, err := g.CreateUser(User{PasswordProfile: PasswordProfile{Password: "very-secret"}}); err == nil {
+		t.Fatalf("GraphClient.CreateUser() error = nil, want an error")
+	}
+
+	var responses []logEntry
+	for _, entry := range logger.entries {
+		if body := fmt.Sprint(entry.keysAndValues["body"]); strings.Contains(body, "very-secret") || strings.Contains(body, "test-access-token") {
+			t.Errorf("secret has not been redacted: %v", entry)
+		}
+		if entry.msg == "msgraph response" && strings.Contains(fmt.Sprint(entry.keysAndValues["url"]), "/beta/users") {
+			responses = append(responses, entry)
+		}
+	}
+	if len(responses) != 2 {
+		t.Fatalf("logged %d API responses, want 2: %v", len(responses), logger.entries)
+	}
+	if get := responses[0]; get.level != LogLevelDebug || get.keysAndValues["method"] != http.MethodGet ||
+		get.keysAndValues["status"] != http.StatusOK || get.keysAndValues["request-id"] != "req-id" || get.keysAndValues["body"] != `{"id": "user-id"}` {
+		t.Errorf("unexpected log entry for GET: %v", get)
+	}
+	if post := responses[1]; post.level != LogLevelWarn || post.keysAndValues["status"] != http.StatusBadRequest {
+		t.Errorf("unexpected log entry for failed POST: %v", post)
+	}
+}
+
+func TestNewStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0), LogLevelInfo)
+	logger.Log(LogLevelDebug, "filtered")
+	logger.Log(LogLevelWarn, "msgraph response", "method", "GET", "status", 404)
+	if got, want := buf.String(), "WARN msgraph response method=\"GET\" status=\"404\"\n"; got != want {
+		t.Errorf("NewStdLogger() logged %q, want %q", got, want)
+	}
+}
+
+func TestGraphClient_logResponseDeviceCodePolling(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want LogLevel
+	}{
+		{name: "authorization_pending", body: `{"error":"authorization_pending","error_description":"pending"}`, want: LogLevelDebug},
+		{name: "slow_down", body: `{"error":"slow_down","error_description":"slow down"}`, want: LogLevelDebug},
+		{name: "expired_token", body: `{"error":"expired_token","error_description":"expired"}`, want: LogLevelWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			g := &GraphClient{logger: logger}
+			req := httptest.NewRequest(http.MethodPost, "https://login.microsoftonline.com/tenant/oauth2/v2.0/token", nil)
+			g.logResponse(req, &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{}}, time.Millisecond, []byte(tt.body))
+			if len(logger.entries) != 1 || logger.entries[0].level != tt.want {
+				t.Errorf("logResponse() logged %v, want one entry with level %v", logger.entries, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package msgraph
+
+import (
+	"net/http"
+	"time"
+)
+
+// defaultHTTPTimeout is the timeout of the default http.Client used for token and msgraph API
+// requests. It is a variable, hence tests can shorten it.
+var defaultHTTPTimeout = time.Second * 10
+
+// GraphClientOption configures optional settings of a GraphClient, see e.g. NewGraphClient
+type GraphClientOption func(g *GraphClient)
+
+var (
+	// WithHTTPClient - use the given http.Client for all requests of the GraphClient, hence for
+	// token requests, msgraph API-calls and Azure storage uploads. The timeout of the http.Client
+	// applies to all of them, keep large intunewin uploads in mind when setting it.
+	WithHTTPClient = func(httpClient *http.Client) GraphClientOption {
+		return func(g *GraphClient) {
+			g.httpClient = httpClient
+		}
+	}
+
+	// WithRoundTripper - use the given http.RoundTripper for all requests of the GraphClient, e.g.
+	// a http.Transport with a corporate proxy or a custom CA pool. The default timeouts are kept:
+	// 10 seconds for token requests and msgraph API-calls, none for Azure storage uploads.
+	WithRoundTripper = func(transport http.RoundTripper) GraphClientOption {
+		return func(g *GraphClient) {
+			g.transport = transport
+		}
+	}
+
+	// WithMaxConcurrency - limit the amount of API-calls of the GraphClient that are performed
+	// concurrently, e.g. to avoid throttling when fanning out over many goroutines. Further
+	// API-calls wait until a slot is free or their context is done. Unlimited if maxConcurrency <= 0.
+	WithMaxConcurrency = func(maxConcurrency int) GraphClientOption {
+		return func(g *GraphClient) {
+			if maxConcurrency <= 0 {
+				g.concurrency = nil
+				return
+			}
+			g.concurrency = make(chan struct{}, maxConcurrency)
+		}
+	}
+
+	// WithAPIVersion - perform all API-calls of the GraphClient with the given msgraph API version,
+	// APIVersionV1 or APIVersionBeta. Defaults to APIVersion. Single API-calls can use another
+	// version with GetWithAPIVersion, ListWithAPIVersion etc.
+	WithAPIVersion = func(apiVersion string) GraphClientOption {
+		return func(g *GraphClient) {
+			g.apiVersion = apiVersion
+		}
+	}
+)
+
+// graphHTTPClient returns the http.Client used for token requests and msgraph API-calls.
+func (g *GraphClient) graphHTTPClient() *http.Client {
+	if g.httpClient != nil {
+		return g.httpClient
+	}
+	return &http.Client{Transport: g.transport, Timeout: defaultHTTPTimeout}
+}
+
+// storageHTTPClient returns the http.Client used for uploads to the Azure storage and the
+// responses of DoStream, which must not time out because the uploaded or streamed files may be
+// several GB in size. The context of the request limits its duration instead.
+func (g *GraphClient) storageHTTPClient() *http.Client {
+	if g.httpClient != nil {
+		return g.httpClient
+	}
+	return &http.Client{Transport: g.transport}
+}
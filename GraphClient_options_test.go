@@ -0,0 +1,325 @@
+package msgraph
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jbvmio/go-msgraph/msgraphtest"
+)
+
+// countingRoundTripper counts all requests per path before passing them to http.DefaultTransport
+type countingRoundTripper struct {
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (c *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests[req.URL.Path]++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWithRoundTripper(t *testing.T) {
+	transport := &countingRoundTripper{requests: map[string]int{}}
+	g := newTestGraphClient(t, pagedUsersHandler(t, 1, nil), WithRoundTripper(transport))
+	if _, err := g.ListUsers(); err != nil {
+		t.Fatalf("GraphClient.ListUsers() error = %v", err)
+	}
+	if transport.requests["/test-tenant/oauth2/token"] != 1 || transport.requests["/beta/users"] != 1 {
+		t.Errorf("http.RoundTripper was not used for all requests, requests: %v", transport.requests)
+	}
+	if c := g.graphHTTPClient(); c.Transport != transport || c.Timeout != defaultHTTPTimeout {
+		t.Errorf("graphHTTPClient() = %+v, want transport %v with timeout %v", c, transport, defaultHTTPTimeout)
+	}
+	if c := g.storageHTTPClient(); c.Transport != transport || c.Timeout != 0 {
+		t.Errorf("storageHTTPClient() = %+v, want transport %v without timeout", c, transport)
+	}
+}
+
+func TestWithRoundTripper_recorder(t *testing.T) {
+	srv := msgraphtest.NewServer()
+	for _, name := range []string{"Alice", "Bob"} {
+		srv.Add("/users", msgraphtest.Object{"displayName": name, "userPrincipalName": strings.ToLower(name) + "@contoso.com", "department": "Engineering"})
+	}
+	cassette := filepath.Join(t.TempDir(), "users.json")
+
+	listUsers := func(mode msgraphtest.RecorderMode) []string {
+		rec, err := msgraphtest.NewRecorder(cassette, mode)
+		if err != nil {
+			t.Fatalf("NewRecorder() error = %v", err)
+		}
+		g, err := NewGraphClientWithCustomEndpoint(srv.TenantID, srv.ApplicationID, srv.ClientSecret, srv.URL, srv.URL, WithRoundTripper(rec))
+		if err != nil {
+			t.Fatalf("NewGraphClientWithCustomEndpoint() error = %v", err)
+		}
+		var got []string
+		for _, opts := range [][]ListQueryOption{
+			{ListWithFilter("displayName eq 'Alice'"), ListWithSelect("displayName")},
+			{ListWithFilter("displayName eq 'Bob'"), ListWithSelect("displayName")},
+			{ListWithFilter("displayName eq 'Bob'"), ListWithSelect("displayName,department")},
+		} {
+			users, err := g.ListUsers(opts...)
+			if err != nil || len(users) != 1 {
+				t.Fatalf("ListUsers() = %v, error = %v, want one user", users, err)
+			}
+			got = append(got, users[0].DisplayName+"|"+users[0].Department)
+		}
+		if err := rec.Save(); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return got
+	}
+
+	recorded := listUsers(msgraphtest.ModeRecord)
+	srv.Close()
+	replayed := listUsers(msgraphtest.ModeReplay)
+	want := []string{"Alice|", "Bob|", "Bob|Engineering"}
+	if strings.Join(recorded, ",") != strings.Join(want, ",") || strings.Join(replayed, ",") != strings.Join(want, ",") {
+		t.Errorf("ListUsers() recorded = %v, replayed = %v, want %v", recorded, replayed, want)
+	}
+}
+
+func TestWithRoundTripper_recorderCreateUser(t *testing.T) {
+	srv := msgraphtest.NewServer()
+	defer srv.Close()
+	cassette := filepath.Join(t.TempDir(), "create-user.json")
+	rec, err := msgraphtest.NewRecorder(cassette, msgraphtest.ModeRecord)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	g, err := NewGraphClientWithCustomEndpoint(srv.TenantID, srv.ApplicationID, srv.ClientSecret, srv.URL, srv.URL, WithRoundTripper(rec))
+	if err != nil {
+		t.Fatalf("NewGraphClientWithCustomEndpoint() error = %v", err)
+	}
+	user, err := g.CreateUser(User{DisplayName: "Alice", UserPrincipalName: "alice@contoso.com", MailNickname: "alice", AccountEnabled: true,
+		PasswordProfile: PasswordProfile{ForceChangePasswordNextSignIn: true, Password: "In1tial-Pa55word"}})
+	if err != nil || user.ID == "" {
+		t.Fatalf("CreateUser() = %v, error = %v", user, err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := ioutil.ReadFile(cassette)
+	if err != nil {
+		t.Fatalf("cannot read cassette: %v", err)
+	}
+	if strings.Contains(string(data), "In1tial-Pa55word") || !strings.Contains(string(data), `\"password\":\"`+msgraphtest.Redacted+`\"`) {
+		t.Errorf("cassette does not redact the password of CreateUser:\n%s", data)
+	}
+	if !strings.Contains(string(data), "alice@contoso.com") {
+		t.Errorf("cassette does not contain the other properties of CreateUser:\n%s", data)
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	transport := &countingRoundTripper{requests: map[string]int{}}
+	httpClient := &http.Client{Transport: transport}
+	g := newTestGraphClient(t, pagedUsersHandler(t, 1, nil), WithHTTPClient(httpClient))
+	if _, err := g.ListUsers(); err != nil {
+		t.Fatalf("GraphClient.ListUsers() error = %v", err)
+	}
+	if transport.requests["/test-tenant/oauth2/token"] != 1 || transport.requests["/beta/users"] != 1 {
+		t.Errorf("http.Client was not used for all requests, requests: %v", transport.requests)
+	}
+	if g.graphHTTPClient() != httpClient || g.storageHTTPClient() != httpClient {
+		t.Errorf("the given http.Client is not used for msgraph API-calls and storage uploads")
+	}
+}
+
+func TestWin32LobAppUploadFinalizeWithHTTPClient(t *testing.T) {
+	transport := &countingRoundTripper{requests: map[string]int{}}
+	var gotBody string
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(body)
+	}), WithRoundTripper(transport))
+
+	storageURI := g.serviceRootEndpoint + "/blob/file.intunewin?sv=2019&sig=secret"
+	if err := g.win32LobAppUploadFinalize("file", storageURI, []string{"MDAwMAo="}); err != nil {
+		t.Fatalf("win32LobAppUploadFinalize() error = %v", err)
+	}
+	if transport.requests["/blob/file.intunewin"] != 1 {
+		t.Errorf("storage upload did not use the http.RoundTripper, requests: %v", transport.requests)
+	}
+	if !strings.Contains(gotBody, "<Latest>MDAwMAo=</Latest>") {
+		t.Errorf("block list %q does not contain the block ID", gotBody)
+	}
+}
+
+// blockingHandler responds to every request with an empty collection once release is closed and
+// tracks the maximum amount of requests that were in flight at the same time
+type blockingHandler struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	started     chan struct{}
+	release     chan struct{}
+}
+
+func (b *blockingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	b.inFlight++
+	if b.inFlight > b.maxInFlight {
+		b.maxInFlight = b.inFlight
+	}
+	b.mu.Unlock()
+	b.started <- struct{}{}
+	<-b.release
+	b.mu.Lock()
+	b.inFlight--
+	b.mu.Unlock()
+	w.Write([]byte(`{"value":[]}`))
+}
+
+func TestGraphClient_ConcurrentAPICalls(t *testing.T) {
+	const numCalls = 10
+	tests := []struct {
+		name            string
+		opts            []GraphClientOption
+		wantMaxInFlight int
+	}{
+		{name: "unlimited", wantMaxInFlight: numCalls},
+		{name: "WithMaxConcurrency(3)", opts: []GraphClientOption{WithMaxConcurrency(3)}, wantMaxInFlight: 3},
+		{name: "WithMaxConcurrency(0)", opts: []GraphClientOption{WithMaxConcurrency(0)}, wantMaxInFlight: numCalls},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &blockingHandler{started: make(chan struct{}, numCalls), release: make(chan struct{})}
+			g := newTestGraphClient(t, handler, tt.opts...)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, numCalls)
+			for i := 0; i < numCalls; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := g.ListUsers()
+					errs <- err
+				}()
+			}
+			// wait until the expected amount of requests is in flight, they would block forever if serialized
+			for i := 0; i < tt.wantMaxInFlight; i++ {
+				select {
+				case <-handler.started:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("only %v of %v API-calls were started concurrently", i, tt.wantMaxInFlight)
+				}
+			}
+			close(handler.release)
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Errorf("GraphClient.ListUsers() error = %v", err)
+				}
+			}
+			if handler.maxInFlight != tt.wantMaxInFlight {
+				t.Errorf("maximum concurrent API-calls = %v, want %v", handler.maxInFlight, tt.wantMaxInFlight)
+			}
+		})
+	}
+}
+
+func TestGraphClient_ConcurrentDefaultEndpoints(t *testing.T) {
+	provider := TokenProviderFunc(func(ctx context.Context) (Token, error) {
+		return Token{TokenType: "Bearer", ExpiresOn: time.Now().Add(time.Hour), AccessToken: "func-token"}, nil
+	})
+	// the endpoints are resolved when the GraphClient is created, not by the concurrent API-calls
+	g, err := NewGraphClientWithCustomEndpoint("", "", "", "", "", WithTokenProvider(provider))
+	if err != nil {
+		t.Fatalf("NewGraphClientWithCustomEndpoint() error = %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if u, err := g.buildAPIURL("", "/users"); err != nil || u.String() != ServiceRootEndpointGlobal+"/beta/users" {
+				t.Errorf("buildAPIURL() = %v, error = %v", u, err)
+			}
+			if u, err := g.oauth2URL("tenant", "token"); err != nil || !strings.HasPrefix(u, AzureADAuthEndpointGlobal+"/tenant/") {
+				t.Errorf("oauth2URL() = %v, error = %v", u, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGraphClient_ConcurrentTokenRefresh(t *testing.T) {
+	const numCalls = 10
+	transport := &countingRoundTripper{requests: map[string]int{}}
+	g := newTestGraphClient(t, pagedUsersHandler(t, 1, nil), WithRoundTripper(transport))
+
+	g.tokenMu.Lock()
+	g.token = Token{} // expire the token, all following API-calls want to refresh it
+	g.tokenMu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < numCalls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := g.ListUsers(); err != nil {
+				t.Errorf("GraphClient.ListUsers() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	// one token request of NewGraphClientWithCustomEndpoint, one single refresh for all API-calls
+	if transport.requests["/test-tenant/oauth2/token"] != 2 || transport.requests["/beta/users"] != numCalls {
+		t.Errorf("the token was not refreshed exactly once, requests: %v", transport.requests)
+	}
+	if !strings.Contains(g.String(), "Token validity") {
+		t.Errorf("GraphClient.String() = %v", g.String())
+	}
+}
+
+func TestGraphClient_WithAPIVersion(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/users"):
+			// v1.0 does not return all properties of beta, e.g. for groups
+			w.Write([]byte(`{"value":[{"id":"user-1"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/$batch"):
+			w.Write([]byte(`{"responses":[{"id":"1","status":200,"body":{"id":"group-1"}}]}`))
+		default:
+			w.Write([]byte(`{"id":"group-1","displayName":"Group 1"}`))
+		}
+	})
+	g := newTestGraphClient(t, handler, WithAPIVersion(APIVersionV1))
+
+	if _, err := g.ListUsers(); err != nil {
+		t.Errorf("GraphClient.ListUsers() error = %v", err)
+	}
+	if _, err := g.ListUsers(ListWithAPIVersion(APIVersionBeta)); err != nil {
+		t.Errorf("GraphClient.ListUsers(beta) error = %v", err)
+	}
+	if _, err := g.GetGroup("group-1", GetWithAPIVersion(APIVersionBeta)); err != nil {
+		t.Errorf("GraphClient.GetGroup(beta) error = %v", err)
+	}
+	if _, err := g.GetGroup("group-1"); err != nil {
+		t.Errorf("GraphClient.GetGroup() error = %v", err)
+	}
+	batch := g.Batch()
+	batch.Get("/groups/group-1", nil)
+	if err := batch.Execute(context.Background()); err != nil {
+		t.Errorf("Batch.Execute() error = %v", err)
+	}
+
+	want := []string{"/v1.0/users", "/beta/users", "/beta/groups/group-1", "/v1.0/groups/group-1", "/v1.0/$batch"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("requested paths = %v, want %v", paths, want)
+	}
+}
@@ -0,0 +1,124 @@
+package msgraph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// odataPage represents a single page of a collection as returned by the msgraph API. If the
+// collection is larger than the page, NextLink contains the absolute URL of the following page.
+//
+// See https://docs.microsoft.com/en-us/graph/paging
+type odataPage struct {
+	Value     []json.RawMessage `json:"value"`
+	NextLink  string            `json:"@odata.nextLink,omitempty"`
+	DeltaLink string            `json:"@odata.deltaLink,omitempty"` // only set on the last page of a delta query
+	Count     *int              `json:"@odata.count,omitempty"`     // only set on the first page if requested with $count
+}
+
+// pageIterator loads a collection page by page by following the @odata.nextLink. It is the
+// foundation for all List funcs as well as for the exported iterators, e.g. UserIterator.
+// Links that do not belong to the Service Root Endpoint are not followed.
+type pageIterator struct {
+	g         *GraphClient
+	reqParams *listQueryOptions
+	nextLink  string // absolute URL of the next page, empty if all pages have been loaded
+	deltaLink string // the @odata.deltaLink of the last page of a delta query
+	numItems  int    // amount of items loaded so far, used for ListWithMaxItems
+	err       error  // the first error that occurred, stops the iteration
+}
+
+// newPageIterator creates a pageIterator for the collection at reqURL. If reqParams contain
+// a nextLink set by ListWithNextLink, the iteration resumes at that page instead.
+func (g *GraphClient) newPageIterator(reqURL *url.URL, reqParams *listQueryOptions) *pageIterator {
+	var it = &pageIterator{g: g, reqParams: reqParams, nextLink: reqParams.nextLink}
+	if it.nextLink == "" {
+		var getParams = reqParams.Values()
+		if getParams.Get(odataTopParamKey) == "" { // keep $top if set by ListWithTop
+			getParams.Set(odataTopParamKey, strconv.Itoa(reqParams.PageSize()))
+		}
+		reqURL.RawQuery = getParams.Encode() // set query parameters
+		it.nextLink = reqURL.String()
+	}
+	return it
+}
+
+// next loads the next page and returns its items. Returns false if all pages have been loaded,
+// the maximum amount of items set by ListWithMaxItems is reached, or an error occurred. The
+// error is kept in p.err. The given ctx is checked before and used for the API-call.
+func (p *pageIterator) next(ctx context.Context) ([]json.RawMessage, bool) {
+	if p.err != nil || p.nextLink == "" {
+		return nil, false
+	}
+	if p.err = ctx.Err(); p.err != nil {
+		return nil, false
+	}
+	// the nextLink may come from the response or the caller, e.g. a persisted deltaLink
+	if _, p.err = p.g.parseServiceRootURL(p.nextLink); p.err != nil {
+		return nil, false
+	}
+	var page odataPage
+	if p.err = p.g.performAPIRequest(ctx, http.MethodGet, p.nextLink, p.reqParams.Headers(), nil, &page); p.err != nil {
+		return nil, false
+	}
+	p.nextLink, p.deltaLink = page.NextLink, page.DeltaLink
+	if page.Count != nil && p.reqParams.count != nil {
+		*p.reqParams.count = *page.Count
+	}
+	if maxItems := p.reqParams.maxItems; maxItems > 0 && p.numItems+len(page.Value) >= maxItems {
+		page.Value = page.Value[:maxItems-p.numItems]
+		p.nextLink = "" // the maximum is reached, do not load any further pages
+	}
+	p.numItems += len(page.Value)
+	return page.Value, true
+}
+
+// makePagedAPICall performs a GET API-Call for a collection and follows the @odata.nextLink of
+// every page until the collection is exhausted or the maximum amount of items set by
+// ListWithMaxItems is reached. The context of reqParams is checked between the pages, hence a
+// cancelled context stops the paging and returns the error of the context.
+//
+// All collected items are json-unmarshalled into v as if they were returned as a single page.
+func (g *GraphClient) makePagedAPICall(reqURL *url.URL, reqParams *listQueryOptions, v interface{}) error {
+	var pages = g.newPageIterator(reqURL, reqParams)
+	var items = []json.RawMessage{}
+	for page, ok := pages.next(reqParams.Context()); ok; page, ok = pages.next(reqParams.Context()) {
+		items = append(items, page...)
+	}
+	if pages.err != nil {
+		return pages.err
+	}
+
+	if v == nil {
+		return nil
+	}
+	collection, err := json.Marshal(odataPage{Value: items})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(collection, v)
+}
+
+// makePageIterator creates a pageIterator for a GET API-Call of the collection apiCall.
+func (g *GraphClient) makePageIterator(apiCall string, reqParams *listQueryOptions) *pageIterator {
+	reqURL, err := g.buildAPIURL(reqParams.APIVersion(), apiCall)
+	if err != nil {
+		return &pageIterator{err: err}
+	}
+	return g.newPageIterator(reqURL, reqParams)
+}
+
+// unmarshalItems json-unmarshals the raw items of a page into v, which must be a pointer to a slice.
+func unmarshalItems(items []json.RawMessage, v interface{}) error {
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
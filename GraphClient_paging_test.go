@@ -0,0 +1,210 @@
+package msgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pagedUsersHandler serves numUsers users on /beta/users, split into pages of the requested $top
+// size that are linked via @odata.nextLink. Every served page is reported to onPage.
+func pagedUsersHandler(t *testing.T, numUsers int, onPage func(r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/beta/users" {
+			t.Errorf("unexpected request path %v", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if onPage != nil {
+			onPage(r)
+		}
+		top, _ := strconv.Atoi(r.URL.Query().Get("$top"))
+		skip, _ := strconv.Atoi(r.URL.Query().Get("$skiptoken"))
+		var page = struct {
+			Value    []User `json:"value"`
+			NextLink string `json:"@odata.nextLink,omitempty"`
+		}{Value: []User{}}
+		for i := skip; i < skip+top && i < numUsers; i++ {
+			page.Value = append(page.Value, User{ID: strconv.Itoa(i), DisplayName: fmt.Sprintf("user %d", i)})
+		}
+		if skip+top < numUsers {
+			page.NextLink = fmt.Sprintf("http://%s/beta/users?$top=%d&$skiptoken=%d", r.Host, top, skip+top)
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+}
+
+func TestGraphClient_makePagedAPICall(t *testing.T) {
+	tests := []struct {
+		name      string
+		numUsers  int
+		opts      []ListQueryOption
+		wantUsers int
+		wantPages int
+	}{
+		{
+			name:      "single page",
+			numUsers:  5,
+			wantUsers: 5,
+			wantPages: 1,
+		}, {
+			name:      "empty collection",
+			numUsers:  0,
+			wantUsers: 0,
+			wantPages: 1,
+		}, {
+			name:      "follow all nextLinks",
+			numUsers:  25,
+			opts:      []ListQueryOption{ListWithPageSize(10)},
+			wantUsers: 25,
+			wantPages: 3,
+		}, {
+			name:      "stop at max items",
+			numUsers:  25,
+			opts:      []ListQueryOption{ListWithPageSize(10), ListWithMaxItems(15)},
+			wantUsers: 15,
+			wantPages: 2,
+		}, {
+			name:      "max items on page boundary",
+			numUsers:  25,
+			opts:      []ListQueryOption{ListWithPageSize(10), ListWithMaxItems(10)},
+			wantUsers: 10,
+			wantPages: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages int
+			g := newTestGraphClient(t, pagedUsersHandler(t, tt.numUsers, func(*http.Request) { pages++ }))
+			got, err := g.ListUsers(tt.opts...)
+			if err != nil {
+				t.Fatalf("GraphClient.ListUsers() error = %v", err)
+			}
+			if len(got) != tt.wantUsers {
+				t.Errorf("GraphClient.ListUsers() len = %d, want %d", len(got), tt.wantUsers)
+			}
+			if pages != tt.wantPages {
+				t.Errorf("GraphClient.ListUsers() requested %d pages, want %d", pages, tt.wantPages)
+			}
+			for i, user := range got {
+				if user.ID != strconv.Itoa(i) {
+					t.Errorf("GraphClient.ListUsers() user %d has ID %v, want %d", i, user.ID, i)
+				}
+				if user.graphClient != g {
+					t.Errorf("GraphClient.ListUsers() graphClient is not set on user %v", user.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestGraphClient_makePagedAPICallDefaultPageSize(t *testing.T) {
+	g := newTestGraphClient(t, pagedUsersHandler(t, 1, func(r *http.Request) {
+		if got := r.URL.Query().Get("$top"); got != strconv.Itoa(MaxPageSize) {
+			t.Errorf("$top = %v, want %v", got, MaxPageSize)
+		}
+	}))
+	if _, err := g.ListUsers(); err != nil {
+		t.Fatalf("GraphClient.ListUsers() error = %v", err)
+	}
+}
+
+func TestGraphClient_makePagedAPICallCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var pages int
+	g := newTestGraphClient(t, pagedUsersHandler(t, 25, func(*http.Request) {
+		pages++
+		cancel() // cancel after the first page has been requested
+	}))
+	_, err := g.ListUsers(ListWithPageSize(10), ListWithContext(ctx))
+	if err == nil {
+		t.Errorf("GraphClient.ListUsers() error = nil, want an error of the cancelled context")
+	}
+	if pages != 1 {
+		t.Errorf("GraphClient.ListUsers() requested %d pages after cancellation, want 1", pages)
+	}
+}
+
+func TestUserIterator(t *testing.T) {
+	var pages int
+	g := newTestGraphClient(t, pagedUsersHandler(t, 25, func(*http.Request) { pages++ }))
+	ctx := context.Background()
+
+	// iterate all users one by one
+	it := g.IterateUsers(ListWithPageSize(10))
+	var ids []string
+	for it.Next(ctx) {
+		if it.Value().graphClient != g {
+			t.Errorf("UserIterator.Value() graphClient is not set on user %v", it.Value().ID)
+		}
+		ids = append(ids, it.Value().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("UserIterator.Err() = %v", err)
+	}
+	if len(ids) != 25 || ids[0] != "0" || ids[24] != "24" {
+		t.Errorf("UserIterator returned users %v, want users 0 to 24", ids)
+	}
+	if pages != 3 {
+		t.Errorf("UserIterator requested %d pages, want 3", pages)
+	}
+
+	// stop after the first page and resume with the saved nextLink
+	pages = 0
+	it = g.IterateUsers(ListWithPageSize(10))
+	if !it.NextPage(ctx) || len(it.Page()) != 10 {
+		t.Fatalf("UserIterator.NextPage() = %v, want 10 users, err: %v", it.Page(), it.Err())
+	}
+	nextLink := it.NextLink()
+	if nextLink == "" || pages != 1 {
+		t.Fatalf("UserIterator.NextLink() = %q after %d pages, want a nextLink after 1 page", nextLink, pages)
+	}
+	it = g.IterateUsers(ListWithNextLink(nextLink))
+	if !it.Next(ctx) || it.Value().ID != "10" {
+		t.Errorf("resumed UserIterator.Value() = %v, want user 10, err: %v", it.Value(), it.Err())
+	}
+}
+
+func TestGraphClient_foreignNextLink(t *testing.T) {
+	var foreignRequests int
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignRequests++
+		fmt.Fprint(w, `{"value": []}`)
+	}))
+	defer foreign.Close()
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"value": [{"id": "1"}], "@odata.nextLink": "%s/beta/users?$skiptoken=1"}`, foreign.URL)
+	}))
+	ctx := context.Background()
+
+	// the nextLink of a response
+	if users, err := g.ListUsers(); err == nil {
+		t.Errorf("ListUsers() with a foreign nextLink = %v, want an error", users)
+	}
+	// the nextLink and deltaLink of the caller, e.g. persisted ones
+	it := g.IterateUsers(ListWithNextLink(foreign.URL + "/beta/users?$skiptoken=1"))
+	if it.Next(ctx) || it.Err() == nil {
+		t.Errorf("UserIterator with a foreign ListWithNextLink: err = %v, want an error", it.Err())
+	}
+	if _, _, err := g.ListUsersDelta(foreign.URL + "/beta/users/delta?$deltatoken=1"); err == nil {
+		t.Errorf("ListUsersDelta() with a foreign deltaLink: error = nil, want an error")
+	}
+	if foreignRequests != 0 {
+		t.Errorf("%v requests have been sent to the foreign host, want none", foreignRequests)
+	}
+}
+
+func TestGroup_IterateMembersNotGraphClientSourced(t *testing.T) {
+	it := Group{ID: "some-id"}.IterateMembers()
+	if it.Next(context.Background()) {
+		t.Errorf("UserIterator.Next() = true, want false")
+	}
+	if err := it.Err(); err != ErrNotGraphClientSourced {
+		t.Errorf("UserIterator.Err() = %v, want %v", err, ErrNotGraphClientSourced)
+	}
+}
@@ -4,12 +4,69 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 )
 
+var (
+	// ExpandWithSelect - $select - only returns the given properties of the expanded resources
+	ExpandWithSelect = func(selectParam string) ExpandOption {
+		return func(opts *url.Values) {
+			opts.Set(odataSelectParamKey, selectParam)
+		}
+	}
+
+	// ExpandWithFilter - $filter - only expands the related resources matching the filter
+	ExpandWithFilter = func(filterParam string) ExpandOption {
+		return func(opts *url.Values) {
+			opts.Set(odataFilterParamKey, filterParam)
+		}
+	}
+
+	// ExpandWithOrderBy - $orderby - sorts the expanded resources
+	ExpandWithOrderBy = func(orderByParam string) ExpandOption {
+		return func(opts *url.Values) {
+			opts.Set(odataOrderByParamKey, orderByParam)
+		}
+	}
+
+	// ExpandWithTop - $top - only expands the first top related resources
+	ExpandWithTop = func(top int) ExpandOption {
+		return func(opts *url.Values) {
+			opts.Set(odataTopParamKey, strconv.Itoa(top))
+		}
+	}
+)
+
+// addExpand adds the navigationProperty with its nested query options to the $expand parameter
+// of values, e.g. "members($select=id,displayName;$top=5)". Several navigation properties are
+// separated by a comma.
+func addExpand(values url.Values, navigationProperty string, expandOpts []ExpandOption) {
+	var nested = url.Values{}
+	for idx := range expandOpts {
+		expandOpts[idx](&nested)
+	}
+	var expand = navigationProperty
+	if len(nested) > 0 {
+		var params []string
+		for _, key := range []string{odataSelectParamKey, odataFilterParamKey, odataOrderByParamKey, odataTopParamKey} {
+			if value := nested.Get(key); value != "" {
+				params = append(params, key+"="+value)
+			}
+		}
+		expand += "(" + strings.Join(params, ";") + ")"
+	}
+	if existing := values.Get(odataExpandParamKey); existing != "" {
+		expand = existing + "," + expand
+	}
+	values.Set(odataExpandParamKey, expand)
+}
+
 type getRequestParams interface {
 	Context() context.Context
 	Values() url.Values
 	Headers() http.Header
+	APIVersion() string
 }
 
 type GetQueryOption func(opts *getQueryOptions)
@@ -22,6 +79,9 @@ type UpdateQueryOption func(opts *updateQueryOptions)
 
 type DeleteQueryOption func(opts *deleteQueryOptions)
 
+// ExpandOption sets a query option of an expanded navigation property, see GetWithExpand and ListWithExpand
+type ExpandOption func(opts *url.Values)
+
 var (
 	// GetWithContext - add a context.Context to the HTTP request e.g. to allow cancellation
 	GetWithContext = func(ctx context.Context) GetQueryOption {
@@ -37,6 +97,23 @@ var (
 		}
 	}
 
+	// GetWithExpand - $expand - includes the related resources of the given navigation property,
+	// e.g. "manager", optionally with nested query options like ExpandWithSelect. Can be passed
+	// multiple times to expand several navigation properties - https://docs.microsoft.com/en-us/graph/query-parameters#expand-parameter
+	GetWithExpand = func(navigationProperty string, expandOpts ...ExpandOption) GetQueryOption {
+		return func(opts *getQueryOptions) {
+			addExpand(opts.queryValues, navigationProperty, expandOpts)
+		}
+	}
+
+	// GetWithAPIVersion - perform the API-call with the given API version, e.g. APIVersionBeta,
+	// instead of the one of the GraphClient
+	GetWithAPIVersion = func(apiVersion string) GetQueryOption {
+		return func(opts *getQueryOptions) {
+			opts.apiVersion = apiVersion
+		}
+	}
+
 	// ListWithContext - add a context.Context to the HTTP request e.g. to allow cancellation
 	ListWithContext = func(ctx context.Context) ListQueryOption {
 		return func(opts *listQueryOptions) {
@@ -61,11 +138,105 @@ var (
 	// ListWithSearch - $search - Returns results based on search criteria - https://docs.microsoft.com/en-us/graph/query-parameters#search-parameter
 	ListWithSearch = func(searchParam string) ListQueryOption {
 		return func(opts *listQueryOptions) {
-			opts.queryHeaders.Add("ConsistencyLevel", "eventual")
+			opts.queryHeaders.Set("ConsistencyLevel", "eventual")
 			opts.queryValues.Add(odataSearchParamKey, searchParam)
 		}
 	}
 
+	// ListWithPageSize - $top - sets the amount of items that are requested per page. All pages are
+	// loaded by following the @odata.nextLink, this only changes how many API-calls are needed.
+	// Defaults to MaxPageSize - https://docs.microsoft.com/en-us/graph/paging
+	ListWithPageSize = func(pageSize int) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			opts.pageSize = pageSize
+		}
+	}
+
+	// ListWithNextLink - starts loading the collection at the given @odata.nextLink instead of the first
+	// page, e.g. to resume an iteration with the link saved from UserIterator.NextLink. The nextLink
+	// already contains all query parameters, hence $select, $filter etc. are ignored. It must belong to
+	// the Service Root Endpoint, the API-call fails otherwise.
+	ListWithNextLink = func(nextLink string) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			opts.nextLink = nextLink
+		}
+	}
+
+	// ListWithSkipToken - $skiptoken - starts loading the collection at the page identified by the
+	// given skip token, which is part of every @odata.nextLink - https://docs.microsoft.com/en-us/graph/paging
+	ListWithSkipToken = func(skipToken string) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			opts.queryValues.Set(odataSkipTokenParamKey, skipToken)
+		}
+	}
+
+	// ListWithMaxItems - stops loading further pages as soon as the given amount of items is
+	// reached and only returns that amount of items. A value <= 0 loads all items.
+	ListWithMaxItems = func(maxItems int) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			opts.maxItems = maxItems
+		}
+	}
+
+	// ListWithOrderBy - $orderby - sorts the results, e.g. "displayName desc" - https://docs.microsoft.com/en-us/graph/query-parameters#orderby-parameter
+	ListWithOrderBy = func(orderByParam string) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			opts.queryValues.Set(odataOrderByParamKey, orderByParam)
+		}
+	}
+
+	// ListWithTop - $top - only returns the first top items of the collection. Unlike
+	// ListWithPageSize, no further pages are loaded once top items have been returned, see
+	// ListWithMaxItems. A value <= 0 is ignored, hence all items are loaded unless limited by other
+	// options - https://docs.microsoft.com/en-us/graph/query-parameters#top-parameter
+	ListWithTop = func(top int) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			if top <= 0 {
+				return
+			}
+			opts.pageSize = top
+			opts.queryValues.Set(odataTopParamKey, strconv.Itoa(opts.PageSize()))
+			opts.maxItems = top
+		}
+	}
+
+	// ListWithSkip - $skip - skips the first skip items of the collection. Not supported by all
+	// collections, e.g. users use $skiptoken instead - https://docs.microsoft.com/en-us/graph/query-parameters#skip-parameter
+	ListWithSkip = func(skip int) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			opts.queryValues.Set(odataSkipParamKey, strconv.Itoa(skip))
+		}
+	}
+
+	// ListWithCount - $count - requests the total amount of items in the collection, which is
+	// stored in count when the first page has been loaded. Directory objects like users and groups
+	// require the ConsistencyLevel header "eventual", which is set automatically - https://docs.microsoft.com/en-us/graph/query-parameters#count-parameter
+	ListWithCount = func(count *int) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			opts.queryValues.Set(odataCountParamKey, "true")
+			opts.queryHeaders.Set("ConsistencyLevel", "eventual")
+			opts.count = count
+		}
+	}
+
+	// ListWithExpand - $expand - includes the related resources of the given navigation property,
+	// e.g. "members", optionally with nested query options like ExpandWithSelect. Can be passed
+	// multiple times to expand several navigation properties - https://docs.microsoft.com/en-us/graph/query-parameters#expand-parameter
+	ListWithExpand = func(navigationProperty string, expandOpts ...ExpandOption) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			addExpand(opts.queryValues, navigationProperty, expandOpts)
+		}
+	}
+
+	// ListWithAPIVersion - perform the API-calls with the given API version, e.g. APIVersionBeta,
+	// instead of the one of the GraphClient. Ignored for ListWithNextLink, the nextLink already
+	// contains the API version.
+	ListWithAPIVersion = func(apiVersion string) ListQueryOption {
+		return func(opts *listQueryOptions) {
+			opts.apiVersion = apiVersion
+		}
+	}
+
 	// CreateWithContext - add a context.Context to the HTTP request e.g. to allow cancellation
 	CreateWithContext = func(ctx context.Context) CreateQueryOption {
 		return func(opts *createQueryOptions) {
@@ -73,18 +244,43 @@ var (
 		}
 	}
 
+	// CreateWithAPIVersion - perform the API-call with the given API version, e.g. APIVersionBeta,
+	// instead of the one of the GraphClient
+	CreateWithAPIVersion = func(apiVersion string) CreateQueryOption {
+		return func(opts *createQueryOptions) {
+			opts.apiVersion = apiVersion
+		}
+	}
+
 	// UpdateWithContext - add a context.Context to the HTTP request e.g. to allow cancellation
 	UpdateWithContext = func(ctx context.Context) UpdateQueryOption {
 		return func(opts *updateQueryOptions) {
 			opts.ctx = ctx
 		}
 	}
+
+	// UpdateWithAPIVersion - perform the API-call with the given API version, e.g. APIVersionBeta,
+	// instead of the one of the GraphClient
+	UpdateWithAPIVersion = func(apiVersion string) UpdateQueryOption {
+		return func(opts *updateQueryOptions) {
+			opts.apiVersion = apiVersion
+		}
+	}
+
 	// DeleteWithContext - add a context.Context to the HTTP request e.g. to allow cancellation
 	DeleteWithContext = func(ctx context.Context) DeleteQueryOption {
 		return func(opts *deleteQueryOptions) {
 			opts.ctx = ctx
 		}
 	}
+
+	// DeleteWithAPIVersion - perform the API-call with the given API version, e.g. APIVersionBeta,
+	// instead of the one of the GraphClient
+	DeleteWithAPIVersion = func(apiVersion string) DeleteQueryOption {
+		return func(opts *deleteQueryOptions) {
+			opts.apiVersion = apiVersion
+		}
+	}
 )
 
 // getQueryOptions allow to optionally pass OData query options
@@ -92,6 +288,7 @@ var (
 type getQueryOptions struct {
 	ctx         context.Context
 	queryValues url.Values
+	apiVersion  string // the API version of the API-call, the one of the GraphClient if empty
 }
 
 func (g *getQueryOptions) Context() context.Context {
@@ -109,6 +306,11 @@ func (g getQueryOptions) Headers() http.Header {
 	return http.Header{}
 }
 
+// APIVersion returns the API version of the API-call, empty to use the one of the GraphClient
+func (g getQueryOptions) APIVersion() string {
+	return g.apiVersion
+}
+
 func compileGetQueryOptions(options []GetQueryOption) *getQueryOptions {
 	var opts = &getQueryOptions{
 		queryValues: url.Values{},
@@ -125,6 +327,10 @@ func compileGetQueryOptions(options []GetQueryOption) *getQueryOptions {
 type listQueryOptions struct {
 	getQueryOptions
 	queryHeaders http.Header
+	pageSize     int    // the amount of items per page, see ListWithPageSize
+	maxItems     int    // the maximum amount of items to return, see ListWithMaxItems
+	nextLink     string // the page to start with, see ListWithNextLink
+	count        *int   // receives the @odata.count of the collection, see ListWithCount
 }
 
 func (g *listQueryOptions) Context() context.Context {
@@ -142,6 +348,14 @@ func (g listQueryOptions) Headers() http.Header {
 	return g.queryHeaders
 }
 
+// PageSize returns the amount of items that should be requested per page, MaxPageSize if not set
+func (g listQueryOptions) PageSize() int {
+	if g.pageSize <= 0 || g.pageSize > MaxPageSize {
+		return MaxPageSize
+	}
+	return g.pageSize
+}
+
 func compileListQueryOptions(options []ListQueryOption) *listQueryOptions {
 	var opts = &listQueryOptions{
 		getQueryOptions: getQueryOptions{
@@ -0,0 +1,76 @@
+package msgraph
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestListWithTop(t *testing.T) {
+	var pages []*http.Request
+	g := newTestGraphClient(t, pagedUsersHandler(t, 10, func(r *http.Request) { pages = append(pages, r) }))
+
+	users, err := g.ListUsers(ListWithTop(3))
+	if err != nil {
+		t.Fatalf("GraphClient.ListUsers() error = %v", err)
+	}
+	if len(users) != 3 || len(pages) != 1 || pages[0].URL.Query().Get("$top") != "3" {
+		t.Errorf("GraphClient.ListUsers(ListWithTop(3)) returned %v users with %v requests, want 3 users with $top=3 and 1 request", len(users), len(pages))
+	}
+
+	// a non-positive top is ignored and keeps the page size
+	pages = nil
+	users, err = g.ListUsers(ListWithPageSize(4), ListWithTop(0))
+	if err != nil {
+		t.Fatalf("GraphClient.ListUsers() error = %v", err)
+	}
+	if len(users) != 10 || len(pages) != 3 || pages[0].URL.Query().Get("$top") != "4" {
+		t.Errorf("GraphClient.ListUsers(ListWithTop(0)) returned %v users with %v requests, want 10 users with $top=4 and 3 requests", len(users), len(pages))
+	}
+}
+
+func TestListQueryOptions(t *testing.T) {
+	var requests []*http.Request
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/beta/groups":
+			fmt.Fprintf(w, `{"@odata.count":42,"value":[{"id":"group-1"}],"@odata.nextLink":"http://%s/beta/groups?$skiptoken=2"}`, r.Host)
+		case "/beta/users/user-1":
+			fmt.Fprint(w, `{"id":"user-1"}`)
+		}
+	}))
+
+	var count int
+	_, err := g.ListGroups(ListWithCount(&count), ListWithOrderBy("displayName desc"), ListWithSkip(5), ListWithMaxItems(1),
+		ListWithExpand("members", ExpandWithSelect("id,displayName"), ExpandWithFilter("accountEnabled eq true")), ListWithExpand("owners"))
+	if err != nil {
+		t.Fatalf("GraphClient.ListGroups() error = %v", err)
+	}
+	if count != 42 {
+		t.Errorf("ListWithCount() count = %v, want 42", count)
+	}
+	query := requests[0].URL.Query()
+	want := map[string]string{
+		"$count":   "true",
+		"$orderby": "displayName desc",
+		"$skip":    "5",
+		"$top":     "999",
+		"$expand":  "members($select=id,displayName;$filter=accountEnabled eq true),owners",
+	}
+	for key, value := range want {
+		if query.Get(key) != value {
+			t.Errorf("query parameter %v = %q, want %q", key, query.Get(key), value)
+		}
+	}
+	if requests[0].Header.Get("ConsistencyLevel") != "eventual" {
+		t.Errorf("ConsistencyLevel header = %q, want eventual", requests[0].Header.Get("ConsistencyLevel"))
+	}
+
+	if _, err := g.GetUser("user-1", GetWithExpand("manager", ExpandWithSelect("id"), ExpandWithTop(1))); err != nil {
+		t.Fatalf("GraphClient.GetUser() error = %v", err)
+	}
+	if expand := requests[len(requests)-1].URL.Query().Get("$expand"); expand != "manager($select=id;$top=1)" {
+		t.Errorf("GetWithExpand() $expand = %q, want manager($select=id;$top=1)", expand)
+	}
+}
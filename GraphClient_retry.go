@@ -0,0 +1,129 @@
+package msgraph
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how API-calls are retried if the msgraph API responds with 429 - Too Many
+// Requests, 503 - Service Unavailable or 504 - Gateway Timeout. The delay between two attempts is
+// taken from the Retry-After or x-ms-retry-after-ms header if available, otherwise an exponential
+// backoff with jitter is used. No attempt is made if the delay exceeds the deadline of the context.
+//
+// Idempotent requests - GET, PUT and DELETE - are always retried. POST and PATCH requests are only
+// retried on 429, because a throttled request has not been processed by the msgraph API. Set
+// RetryNonIdempotent to retry them on 503 and 504 too.
+//
+// See https://docs.microsoft.com/en-us/graph/throttling
+type RetryPolicy struct {
+	MaxAttempts        int           // the maximum number of attempts including the first one, <= 1 disables retries
+	BaseDelay          time.Duration // the backoff before the first retry, doubled for every further retry
+	MaxDelay           time.Duration // the maximum backoff between two attempts, 0 means no limit
+	RetryNonIdempotent bool          // retry POST and PATCH requests on 503 and 504 too
+}
+
+// DefaultRetryPolicy is used by all GraphClients unless another RetryPolicy is set with WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	MaxDelay:    time.Minute,
+}
+
+// WithRetryPolicy - use the given RetryPolicy instead of the DefaultRetryPolicy. Use
+// RetryPolicy{MaxAttempts: 1} to disable retries.
+var WithRetryPolicy = func(policy RetryPolicy) GraphClientOption {
+	return func(g *GraphClient) {
+		g.retryPolicy = &policy
+	}
+}
+
+// getRetryPolicy returns the RetryPolicy of the GraphClient or the DefaultRetryPolicy if none is set
+func (g *GraphClient) getRetryPolicy() RetryPolicy {
+	if g.retryPolicy == nil {
+		return DefaultRetryPolicy
+	}
+	return *g.retryPolicy
+}
+
+// retryDelay returns the delay before the next attempt of a request with the given httpMethod that
+// failed with err on the given attempt, starting at 1. Returns false if it must not be retried.
+func (p RetryPolicy) retryDelay(httpMethod string, attempt int, err error) (time.Duration, bool) {
+	var graphErr *GraphError
+	if attempt >= p.MaxAttempts || !errors.As(err, &graphErr) {
+		return 0, false
+	}
+	switch graphErr.StatusCode {
+	case http.StatusTooManyRequests:
+		// a throttled request has not been processed, hence it can be retried with every method
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !isIdempotent(httpMethod) && !p.RetryNonIdempotent {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if graphErr.RetryAfter > 0 {
+		return graphErr.RetryAfter, true
+	}
+	return p.backoff(attempt), true
+}
+
+// backoff returns the exponential backoff with jitter after the given attempt, starting at 1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)) // jitter between 50% and 100%
+}
+
+// isIdempotent returns true if a request with the given httpMethod can safely be sent multiple times
+func isIdempotent(httpMethod string) bool {
+	switch httpMethod {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter returns the delay requested by the msgraph API with the x-ms-retry-after-ms or
+// Retry-After header, which may either contain seconds or a HTTP date. Returns 0 if none is set.
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseInt(header.Get("x-ms-retry-after-ms"), 10, 64); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	retryAfter := header.Get("Retry-After")
+	if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// sleepContext waits for the given delay. Returns false immediately if the context is done or its
+// deadline would pass before the delay elapsed.
+func sleepContext(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
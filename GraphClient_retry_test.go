@@ -0,0 +1,165 @@
+package msgraph
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// failingHandler responds with the given status codes in order, afterwards with an empty user.
+// Every request body is appended to bodies.
+func failingHandler(statusCodes []int, header http.Header, bodies *[]string) http.Handler {
+	var attempt int
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, string(body))
+		if attempt < len(statusCodes) {
+			for key := range header {
+				w.Header().Set(key, header.Get(key))
+			}
+			w.WriteHeader(statusCodes[attempt])
+			attempt++
+			fmt.Fprint(w, `{"error": {"code": "TooManyRequests", "message": "retry later"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id": "user-id"}`)
+	})
+}
+
+func TestGraphClient_Retry(t *testing.T) {
+	fastRetries := WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		header       http.Header
+		opts         []GraphClientOption
+		call         func(g *GraphClient) error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "GET retried on 429 with x-ms-retry-after-ms",
+			statusCodes:  []int{429, 429},
+			header:       http.Header{"X-Ms-Retry-After-Ms": []string{"1"}},
+			opts:         []GraphClientOption{fastRetries},
+			call:         func(g *GraphClient) error { _, err := g.GetUser("user-id"); return err },
+			wantAttempts: 3,
+		}, {
+			name:         "GET retried on 503 and 504",
+			statusCodes:  []int{503, 504},
+			opts:         []GraphClientOption{fastRetries},
+			call:         func(g *GraphClient) error { _, err := g.GetUser("user-id"); return err },
+			wantAttempts: 3,
+		}, {
+			name:         "give up after MaxAttempts",
+			statusCodes:  []int{429, 429, 429},
+			opts:         []GraphClientOption{fastRetries},
+			call:         func(g *GraphClient) error { _, err := g.GetUser("user-id"); return err },
+			wantAttempts: 3,
+			wantErr:      true,
+		}, {
+			name:         "no retry on 404",
+			statusCodes:  []int{404},
+			opts:         []GraphClientOption{fastRetries},
+			call:         func(g *GraphClient) error { _, err := g.GetUser("user-id"); return err },
+			wantAttempts: 1,
+			wantErr:      true,
+		}, {
+			name:         "retries disabled",
+			statusCodes:  []int{429},
+			opts:         []GraphClientOption{WithRetryPolicy(RetryPolicy{MaxAttempts: 1})},
+			call:         func(g *GraphClient) error { _, err := g.GetUser("user-id"); return err },
+			wantAttempts: 1,
+			wantErr:      true,
+		}, {
+			name:         "POST retried on 429",
+			statusCodes:  []int{429},
+			opts:         []GraphClientOption{fastRetries},
+			call:         func(g *GraphClient) error { _, err := g.CreateUser(User{DisplayName: "retried"}); return err },
+			wantAttempts: 2,
+		}, {
+			name:         "POST not retried on 503",
+			statusCodes:  []int{503},
+			opts:         []GraphClientOption{fastRetries},
+			call:         func(g *GraphClient) error { _, err := g.CreateUser(User{DisplayName: "retried"}); return err },
+			wantAttempts: 1,
+			wantErr:      true,
+		}, {
+			name:        "POST retried on 503 with RetryNonIdempotent",
+			statusCodes: []int{503},
+			opts: []GraphClientOption{WithRetryPolicy(RetryPolicy{
+				MaxAttempts: 3, BaseDelay: time.Millisecond, RetryNonIdempotent: true,
+			})},
+			call:         func(g *GraphClient) error { _, err := g.CreateUser(User{DisplayName: "retried"}); return err },
+			wantAttempts: 2,
+		}, {
+			name:        "Retry-After exceeds context deadline",
+			statusCodes: []int{429},
+			header:      http.Header{"Retry-After": []string{"60"}},
+			opts:        []GraphClientOption{fastRetries},
+			call: func(g *GraphClient) error {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				_, err := g.GetUser("user-id", GetWithContext(ctx))
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []string
+			g := newTestGraphClient(t, failingHandler(tt.statusCodes, tt.header, &bodies), tt.opts...)
+			err := tt.call(g)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(bodies) != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", len(bodies), tt.wantAttempts)
+			}
+			for _, body := range bodies {
+				if body != bodies[0] {
+					t.Errorf("request body %q differs from the first attempt %q", body, bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "none", header: http.Header{}, want: 0},
+		{name: "seconds", header: http.Header{"Retry-After": []string{"7"}}, want: 7 * time.Second},
+		{name: "milliseconds take precedence", header: http.Header{"Retry-After": []string{"7"}, "X-Ms-Retry-After-Ms": []string{"1500"}}, want: 1500 * time.Millisecond},
+		{name: "invalid", header: http.Header{"Retry-After": []string{"soon"}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header); got != tt.want {
+				t.Errorf("parseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	// HTTP date
+	header := http.Header{"Retry-After": []string{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}
+	if got := parseRetryAfter(header); got <= 50*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter() = %v, want about 1 minute", got)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		if got := p.backoff(attempt + 1); got < max/2 || got > max {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", attempt+1, got, max/2, max)
+		}
+	}
+}
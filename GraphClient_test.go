@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"reflect"
@@ -12,6 +14,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/jbvmio/go-msgraph/msgraphtest"
 )
 
 // get graph client config from environment
@@ -51,6 +55,14 @@ func getEnvOrPanic(key string) string {
 }
 
 func TestMain(m *testing.M) {
+	if os.Getenv("MSGraphTenantID") == "" {
+		// no live tenant configured, run all tests against the fake msgraph API
+		srv := startFakeGraphServer()
+		code := m.Run()
+		srv.Close()
+		os.Exit(code)
+	}
+
 	msGraphTenantID = getEnvOrPanic("MSGraphTenantID")
 	msGraphApplicationID = getEnvOrPanic("MSGraphApplicationID")
 	msGraphClientSecret = getEnvOrPanic("MSGraphClientSecret")
@@ -85,6 +97,53 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+// startFakeGraphServer starts a msgraphtest.Server with the users, groups and calendars the tests
+// expect and initializes the test configuration and graphClient with it.
+func startFakeGraphServer() *msgraphtest.Server {
+	srv := msgraphtest.NewServer()
+	mustAdd := func(path string, obj msgraphtest.Object) msgraphtest.Object {
+		added, err := srv.Add(path, obj)
+		if err != nil {
+			panic(fmt.Sprintf("Cannot add %v to the fake msgraph API: %v", path, err))
+		}
+		return added
+	}
+
+	msGraphTenantID, msGraphApplicationID, msGraphClientSecret = srv.TenantID, srv.ApplicationID, srv.ClientSecret
+	msGraphAzureADAuthEndpoint, msGraphServiceRootEndpoint = srv.URL, srv.URL
+	msGraphExistingGroupDisplayName = "technicians"
+	msGraphExistingGroupDisplayNameNumRes = 1
+	msGraphExistingUserPrincipalInGroup = "felix@contoso.com"
+	msGraphExistingCalendarsOfUser = []string{"Calendar", "Birthdays"}
+	msGraphDomainNameForCreateTests = "contoso.com"
+
+	user := mustAdd("/users", msgraphtest.Object{"displayName": "Felix", "givenName": "Felix", "surname": "Example",
+		"userPrincipalName": msGraphExistingUserPrincipalInGroup, "mail": msGraphExistingUserPrincipalInGroup,
+		"accountEnabled": true, "businessPhones": []string{"+43 1 234567"}})
+	mustAdd("/users", msgraphtest.Object{"displayName": "Other", "userPrincipalName": "other@contoso.com", "accountEnabled": true})
+	group := mustAdd("/groups", msgraphtest.Object{"displayName": msGraphExistingGroupDisplayName, "securityEnabled": true, "groupTypes": []string{}})
+	mustAdd("/groups", msgraphtest.Object{"displayName": "technicians-archive", "securityEnabled": true, "groupTypes": []string{}})
+	if err := srv.AddMember(group["id"].(string), user["id"].(string)); err != nil {
+		panic(fmt.Sprintf("Cannot add the member to the fake msgraph API: %v", err))
+	}
+	userPath := "/users/" + user["id"].(string)
+	for _, name := range msGraphExistingCalendarsOfUser {
+		mustAdd(userPath+"/calendars", msgraphtest.Object{"name": name, "canEdit": true, "canShare": true})
+	}
+	start := time.Now().UTC().Add(time.Hour)
+	mustAdd(userPath+"/events", msgraphtest.Object{"subject": "Weekly sync", "originalStartTimeZone": "UTC", "originalEndTimeZone": "UTC",
+		"start": map[string]string{"dateTime": start.Format("2006-01-02T15:04:05.0000000"), "timeZone": "UTC"},
+		"end":   map[string]string{"dateTime": start.Add(time.Hour).Format("2006-01-02T15:04:05.0000000"), "timeZone": "UTC"}})
+
+	var err error
+	graphClient, err = NewGraphClientWithCustomEndpoint(msGraphTenantID, msGraphApplicationID, msGraphClientSecret, msGraphAzureADAuthEndpoint, msGraphServiceRootEndpoint)
+	if err != nil {
+		panic(fmt.Sprintf("Cannot initialize a new GraphClient for the fake msgraph API, error: %v", err))
+	}
+	rand.Seed(time.Now().UnixNano())
+	return srv
+}
+
 func randomString(n int) string {
 	var runes = []rune("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	b := make([]rune, n)
@@ -110,6 +169,33 @@ func createUnitTestUser(t *testing.T) User {
 	return user
 }
 
+// writeTestToken writes a token response of the Azure AD authentication endpoint with the given
+// accessToken, which is valid for an hour.
+func writeTestToken(w http.ResponseWriter, resource, accessToken string) {
+	fmt.Fprintf(w, `{"token_type":"Bearer","expires_on":"%d","not_before":"%d","resource":"%s","access_token":"%s"}`,
+		time.Now().Add(time.Hour).Unix(), time.Now().Add(-time.Minute).Unix(), resource, accessToken)
+}
+
+// newTestGraphClient starts a httptest.Server that hands out tokens on the token endpoint of the
+// tenant "test-tenant" and passes every other request to the given handler. The returned
+// GraphClient is connected to that server and configured with the given opts.
+func newTestGraphClient(t *testing.T, handler http.Handler, opts ...GraphClientOption) *GraphClient {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test-tenant/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		writeTestToken(w, r.FormValue("resource"), "test-access-token")
+	})
+	mux.Handle("/", handler)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	g, err := NewGraphClientWithCustomEndpoint("test-tenant", "test-application", "test-secret", srv.URL, srv.URL, opts...)
+	if err != nil {
+		t.Fatalf("Cannot initialize a GraphClient for the test server: %v", err)
+	}
+	return g
+}
+
 func TestNewGraphClient(t *testing.T) {
 	if msGraphAzureADAuthEndpoint != AzureADAuthEndpointGlobal || msGraphServiceRootEndpoint != ServiceRootEndpointGlobal {
 		t.Skip("Skipping TestNewGraphClient because the endpoint is not the default - global - endpoint")
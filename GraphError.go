@@ -0,0 +1,179 @@
+package msgraph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// GraphError is returned by all API-calls if the msgraph API - or the Azure AD authentication
+// endpoint when requesting a token - responds with a status code that is not 2xx. It contains the
+// parsed OData error of the response body and can be retrieved with errors.As:
+//
+//	var graphErr *msgraph.GraphError
+//	if errors.As(err, &graphErr) {
+//		fmt.Println(graphErr.StatusCode, graphErr.Code, graphErr.RequestID)
+//	}
+//
+// See https://docs.microsoft.com/en-us/graph/errors
+type GraphError struct {
+	StatusCode      int                // the HTTP status code of the response, e.g. 404
+	Code            string             // the error code, e.g. "Request_ResourceNotFound"
+	Message         string             // the human readable error message
+	RequestID       string             // the request-id of the innerError or header, required by Microsoft support
+	ClientRequestID string             // the client-request-id of the innerError or header
+	Date            time.Time          // the date of the innerError or header, zero if not available
+	Details         []GraphErrorDetail // further details of the error, if any
+	RetryAfter      time.Duration      // the delay requested by the Retry-After or x-ms-retry-after-ms header, if any
+	Body            string             // the raw response body
+
+	err error // an error wrapped by this GraphError, e.g. ErrFindUser, see Unwrap
+}
+
+// GraphErrorDetail contains one of the details of a GraphError
+type GraphErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Target  string `json:"target"`
+}
+
+func (e *GraphError) Error() string {
+	msg := fmt.Sprintf("StatusCode is not OK: %v", e.StatusCode)
+	if e.Code == "" && e.Message == "" {
+		msg = fmt.Sprintf("%v. Body: %v", msg, e.Body)
+	} else {
+		msg = fmt.Sprintf("%v. %v: %v", msg, e.Code, e.Message)
+	}
+	if e.RequestID != "" {
+		msg = fmt.Sprintf("%v (request-id: %v)", msg, e.RequestID)
+	}
+	if e.err != nil {
+		msg = fmt.Sprintf("%v: %v", e.err, msg)
+	}
+	return msg
+}
+
+// Unwrap returns the error wrapped by this GraphError, e.g. ErrFindUser if GetUser responds with
+// 404 - Not Found. This allows errors.Is(err, msgraph.ErrFindUser) alongside errors.As.
+func (e *GraphError) Unwrap() error {
+	return e.err
+}
+
+// newGraphError creates a GraphError for the given response status code, header and body.
+// The body can either contain an OData error of the msgraph API or an OAuth error of the
+// Azure AD authentication endpoint. The header values are used if the body does not provide them.
+func newGraphError(statusCode int, header http.Header, body []byte) *GraphError {
+	graphErr := &GraphError{
+		StatusCode:      statusCode,
+		Body:            string(body),
+		RequestID:       header.Get("request-id"),
+		ClientRequestID: header.Get("client-request-id"),
+		RetryAfter:      parseRetryAfter(header),
+	}
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		graphErr.Date = date
+	}
+
+	tmp := struct {
+		Error json.RawMessage `json:"error"`
+		// OAuth error of the Azure AD authentication endpoint
+		ErrorDescription string `json:"error_description"`
+		TraceID          string `json:"trace_id"`
+		CorrelationID    string `json:"correlation_id"`
+	}{}
+	if err := json.Unmarshal(body, &tmp); err != nil || len(tmp.Error) == 0 {
+		return graphErr // not a json error, the body is kept for proper error-analysis
+	}
+
+	// OAuth error: {"error": "invalid_client", "error_description": "...", "trace_id": "..."}
+	if err := json.Unmarshal(tmp.Error, &graphErr.Code); err == nil {
+		graphErr.Message = tmp.ErrorDescription
+		if tmp.TraceID != "" {
+			graphErr.RequestID = tmp.TraceID
+		}
+		if tmp.CorrelationID != "" {
+			graphErr.ClientRequestID = tmp.CorrelationID
+		}
+		return graphErr
+	}
+
+	// OData error: {"error": {"code": "...", "message": "...", "innerError": {...}, "details": [...]}}
+	odataErr := struct {
+		Code       string             `json:"code"`
+		Message    string             `json:"message"`
+		Details    []GraphErrorDetail `json:"details"`
+		InnerError struct {
+			RequestID       string `json:"request-id"`
+			ClientRequestID string `json:"client-request-id"`
+			Date            string `json:"date"`
+		} `json:"innerError"`
+	}{}
+	if err := json.Unmarshal(tmp.Error, &odataErr); err != nil {
+		return graphErr
+	}
+	graphErr.Code = odataErr.Code
+	graphErr.Message = odataErr.Message
+	graphErr.Details = odataErr.Details
+	if odataErr.InnerError.RequestID != "" {
+		graphErr.RequestID = odataErr.InnerError.RequestID
+	}
+	if odataErr.InnerError.ClientRequestID != "" {
+		graphErr.ClientRequestID = odataErr.InnerError.ClientRequestID
+	}
+	if date, err := time.Parse("2006-01-02T15:04:05", odataErr.InnerError.Date); err == nil {
+		graphErr.Date = date
+	}
+	return graphErr
+}
+
+// wrapGraphErrorOnStatus lets the GraphError within err wrap the given sentinel error if the
+// GraphError has the given statusCode. Returns err in any case.
+func wrapGraphErrorOnStatus(err error, statusCode int, sentinel error) error {
+	var graphErr *GraphError
+	if errors.As(err, &graphErr) && graphErr.StatusCode == statusCode {
+		graphErr.err = sentinel
+	}
+	return err
+}
+
+// hasGraphErrorStatus returns true if err is or wraps a GraphError with one of the given status codes
+func hasGraphErrorStatus(err error, statusCodes ...int) bool {
+	var graphErr *GraphError
+	if !errors.As(err, &graphErr) {
+		return false
+	}
+	for _, statusCode := range statusCodes {
+		if graphErr.StatusCode == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotFound returns true if err is a GraphError with status code 404 - Not Found
+func IsNotFound(err error) bool {
+	return hasGraphErrorStatus(err, http.StatusNotFound)
+}
+
+// IsThrottled returns true if err is a GraphError with status code 429 - Too Many Requests
+//
+// See https://docs.microsoft.com/en-us/graph/throttling
+func IsThrottled(err error) bool {
+	return hasGraphErrorStatus(err, http.StatusTooManyRequests)
+}
+
+// IsConflict returns true if err is a GraphError with status code 409 - Conflict, e.g. when
+// creating an object that already exists
+func IsConflict(err error) bool {
+	return hasGraphErrorStatus(err, http.StatusConflict)
+}
+
+// IsAuthorizationDenied returns true if err is a GraphError with status code 403 - Forbidden or
+// the error code Authorization_RequestDenied, hence the application lacks the required permissions
+func IsAuthorizationDenied(err error) bool {
+	var graphErr *GraphError
+	return hasGraphErrorStatus(err, http.StatusForbidden) ||
+		(errors.As(err, &graphErr) && graphErr.Code == "Authorization_RequestDenied")
+}
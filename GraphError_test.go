@@ -0,0 +1,170 @@
+package msgraph
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewGraphError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		body   string
+		want   GraphError
+	}{
+		{
+			name:   "OData error",
+			status: http.StatusNotFound,
+			header: http.Header{},
+			body: `{"error": {"code": "Request_ResourceNotFound", "message": "Resource 'x' does not exist.",
+				"details": [{"code": "detail", "message": "detailed message", "target": "id"}],
+				"innerError": {"date": "2021-06-15T12:01:02", "request-id": "req-id", "client-request-id": "client-req-id"}}}`,
+			want: GraphError{
+				StatusCode:      http.StatusNotFound,
+				Code:            "Request_ResourceNotFound",
+				Message:         "Resource 'x' does not exist.",
+				RequestID:       "req-id",
+				ClientRequestID: "client-req-id",
+				Date:            time.Date(2021, 6, 15, 12, 1, 2, 0, time.UTC),
+				Details:         []GraphErrorDetail{{Code: "detail", Message: "detailed message", Target: "id"}},
+			},
+		}, {
+			name:   "OAuth error of the token endpoint",
+			status: http.StatusUnauthorized,
+			header: http.Header{},
+			body:   `{"error": "invalid_client", "error_description": "AADSTS7000215: Invalid client secret is provided.", "trace_id": "trace", "correlation_id": "correlation"}`,
+			want: GraphError{
+				StatusCode:      http.StatusUnauthorized,
+				Code:            "invalid_client",
+				Message:         "AADSTS7000215: Invalid client secret is provided.",
+				RequestID:       "trace",
+				ClientRequestID: "correlation",
+			},
+		}, {
+			name:   "no json body, header values",
+			status: http.StatusBadGateway,
+			header: http.Header{"Request-Id": []string{"req-id"}, "Date": []string{"Tue, 15 Jun 2021 12:01:02 GMT"}},
+			body:   `Bad Gateway`,
+			want: GraphError{
+				StatusCode: http.StatusBadGateway,
+				RequestID:  "req-id",
+				Date:       time.Date(2021, 6, 15, 12, 1, 2, 0, time.UTC),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newGraphError(tt.status, tt.header, []byte(tt.body))
+			if got.StatusCode != tt.want.StatusCode || got.Code != tt.want.Code || got.Message != tt.want.Message ||
+				got.RequestID != tt.want.RequestID || got.ClientRequestID != tt.want.ClientRequestID ||
+				!got.Date.Equal(tt.want.Date) || fmt.Sprint(got.Details) != fmt.Sprint(tt.want.Details) {
+				t.Errorf("newGraphError() = %+v, want %+v", got, tt.want)
+			}
+			if got.Body != tt.body {
+				t.Errorf("newGraphError() Body = %v, want %v", got.Body, tt.body)
+			}
+		})
+	}
+}
+
+func TestGraphError_Helpers(t *testing.T) {
+	tests := []struct {
+		name                                                     string
+		err                                                      error
+		notFound, throttled, conflict, authorizationDenied, isGE bool
+	}{
+		{name: "404", err: &GraphError{StatusCode: 404}, notFound: true, isGE: true},
+		{name: "429 wrapped", err: fmt.Errorf("wrapped: %w", &GraphError{StatusCode: 429}), throttled: true, isGE: true},
+		{name: "409", err: &GraphError{StatusCode: 409}, conflict: true, isGE: true},
+		{name: "403", err: &GraphError{StatusCode: 403}, authorizationDenied: true, isGE: true},
+		{name: "Authorization_RequestDenied", err: &GraphError{StatusCode: 400, Code: "Authorization_RequestDenied"}, authorizationDenied: true, isGE: true},
+		{name: "other error", err: errors.New("some error")},
+		{name: "nil", err: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsNotFound(tt.err); got != tt.notFound {
+				t.Errorf("IsNotFound() = %v, want %v", got, tt.notFound)
+			}
+			if got := IsThrottled(tt.err); got != tt.throttled {
+				t.Errorf("IsThrottled() = %v, want %v", got, tt.throttled)
+			}
+			if got := IsConflict(tt.err); got != tt.conflict {
+				t.Errorf("IsConflict() = %v, want %v", got, tt.conflict)
+			}
+			if got := IsAuthorizationDenied(tt.err); got != tt.authorizationDenied {
+				t.Errorf("IsAuthorizationDenied() = %v, want %v", got, tt.authorizationDenied)
+			}
+			var graphErr *GraphError
+			if got := errors.As(tt.err, &graphErr); got != tt.isGE {
+				t.Errorf("errors.As() = %v, want %v", got, tt.isGE)
+			}
+		})
+	}
+}
+
+func TestGraphClient_GetUserNotFound(t *testing.T) {
+	g := newTestGraphClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("request-id", "req-id")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": "Request_ResourceNotFound", "message": "Resource does not exist."}}`)
+	}))
+	_, err := g.GetUser("nobody@contoso.com")
+	if !errors.Is(err, ErrFindUser) {
+		t.Errorf("GraphClient.GetUser() error = %v, want it to wrap %v", err, ErrFindUser)
+	}
+	var graphErr *GraphError
+	if !errors.As(err, &graphErr) || graphErr.Code != "Request_ResourceNotFound" || graphErr.RequestID != "req-id" {
+		t.Errorf("GraphClient.GetUser() error = %#v, want a GraphError", err)
+	}
+	if !IsNotFound(err) {
+		t.Errorf("IsNotFound() = false, want true")
+	}
+
+	_, err = g.GetGroup("no-group")
+	if !errors.Is(err, ErrFindGroup) || !IsNotFound(err) {
+		t.Errorf("GraphClient.GetGroup() error = %v, want it to wrap %v", err, ErrFindGroup)
+	}
+}
+
+func TestGraphError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *GraphError
+		want string
+	}{
+		{name: "OData error", err: &GraphError{StatusCode: 404, Code: "Request_ResourceNotFound", Message: "Resource does not exist.", RequestID: "req-id"},
+			want: "StatusCode is not OK: 404. Request_ResourceNotFound: Resource does not exist. (request-id: req-id)"},
+		{name: "OData error wrapping a sentinel", err: &GraphError{StatusCode: 404, Code: "Request_ResourceNotFound", Message: "Resource does not exist.", err: ErrFindUser},
+			want: ErrFindUser.Error() + ": StatusCode is not OK: 404. Request_ResourceNotFound: Resource does not exist."},
+		{name: "empty body", err: &GraphError{StatusCode: 502},
+			want: "StatusCode is not OK: 502. Body: "},
+		{name: "empty body wrapping a sentinel", err: &GraphError{StatusCode: 404, RequestID: "req-id", err: ErrFindUser},
+			want: ErrFindUser.Error() + ": StatusCode is not OK: 404. Body:  (request-id: req-id)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("GraphError.Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGraphClient_TokenGraphError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": "invalid_client", "error_description": "Invalid client secret is provided."}`)
+	}))
+	defer srv.Close()
+	_, err := NewGraphClientWithCustomEndpoint("test-tenant", "test-application", "wrong-secret", srv.URL, srv.URL)
+	var graphErr *GraphError
+	if !errors.As(err, &graphErr) || graphErr.Code != "invalid_client" || graphErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("NewGraphClientWithCustomEndpoint() error = %v, want a GraphError with code invalid_client", err)
+	}
+}
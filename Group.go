@@ -24,6 +24,7 @@ type Group struct {
 	ProxyAddresses               []string
 	SecurityEnabled              bool
 	Visibility                   string
+	Removed                      *Removed `json:"@removed,omitempty"` // only set by ListGroupsDelta if the group has been removed
 
 	graphClient *GraphClient // the graphClient that called the group
 }
@@ -53,8 +54,23 @@ func (g Group) ListMembers(opts ...ListQueryOption) (Users, error) {
 	var marsh struct {
 		Users Users `json:"value"`
 	}
+	err := g.graphClient.makeGETAPICall(resource, compileListQueryOptions(opts), &marsh)
 	marsh.Users.setGraphClient(g.graphClient)
-	return marsh.Users, g.graphClient.makeGETAPICall(resource, compileListQueryOptions(opts), &marsh)
+	return marsh.Users, err
+}
+
+// IterateMembers returns a UserIterator over the group's direct members, which loads the
+// members page by page instead of all at once like ListMembers does. The iterator returns
+// ErrNotGraphClientSourced via Err if the group has not been created by a GraphClient.
+// Supports optional OData query parameters https://docs.microsoft.com/en-us/graph/query-parameters
+//
+// See https://developer.microsoft.com/en-us/graph/docs/api-reference/v1.0/api/group_list_members
+func (g Group) IterateMembers(opts ...ListQueryOption) *UserIterator {
+	if g.graphClient == nil {
+		return &UserIterator{pages: &pageIterator{err: ErrNotGraphClientSourced}}
+	}
+	resource := fmt.Sprintf("/groups/%v/members", g.ID)
+	return &UserIterator{pages: g.graphClient.makePageIterator(resource, compileListQueryOptions(opts))}
 }
 
 // UnmarshalJSON implements the json unmarshal to be used by the json-library
@@ -74,6 +90,7 @@ func (g *Group) UnmarshalJSON(data []byte) error {
 		ProxyAddresses               []string `json:"proxyAddresses"`
 		SecurityEnabled              bool     `json:"securityEnabled"`
 		Visibility                   string   `json:"visibility"`
+		Removed                      *Removed `json:"@removed"`
 	}{}
 
 	err := json.Unmarshal(data, &tmp)
@@ -101,6 +118,7 @@ func (g *Group) UnmarshalJSON(data []byte) error {
 	g.ProxyAddresses = tmp.ProxyAddresses
 	g.SecurityEnabled = tmp.SecurityEnabled
 	g.Visibility = tmp.Visibility
+	g.Removed = tmp.Removed
 
 	return nil
 }
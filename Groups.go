@@ -1,6 +1,7 @@
 package msgraph
 
 import (
+	"context"
 	"strings"
 )
 
@@ -34,3 +35,69 @@ func (g Groups) GetByDisplayName(displayName string) (Group, error) {
 	}
 	return Group{}, ErrFindGroup
 }
+
+// GroupIterator iterates over a collection of groups that is loaded page by page, hence only
+// one page is held in memory at a time. Create it with e.g. GraphClient.IterateGroups.
+// See UserIterator for an example.
+type GroupIterator struct {
+	pages *pageIterator
+	page  Groups
+	index int
+	err   error
+}
+
+// Next advances to the next group and loads the next page if the current one is exhausted.
+// Returns false if there are no more groups or an error occurred, see Err.
+func (it *GroupIterator) Next(ctx context.Context) bool {
+	for it.index+1 >= len(it.page) {
+		if !it.NextPage(ctx) {
+			return false
+		}
+	}
+	it.index++
+	return true
+}
+
+// NextPage loads the next page, skipping all groups of the current page that have not been
+// returned by Next yet. Returns false if there are no more pages or an error occurred, see Err.
+func (it *GroupIterator) NextPage(ctx context.Context) bool {
+	items, ok := it.pages.next(ctx)
+	if !ok {
+		return false
+	}
+	var page Groups
+	if it.err = unmarshalItems(items, &page); it.err != nil {
+		return false
+	}
+	it.page = page.setGraphClient(it.pages.g)
+	it.index = -1
+	return true
+}
+
+// Value returns the current group, hence the group Next advanced to.
+func (it *GroupIterator) Value() Group {
+	if it.index < 0 || it.index >= len(it.page) {
+		return Group{}
+	}
+	return it.page[it.index]
+}
+
+// Page returns all groups of the current page.
+func (it *GroupIterator) Page() Groups {
+	return it.page
+}
+
+// Err returns the first error that occurred during the iteration, if any.
+func (it *GroupIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.pages.err
+}
+
+// NextLink returns the @odata.nextLink of the page following the current page, or an empty
+// string if the current page is the last one. Save it after a page has been processed completely
+// to resume the iteration later on with ListWithNextLink.
+func (it *GroupIterator) NextLink() string {
+	return it.pages.nextLink
+}
@@ -24,6 +24,9 @@ func (s ResponseStatus) Equal(other ResponseStatus) bool {
 
 // UnmarshalJSON implements the json unmarshal to be used by the json-library
 func (s *ResponseStatus) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" { // e.g. not set in the API version
+		return nil
+	}
 	tmp := struct {
 		Response  string `json:"response"`
 		Timestamp string `json:"time"`
@@ -0,0 +1,172 @@
+package msgraph
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Change types of a Subscription, multiple change types are separated by a comma, e.g.
+// ChangeTypeCreated + "," + ChangeTypeUpdated
+const (
+	ChangeTypeCreated = "created"
+	ChangeTypeUpdated = "updated"
+	ChangeTypeDeleted = "deleted"
+)
+
+// Subscription represents a change notification subscription of ms graph, which sends
+// notifications to the NotificationURL whenever the Resource changes.
+//
+// See https://docs.microsoft.com/en-us/graph/api/resources/subscription
+type Subscription struct {
+	ID                        string    `json:"id,omitempty"`
+	Resource                  string    `json:"resource,omitempty"`
+	ChangeType                string    `json:"changeType,omitempty"`
+	NotificationURL           string    `json:"notificationUrl,omitempty"`
+	LifecycleNotificationURL  string    `json:"lifecycleNotificationUrl,omitempty"`
+	ExpirationDateTime        time.Time `json:"expirationDateTime"`
+	ClientState               string    `json:"clientState,omitempty"`
+	ApplicationID             string    `json:"applicationId,omitempty"`
+	CreatorID                 string    `json:"creatorId,omitempty"`
+	IncludeResourceData       bool      `json:"includeResourceData,omitempty"`
+	EncryptionCertificate     string    `json:"encryptionCertificate,omitempty"`
+	EncryptionCertificateID   string    `json:"encryptionCertificateId,omitempty"`
+	LatestSupportedTLSVersion string    `json:"latestSupportedTlsVersion,omitempty"`
+
+	graphClient *GraphClient // the graphClient that called the subscription
+}
+
+func (s Subscription) String() string {
+	return fmt.Sprintf("Subscription(ID: \"%v\", Resource: \"%v\", ChangeType: \"%v\", NotificationURL: \"%v\", LifecycleNotificationURL: \"%v\", ExpirationDateTime: \"%v\", IncludeResourceData: %v, DirectAPIConnection: %v)",
+		s.ID, s.Resource, s.ChangeType, s.NotificationURL, s.LifecycleNotificationURL, s.ExpirationDateTime, s.IncludeResourceData, s.graphClient != nil)
+}
+
+// setGraphClient sets the graphClient instance in this instance and all child-instances (if any)
+func (s *Subscription) setGraphClient(gC *GraphClient) {
+	s.graphClient = gC
+}
+
+// MaxLifetime returns the maximum lifetime of the Subscription, see SubscriptionMaxLifetime
+func (s Subscription) MaxLifetime() time.Duration {
+	return SubscriptionMaxLifetime(s.Resource, s.IncludeResourceData)
+}
+
+// Renew extends the Subscription to the given expiration, see GraphClient.RenewSubscription
+func (s Subscription) Renew(expiration time.Time, opts ...UpdateQueryOption) (Subscription, error) {
+	if s.graphClient == nil {
+		return s, ErrNotGraphClientSourced
+	}
+	return s.graphClient.RenewSubscription(s.ID, expiration, opts...)
+}
+
+// Delete deletes the Subscription, hence no further notifications are sent.
+//
+// Reference: https://docs.microsoft.com/en-us/graph/api/subscription-delete
+func (s Subscription) Delete(opts ...DeleteQueryOption) error {
+	if s.graphClient == nil {
+		return ErrNotGraphClientSourced
+	}
+	return s.graphClient.DeleteSubscription(s.ID, opts...)
+}
+
+// Subscriptions represents multiple Subscription-instances.
+type Subscriptions []Subscription
+
+func (s Subscriptions) String() string {
+	var subscriptions = make([]string, len(s))
+	for i, subscription := range s {
+		subscriptions[i] = subscription.String()
+	}
+	return "Subscriptions(" + strings.Join(subscriptions, " | ") + ")"
+}
+
+// setGraphClient sets the GraphClient within that particular instance. Hence it's directly created by GraphClient
+func (s Subscriptions) setGraphClient(gC *GraphClient) Subscriptions {
+	for i := range s {
+		s[i].setGraphClient(gC)
+	}
+	return s
+}
+
+// NewSubscription returns a new Subscription for changes of the given resource, e.g. "users" or
+// "users/{id}/events". The clientState is sent with every notification and should be verified by
+// the receiver. The ExpirationDateTime is not set, hence CreateSubscription sets it to the maximum
+// lifetime of the resource, depending on IncludeResourceData.
+func NewSubscription(resource, changeType, notificationURL, clientState string) Subscription {
+	return Subscription{
+		Resource:        resource,
+		ChangeType:      changeType,
+		NotificationURL: notificationURL,
+		ClientState:     clientState,
+	}
+}
+
+// NewUsersSubscription returns a new Subscription for changes of all users, see NewSubscription
+func NewUsersSubscription(changeType, notificationURL, clientState string) Subscription {
+	return NewSubscription("users", changeType, notificationURL, clientState)
+}
+
+// NewGroupsSubscription returns a new Subscription for changes of all groups, see NewSubscription
+func NewGroupsSubscription(changeType, notificationURL, clientState string) Subscription {
+	return NewSubscription("groups", changeType, notificationURL, clientState)
+}
+
+// NewUserEventsSubscription returns a new Subscription for changes of the events of the user
+// identified by either the given ID or userPrincipalName, see NewSubscription
+func NewUserEventsSubscription(userIdentifier, changeType, notificationURL, clientState string) Subscription {
+	return NewSubscription(fmt.Sprintf("users/%v/events", userIdentifier), changeType, notificationURL, clientState)
+}
+
+// NewUserMessagesSubscription returns a new Subscription for changes of the messages of the user
+// identified by either the given ID or userPrincipalName, see NewSubscription
+func NewUserMessagesSubscription(userIdentifier, changeType, notificationURL, clientState string) Subscription {
+	return NewSubscription(fmt.Sprintf("users/%v/messages", userIdentifier), changeType, notificationURL, clientState)
+}
+
+// subscriptionExpirationMargin is subtracted from the maximum lifetime of a Subscription when
+// calculating its expiration, hence a deviating clock does not exceed the maximum lifetime.
+const subscriptionExpirationMargin = time.Minute
+
+// DefaultSubscriptionLifetime is the maximum lifetime of Subscriptions for resources that are not
+// known by SubscriptionMaxLifetime.
+const DefaultSubscriptionLifetime = 4230 * time.Minute
+
+// subscriptionLifetimes are the maximum lifetimes of Subscriptions per resource, the first
+// matching pattern wins. Patterns match the lowercase resource without leading slash.
+//
+// See https://docs.microsoft.com/en-us/graph/api/resources/subscription#maximum-length-of-subscription-per-resource-type
+var subscriptionLifetimes = []struct {
+	pattern          *regexp.Regexp
+	lifetime         time.Duration
+	withResourceData time.Duration // the lifetime if the Subscription includes resource data, lifetime if zero
+}{
+	{pattern: regexp.MustCompile(`^(chats|teams|appcatalogs|communications/(presences|onlinemeetings))(/|\(|$)`), lifetime: 60 * time.Minute},
+	{pattern: regexp.MustCompile(`^communications/callrecords`), lifetime: 4230 * time.Minute},
+	{pattern: regexp.MustCompile(`^security/alerts`), lifetime: 43200 * time.Minute},
+	{pattern: regexp.MustCompile(`^(users|groups)(/[^/]+)?$|^directory/`), lifetime: 41760 * time.Minute},
+	{pattern: regexp.MustCompile(`^groups/[^/]+/conversations$`), lifetime: 4230 * time.Minute},
+	{pattern: regexp.MustCompile(`(^|/)(drive|drives)(/|$)|/lists/`), lifetime: 42300 * time.Minute},
+	{pattern: regexp.MustCompile(`(^|/)(messages|events|contacts)$`), lifetime: 4230 * time.Minute, withResourceData: 1440 * time.Minute},
+}
+
+// SubscriptionMaxLifetime returns the maximum lifetime of a Subscription for the given resource,
+// e.g. 41760 minutes for "users" and 4230 minutes for "users/{id}/events". Subscriptions that
+// include resource data may have a shorter lifetime. DefaultSubscriptionLifetime is returned for
+// unknown resources.
+func SubscriptionMaxLifetime(resource string, includeResourceData bool) time.Duration {
+	resource = strings.ToLower(strings.TrimPrefix(resource, "/"))
+	if idx := strings.Index(resource, "?"); idx >= 0 {
+		resource = resource[:idx]
+	}
+	for _, l := range subscriptionLifetimes {
+		if !l.pattern.MatchString(resource) {
+			continue
+		}
+		if includeResourceData && l.withResourceData != 0 {
+			return l.withResourceData
+		}
+		return l.lifetime
+	}
+	return DefaultSubscriptionLifetime
+}
@@ -0,0 +1,328 @@
+package msgraph
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lifecycle events of a ChangeNotification, which are sent to the LifecycleNotificationURL of a Subscription.
+//
+// See https://docs.microsoft.com/en-us/graph/webhooks-lifecycle
+const (
+	// LifecycleEventReauthorizationRequired - the access token of the Subscription is about to
+	// expire, the Subscription must be renewed or reauthorized to keep receiving notifications.
+	LifecycleEventReauthorizationRequired = "reauthorizationRequired"
+	// LifecycleEventSubscriptionRemoved - the Subscription has been removed, e.g. because the access
+	// has been revoked, and must be created again.
+	LifecycleEventSubscriptionRemoved = "subscriptionRemoved"
+	// LifecycleEventMissed - some notifications have not been delivered, the resource should be
+	// synchronized, e.g. with a delta query.
+	LifecycleEventMissed = "missed"
+)
+
+// ChangeNotification is a single notification of a Subscription, see ChangeNotificationCollection.
+//
+// See https://docs.microsoft.com/en-us/graph/api/resources/changenotification
+type ChangeNotification struct {
+	ID                             string            `json:"id,omitempty"`
+	SubscriptionID                 string            `json:"subscriptionId"`
+	SubscriptionExpirationDateTime time.Time         `json:"subscriptionExpirationDateTime"`
+	ClientState                    string            `json:"clientState,omitempty"`
+	ChangeType                     string            `json:"changeType,omitempty"`
+	Resource                       string            `json:"resource,omitempty"`
+	TenantID                       string            `json:"tenantId,omitempty"`
+	ResourceData                   *ResourceData     `json:"resourceData,omitempty"`
+	EncryptedContent               *EncryptedContent `json:"encryptedContent,omitempty"`
+	LifecycleEvent                 string            `json:"lifecycleEvent,omitempty"`
+}
+
+// ResourceData identifies the changed resource of a ChangeNotification
+type ResourceData struct {
+	ODataType string `json:"@odata.type,omitempty"`
+	ODataID   string `json:"@odata.id,omitempty"`
+	ODataEtag string `json:"@odata.etag,omitempty"`
+	ID        string `json:"id,omitempty"`
+}
+
+// ChangeNotificationCollection is the body of a request to the NotificationURL of a Subscription,
+// which contains a batch of notifications.
+//
+// See https://docs.microsoft.com/en-us/graph/api/resources/changenotificationcollection
+type ChangeNotificationCollection struct {
+	Value            []ChangeNotification `json:"value"`
+	ValidationTokens []string             `json:"validationTokens,omitempty"`
+}
+
+// ResourceType returns the type of the changed resource, which is the last collection of the
+// Resource in lowercase, e.g. "events" for "Users/{id}/Events/{id}" and "users" for "Users/{id}".
+func (n ChangeNotification) ResourceType() string {
+	var resourceType string
+	expectID := false
+	for _, segment := range strings.Split(strings.Trim(n.Resource, "/"), "/") {
+		switch {
+		case strings.Contains(segment, "("): // e.g. Users('{id}')
+			resourceType, expectID = segment[:strings.Index(segment, "(")], false
+		case expectID:
+			expectID = false
+		default:
+			resourceType, expectID = segment, true
+		}
+	}
+	return strings.ToLower(resourceType)
+}
+
+// maxNotificationBodySize limits the size of a ChangeNotificationCollection accepted by SubscriptionHandler
+const maxNotificationBodySize = 10 << 20
+
+// NotificationFunc processes a single ChangeNotification, see SubscriptionHandler.Handle. The
+// context is cancelled if SubscriptionHandler.Shutdown does not complete in time.
+type NotificationFunc func(ctx context.Context, n ChangeNotification)
+
+// SubscriptionHandler is a http.Handler that receives the change notifications of Subscriptions
+// at their NotificationURL and LifecycleNotificationURL:
+//
+//	handler := msgraph.NewSubscriptionHandler("<clientState>")
+//	handler.Handle("users", func(ctx context.Context, n msgraph.ChangeNotification) {
+//		user, err := graphClient.GetUser(n.ResourceData.ID, msgraph.GetWithContext(ctx))
+//		// ...
+//	})
+//	http.Handle("/notifications", handler)
+//
+// It answers the validationToken handshake of new Subscriptions, verifies the clientState of every
+// notification and acknowledges valid notifications right away with 202 - Accepted. The
+// notifications are processed asynchronously by a bounded pool of workers afterwards, hence their
+// order is not guaranteed. Requests are answered with 503 - Service Unavailable if the queue is
+// full, hence Microsoft retries them later.
+type SubscriptionHandler struct {
+	clientState string
+	workers     int
+	queueSize   int
+	handlers    map[string]NotificationFunc // by resource type, "" for all other resource types
+	lifecycle   NotificationFunc
+	onError     func(n ChangeNotification, err error)
+	validator   *ValidationTokenValidator // validates the validationTokens of each request, if set
+
+	mu       sync.RWMutex // guards handlers and closed
+	closed   bool         // no notifications are accepted after Shutdown
+	queue    chan ChangeNotification
+	wg       sync.WaitGroup
+	ctx      context.Context // the context of the NotificationFuncs
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+}
+
+// SubscriptionHandlerOption configures optional settings of a SubscriptionHandler, see NewSubscriptionHandler
+type SubscriptionHandlerOption func(h *SubscriptionHandler)
+
+var (
+	// HandlerWithWorkers - process the notifications with the given amount of concurrent workers, defaults to 4.
+	HandlerWithWorkers = func(workers int) SubscriptionHandlerOption {
+		return func(h *SubscriptionHandler) {
+			if workers > 0 {
+				h.workers = workers
+			}
+		}
+	}
+
+	// HandlerWithQueueSize - queue up to the given amount of notifications that have been acknowledged
+	// but not processed yet, defaults to 100. Further requests are answered with 503 - Service Unavailable.
+	HandlerWithQueueSize = func(queueSize int) SubscriptionHandlerOption {
+		return func(h *SubscriptionHandler) {
+			if queueSize > 0 {
+				h.queueSize = queueSize
+			}
+		}
+	}
+
+	// HandlerWithLifecycleFunc - process lifecycle notifications with fn, e.g. renew the Subscription on
+	// LifecycleEventReauthorizationRequired or create it again on LifecycleEventSubscriptionRemoved.
+	// Lifecycle notifications are passed to the error handler as ErrUnhandledNotification otherwise.
+	HandlerWithLifecycleFunc = func(fn NotificationFunc) SubscriptionHandlerOption {
+		return func(h *SubscriptionHandler) {
+			h.lifecycle = fn
+		}
+	}
+
+	// HandlerWithErrorHandler - call fn for every notification that is rejected, e.g. with
+	// ErrClientStateMismatch or ErrUnhandledNotification.
+	HandlerWithErrorHandler = func(fn func(n ChangeNotification, err error)) SubscriptionHandlerOption {
+		return func(h *SubscriptionHandler) {
+			h.onError = fn
+		}
+	}
+
+	// HandlerWithValidationTokens - validate the validationTokens of every request with v, which are
+	// included if notifications contain resource data. If validation fails, all notifications of the
+	// request are rejected with an error wrapping ErrInvalidValidationToken. Refresh the keys of v
+	// regularly with ValidationTokenValidator.SetKeys, because Microsoft rolls its signing keys.
+	HandlerWithValidationTokens = func(v *ValidationTokenValidator) SubscriptionHandlerOption {
+		return func(h *SubscriptionHandler) {
+			h.validator = v
+		}
+	}
+)
+
+// NewSubscriptionHandler returns a new SubscriptionHandler and starts its workers. Notifications are
+// only accepted if their clientState equals the given one, any clientState is accepted if it is empty.
+// Call Shutdown to stop the workers.
+func NewSubscriptionHandler(clientState string, opts ...SubscriptionHandlerOption) *SubscriptionHandler {
+	h := &SubscriptionHandler{
+		clientState: clientState,
+		workers:     4,
+		queueSize:   100,
+		handlers:    map[string]NotificationFunc{},
+	}
+	for idx := range opts {
+		opts[idx](h)
+	}
+	h.queue = make(chan ChangeNotification, h.queueSize)
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	for i := 0; i < h.workers; i++ {
+		h.wg.Add(1)
+		go h.work()
+	}
+	return h
+}
+
+// Handle registers fn for the notifications of the given resource type, e.g. "users", "groups",
+// "events" or "messages", see ChangeNotification.ResourceType. fn is registered for all resource types
+// without a NotificationFunc if resourceType is empty.
+func (h *SubscriptionHandler) Handle(resourceType string, fn NotificationFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[strings.ToLower(resourceType)] = fn
+}
+
+// ServeHTTP implements http.Handler
+func (h *SubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// validation of a new Subscription, the token must be returned as plain text within 10 seconds
+	if token := r.URL.Query().Get("validationToken"); token != "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, token)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxNotificationBodySize))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var collection ChangeNotificationCollection
+	if err := json.Unmarshal(body, &collection); err != nil {
+		http.Error(w, "invalid change notification collection", http.StatusBadRequest)
+		return
+	}
+
+	if h.validator != nil {
+		if err := h.validator.ValidateCollection(collection); err != nil {
+			for _, n := range collection.Value {
+				h.reject(n, err)
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+	}
+
+	var accepted []ChangeNotification
+	for _, n := range collection.Value {
+		if h.clientState != "" && subtle.ConstantTimeCompare([]byte(n.ClientState), []byte(h.clientState)) != 1 {
+			h.reject(n, ErrClientStateMismatch)
+			continue
+		}
+		accepted = append(accepted, n)
+	}
+	if !h.enqueue(accepted) {
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "notification queue is full", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// enqueue adds all notifications to the queue, false if the queue cannot take all of them or the
+// SubscriptionHandler has been shut down. Either all or none of the notifications are queued, hence
+// Microsoft does not send notifications twice when it retries the request.
+func (h *SubscriptionHandler) enqueue(notifications []ChangeNotification) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed || len(notifications) > cap(h.queue)-len(h.queue) {
+		return false
+	}
+	for _, n := range notifications {
+		h.queue <- n
+	}
+	return true
+}
+
+// work processes queued notifications until the queue is closed
+func (h *SubscriptionHandler) work() {
+	defer h.wg.Done()
+	for n := range h.queue {
+		h.dispatch(n)
+	}
+}
+
+// dispatch calls the NotificationFunc of the notification
+func (h *SubscriptionHandler) dispatch(n ChangeNotification) {
+	fn := h.lifecycle
+	if n.LifecycleEvent == "" {
+		h.mu.RLock()
+		var ok bool
+		if fn, ok = h.handlers[n.ResourceType()]; !ok {
+			fn = h.handlers[""]
+		}
+		h.mu.RUnlock()
+	}
+	if fn == nil {
+		h.reject(n, fmt.Errorf("%w: resource type %q, lifecycle event %q", ErrUnhandledNotification, n.ResourceType(), n.LifecycleEvent))
+		return
+	}
+	fn(h.ctx, n)
+}
+
+// reject passes the rejected notification to the error handler, if any
+func (h *SubscriptionHandler) reject(n ChangeNotification, err error) {
+	if h.onError != nil {
+		h.onError(n, err)
+	}
+}
+
+// Shutdown stops accepting notifications and waits until all queued notifications have been
+// processed or ctx is done. In the latter case, the context of the NotificationFuncs is cancelled
+// and the error of ctx is returned.
+func (h *SubscriptionHandler) Shutdown(ctx context.Context) error {
+	h.stopOnce.Do(func() {
+		h.mu.Lock()
+		h.closed = true
+		close(h.queue)
+		h.mu.Unlock()
+	})
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		h.cancel()
+		return nil
+	case <-ctx.Done():
+		h.cancel()
+		return ctx.Err()
+	}
+}
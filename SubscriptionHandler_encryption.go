@@ -0,0 +1,117 @@
+package msgraph
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// EncryptedContent is the encrypted resource data of a ChangeNotification, which is included if
+// the Subscription has IncludeResourceData set. The data is encrypted with the public key of the
+// EncryptionCertificate of the Subscription, use Decrypt with the matching private key.
+//
+// See https://docs.microsoft.com/en-us/graph/webhooks-with-resource-data#decrypting-resource-data-from-change-notifications
+type EncryptedContent struct {
+	Data                            string `json:"data"`
+	DataSignature                   string `json:"dataSignature"`
+	DataKey                         string `json:"dataKey"`
+	EncryptionCertificateID         string `json:"encryptionCertificateId,omitempty"`
+	EncryptionCertificateThumbprint string `json:"encryptionCertificateThumbprint,omitempty"`
+}
+
+// Decrypt returns the decrypted resource data, a JSON object of the changed resource. The
+// symmetric key is decrypted with RSA-OAEP and the given private key, e.g. the *rsa.PrivateKey
+// returned by ParseCertificate. The data is verified with its HMAC-SHA256 signature before it is
+// decrypted with AES-CBC, ErrInvalidDataSignature is returned if the signature does not match.
+func (c EncryptedContent) Decrypt(privateKey crypto.Decrypter) ([]byte, error) {
+	dataKey, err := base64.StdEncoding.DecodeString(c.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot base64 decode dataKey: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(c.Data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot base64 decode data: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(c.DataSignature)
+	if err != nil {
+		return nil, fmt.Errorf("cannot base64 decode dataSignature: %w", err)
+	}
+
+	key, err := privateKey.Decrypt(rand.Reader, dataKey, &rsa.OAEPOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt dataKey with certificate %v: %w", c.EncryptionCertificateID, err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return nil, ErrInvalidDataSignature
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use dataKey as AES key: %w", err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("data is not a multiple of the AES block size")
+	}
+	// the initialization vector is the beginning of the symmetric key
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, key[:aes.BlockSize]).CryptBlocks(plain, data)
+	return unpadPKCS7(plain, aes.BlockSize)
+}
+
+// unpadPKCS7 removes the PKCS#7 padding of the given data
+func unpadPKCS7(data []byte, blockSize int) ([]byte, error) {
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > blockSize || padding > len(data) ||
+		!bytes.Equal(data[len(data)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("invalid PKCS#7 padding of decrypted data")
+	}
+	return data[:len(data)-padding], nil
+}
+
+// Decrypt decrypts the EncryptedContent of the notification with the given private key and
+// unmarshals the resource data into v, see EncryptedContent.Decrypt.
+func (n ChangeNotification) Decrypt(privateKey crypto.Decrypter, v interface{}) error {
+	if n.EncryptedContent == nil {
+		return fmt.Errorf("notification of subscription %v has no encryptedContent", n.SubscriptionID)
+	}
+	data, err := n.EncryptedContent.Decrypt(privateKey)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("cannot unmarshal resource data of subscription %v: %w", n.SubscriptionID, err)
+	}
+	return nil
+}
+
+// DecryptUser returns the User of a notification with resource data, see ChangeNotification.Decrypt
+func (n ChangeNotification) DecryptUser(privateKey crypto.Decrypter) (User, error) {
+	var user User
+	err := n.Decrypt(privateKey, &user)
+	return user, err
+}
+
+// DecryptGroup returns the Group of a notification with resource data, see ChangeNotification.Decrypt
+func (n ChangeNotification) DecryptGroup(privateKey crypto.Decrypter) (Group, error) {
+	var group Group
+	err := n.Decrypt(privateKey, &group)
+	return group, err
+}
+
+// DecryptCalendarEvent returns the CalendarEvent of a notification with resource data, see
+// ChangeNotification.Decrypt
+func (n ChangeNotification) DecryptCalendarEvent(privateKey crypto.Decrypter) (CalendarEvent, error) {
+	var event CalendarEvent
+	err := n.Decrypt(privateKey, &event)
+	return event, err
+}
@@ -0,0 +1,90 @@
+package msgraph
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// newTestEncryptedContent encrypts data for the given public key the way Microsoft encrypts resource data
+func newTestEncryptedContent(t *testing.T, publicKey *rsa.PublicKey, data []byte) *EncryptedContent {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	plain := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, key[:aes.BlockSize]).CryptBlocks(encrypted, plain)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(encrypted)
+	dataKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, publicKey, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &EncryptedContent{
+		Data:                    base64.StdEncoding.EncodeToString(encrypted),
+		DataSignature:           base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		DataKey:                 base64.StdEncoding.EncodeToString(dataKey),
+		EncryptionCertificateID: "cert-1",
+	}
+}
+
+func TestChangeNotification_Decrypt(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := ChangeNotification{SubscriptionID: "s1", EncryptedContent: newTestEncryptedContent(t, &privateKey.PublicKey,
+		[]byte(`{"id": "u1", "displayName": "Alice", "mail": "alice@contoso.com"}`))}
+	user, err := n.DecryptUser(privateKey)
+	if err != nil || user.ID != "u1" || user.DisplayName != "Alice" || user.Mail != "alice@contoso.com" {
+		t.Errorf("ChangeNotification.DecryptUser() = %v, error = %v", user, err)
+	}
+
+	n.EncryptedContent = newTestEncryptedContent(t, &privateKey.PublicKey, []byte(`{"id": "g1", "displayName": "Sales"}`))
+	group, err := n.DecryptGroup(privateKey)
+	if err != nil || group.ID != "g1" || group.DisplayName != "Sales" {
+		t.Errorf("ChangeNotification.DecryptGroup() = %v, error = %v", group, err)
+	}
+
+	// a multiple of the block size is padded with a full block
+	n.EncryptedContent = newTestEncryptedContent(t, &privateKey.PublicKey, []byte(`{"id":"e1","subject":"Planning"}`))
+	event, err := n.DecryptCalendarEvent(privateKey)
+	if err != nil || event.ID != "e1" || event.Subject != "Planning" {
+		t.Errorf("ChangeNotification.DecryptCalendarEvent() = %v, error = %v", event, err)
+	}
+
+	tampered := *n.EncryptedContent
+	data, _ := base64.StdEncoding.DecodeString(tampered.Data)
+	data[0] ^= 1
+	tampered.Data = base64.StdEncoding.EncodeToString(data)
+	if _, err := tampered.Decrypt(privateKey); !errors.Is(err, ErrInvalidDataSignature) {
+		t.Errorf("EncryptedContent.Decrypt() of tampered data error = %v, want %v", err, ErrInvalidDataSignature)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.EncryptedContent.Decrypt(otherKey); err == nil {
+		t.Errorf("EncryptedContent.Decrypt() with another key succeeded, want an error")
+	}
+	if _, err := (ChangeNotification{}).DecryptUser(privateKey); err == nil {
+		t.Errorf("ChangeNotification.DecryptUser() without encryptedContent succeeded, want an error")
+	}
+}
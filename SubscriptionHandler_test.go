@@ -0,0 +1,162 @@
+package msgraph
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// postNotifications posts the given ChangeNotificationCollection to h and returns the status code
+func postNotifications(t *testing.T, h http.Handler, body string) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(body)))
+	return rec.Code
+}
+
+func TestChangeNotification_ResourceType(t *testing.T) {
+	tests := map[string]string{
+		"Users/8a5bd0c8-5b8e-4a3c-9d2a-2a3b5f0c1d2e":      "users",
+		"Users/{id}/Events/{id}":                          "events",
+		"Users('{id}')/Messages('{id}')":                  "messages",
+		"/groups/{id}":                                    "groups",
+		"Users/{id}/mailFolders('Inbox')/Messages/{id}":   "messages",
+		"teams('{id}')/channels('{id}')/messages('{id}')": "messages",
+		"": "",
+	}
+	for resource, want := range tests {
+		if got := (ChangeNotification{Resource: resource}).ResourceType(); got != want {
+			t.Errorf("ChangeNotification{Resource: %q}.ResourceType() = %q, want %q", resource, got, want)
+		}
+	}
+}
+
+func TestSubscriptionHandler_validation(t *testing.T) {
+	h := NewSubscriptionHandler("secret")
+	defer h.Shutdown(context.Background())
+
+	token := "Validation: Testing client application reachability for subscription Request-Id: 3d5e4b0a <script>"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notifications?validationToken="+url.QueryEscape(token), nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || string(body) != token || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("validation response = %v %q (%v), want 200 with the token as text/plain", rec.Code, body, rec.Header().Get("Content-Type"))
+	}
+
+	if code := postNotifications(t, h, `{"value": [`); code != http.StatusBadRequest {
+		t.Errorf("invalid body status = %v, want 400", code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notifications", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %v, want 405", rec.Code)
+	}
+}
+
+func TestSubscriptionHandler_dispatch(t *testing.T) {
+	var mu sync.Mutex
+	var users, events, lifecycle []ChangeNotification
+	var errs []error
+	collect := func(list *[]ChangeNotification) NotificationFunc {
+		return func(ctx context.Context, n ChangeNotification) {
+			mu.Lock()
+			defer mu.Unlock()
+			*list = append(*list, n)
+		}
+	}
+	h := NewSubscriptionHandler("secret",
+		HandlerWithWorkers(2),
+		HandlerWithLifecycleFunc(collect(&lifecycle)),
+		HandlerWithErrorHandler(func(n ChangeNotification, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}),
+	)
+	h.Handle("Users", collect(&users))
+	h.Handle("events", collect(&events))
+
+	body := `{"value": [
+		{"subscriptionId": "s1", "clientState": "secret", "changeType": "updated", "resource": "Users/u1", "tenantId": "t",
+		 "subscriptionExpirationDateTime": "2021-09-01T10:00:00.0000000-07:00",
+		 "resourceData": {"@odata.type": "#Microsoft.Graph.User", "@odata.id": "Users/u1", "id": "u1"}},
+		{"subscriptionId": "s2", "clientState": "secret", "changeType": "created", "resource": "Users/u1/Events/e1"},
+		{"subscriptionId": "s3", "clientState": "secret", "changeType": "created", "resource": "Users/u1/Messages/m1"},
+		{"subscriptionId": "s4", "clientState": "wrong", "changeType": "created", "resource": "Users/u2"},
+		{"subscriptionId": "s1", "clientState": "secret", "lifecycleEvent": "reauthorizationRequired"}
+	]}`
+	if code := postNotifications(t, h, body); code != http.StatusAccepted {
+		t.Fatalf("notification status = %v, want 202", code)
+	}
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	mu.Lock()
+	if len(users) != 1 || users[0].ResourceData == nil || users[0].ResourceData.ID != "u1" || users[0].SubscriptionExpirationDateTime.IsZero() {
+		t.Errorf("users notifications = %+v, want u1 with its resource data", users)
+	}
+	if len(events) != 1 || events[0].SubscriptionID != "s2" {
+		t.Errorf("events notifications = %+v, want s2", events)
+	}
+	if len(lifecycle) != 1 || lifecycle[0].LifecycleEvent != LifecycleEventReauthorizationRequired {
+		t.Errorf("lifecycle notifications = %+v, want reauthorizationRequired", lifecycle)
+	}
+	var mismatch, unhandled int
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, ErrClientStateMismatch):
+			mismatch++
+		case errors.Is(err, ErrUnhandledNotification):
+			unhandled++
+		}
+	}
+	if mismatch != 1 || unhandled != 1 {
+		t.Errorf("errors = %v, want one clientState mismatch and one unhandled messages notification", errs)
+	}
+	mu.Unlock()
+
+	if code := postNotifications(t, h, body); code != http.StatusServiceUnavailable {
+		t.Errorf("notification status after Shutdown = %v, want 503", code)
+	}
+}
+
+func TestSubscriptionHandler_queueFull(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	h := NewSubscriptionHandler("", HandlerWithWorkers(1), HandlerWithQueueSize(2))
+	h.Handle("", func(ctx context.Context, n ChangeNotification) {
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	})
+
+	notification := `{"subscriptionId": "s", "resource": "Groups/g"}`
+	if code := postNotifications(t, h, `{"value": [`+notification+`]}`); code != http.StatusAccepted {
+		t.Fatalf("first notification status = %v, want 202", code)
+	}
+	<-started // the worker is busy, the queue is empty
+	if code := postNotifications(t, h, `{"value": [`+notification+`,`+notification+`,`+notification+`]}`); code != http.StatusServiceUnavailable {
+		t.Errorf("status of a batch larger than the queue = %v, want 503", code)
+	}
+	if code := postNotifications(t, h, `{"value": [`+notification+`,`+notification+`]}`); code != http.StatusAccepted {
+		t.Errorf("status of a batch that fits into the queue = %v, want 202", code)
+	}
+
+	// the NotificationFuncs are cancelled if Shutdown does not complete in time
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+}
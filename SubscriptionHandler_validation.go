@@ -0,0 +1,257 @@
+package msgraph
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChangeNotificationPublisherID is the application ID of Microsoft Graph change notifications,
+// which is the authorized party (azp) of every validationToken.
+const ChangeNotificationPublisherID = "0bf30f3b-4a52-48df-9a82-234910c4a086"
+
+// validationTokenClockSkew is the tolerated deviation of the clock when validating the lifetime of a validationToken
+const validationTokenClockSkew = 5 * time.Minute
+
+// JSONWebKey is a public key of a JSONWebKeySet. RSA keys are supported, either given by their
+// modulus and exponent or by their certificate chain.
+type JSONWebKey struct {
+	KeyType string   `json:"kty"`
+	KeyID   string   `json:"kid"`
+	Use     string   `json:"use,omitempty"`
+	N       string   `json:"n,omitempty"`
+	E       string   `json:"e,omitempty"`
+	X5C     []string `json:"x5c,omitempty"`
+}
+
+// JSONWebKeySet contains the public keys that sign validationTokens, e.g. json-unmarshalled from
+// AzureADAuthEndpointGlobal + "/common/discovery/v2.0/keys".
+//
+// See https://datatracker.ietf.org/doc/html/rfc7517#section-5
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// publicKey returns the RSA public key of the JSONWebKey
+func (k JSONWebKey) publicKey() (*rsa.PublicKey, error) {
+	if k.KeyType != "RSA" {
+		return nil, fmt.Errorf("key type %q is not supported", k.KeyType)
+	}
+	if k.N != "" && k.E != "" {
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("cannot base64 decode modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("cannot base64 decode exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("exponent is too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	}
+	if len(k.X5C) == 0 {
+		return nil, fmt.Errorf("neither modulus and exponent nor certificate given")
+	}
+	der, err := base64.StdEncoding.DecodeString(k.X5C[0])
+	if err != nil {
+		return nil, fmt.Errorf("cannot base64 decode certificate: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("certificate key must be a RSA key, got %T", certificate.PublicKey)
+	}
+	return publicKey, nil
+}
+
+// ValidationTokenValidator validates the validationTokens of a ChangeNotificationCollection, which
+// are included if notifications contain resource data. Use HandlerWithValidationTokens to validate
+// them in a SubscriptionHandler.
+//
+// See https://docs.microsoft.com/en-us/graph/webhooks-with-resource-data#validation-tokens-in-the-change-notification
+type ValidationTokenValidator struct {
+	applicationID string
+	tenantIDs     map[string]bool // the accepted tenants, all tenants if empty
+
+	mu   sync.RWMutex // guards keys
+	keys map[string]*rsa.PublicKey
+
+	now func() time.Time
+}
+
+// NewValidationTokenValidator returns a ValidationTokenValidator for notifications of the given
+// application, whose tokens are signed by one of the given keys. Tokens of all tenants are accepted
+// unless tenantIDs are given. Returns an error if applicationID is empty or a RSA key of the
+// JSONWebKeySet cannot be parsed.
+func NewValidationTokenValidator(applicationID string, keys JSONWebKeySet, tenantIDs ...string) (*ValidationTokenValidator, error) {
+	if applicationID == "" {
+		return nil, fmt.Errorf("applicationID is required to validate the audience of validationTokens")
+	}
+	v := &ValidationTokenValidator{applicationID: applicationID, tenantIDs: map[string]bool{}, now: time.Now}
+	for _, tenantID := range tenantIDs {
+		v.tenantIDs[strings.ToLower(tenantID)] = true
+	}
+	if err := v.SetKeys(keys); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewValidationTokenValidator returns a ValidationTokenValidator for notifications of Subscriptions
+// created by this GraphClient, see NewValidationTokenValidator. It requires the ApplicationID and
+// TenantID of the GraphClient, which are not set for GraphClients created with
+// NewGraphClientWithTokenProvider, e.g. for managed identities or the on-behalf-of flow; use
+// NewValidationTokenValidator with the application and tenant instead. Returns an error if the
+// ApplicationID is empty. Tokens of all tenants are accepted if the TenantID is empty.
+func (g *GraphClient) NewValidationTokenValidator(keys JSONWebKeySet) (*ValidationTokenValidator, error) {
+	if g.ApplicationID == "" {
+		return nil, fmt.Errorf("ApplicationID of the GraphClient is not set, use NewValidationTokenValidator with the application")
+	}
+	var tenantIDs []string
+	if g.TenantID != "" {
+		tenantIDs = append(tenantIDs, g.TenantID)
+	}
+	return NewValidationTokenValidator(g.ApplicationID, keys, tenantIDs...)
+}
+
+// SetKeys replaces the keys of the ValidationTokenValidator, e.g. after Microsoft rolled its
+// signing keys. Keys that are not of type RSA or not used for signatures are ignored.
+func (v *ValidationTokenValidator) SetKeys(keys JSONWebKeySet) error {
+	publicKeys := map[string]*rsa.PublicKey{}
+	for _, key := range keys.Keys {
+		if key.KeyType != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return fmt.Errorf("cannot parse key %v: %w", key.KeyID, err)
+		}
+		publicKeys[key.KeyID] = publicKey
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = publicKeys
+	return nil
+}
+
+// validationTokenClaims are the validated claims of a validationToken
+type validationTokenClaims struct {
+	Issuer          string    `json:"iss"`
+	Audience        string    `json:"aud"`
+	AuthorizedParty string    `json:"azp"`
+	TenantID        string    `json:"tid"`
+	ExpiresAt       jsonInt64 `json:"exp"`
+	NotBefore       jsonInt64 `json:"nbf"`
+}
+
+// Validate validates the signature, issuer, audience and lifetime of the given validationToken.
+// Returns an error wrapping ErrInvalidValidationToken if the token is not valid.
+func (v *ValidationTokenValidator) Validate(token string) error {
+	_, err := v.validate(token)
+	return err
+}
+
+// validate returns the claims of the given validationToken if it is valid
+func (v *ValidationTokenValidator) validate(token string) (validationTokenClaims, error) {
+	var claims validationTokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("%w: not a JWT", ErrInvalidValidationToken)
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return claims, fmt.Errorf("%w: cannot decode header: %v", ErrInvalidValidationToken, err)
+	}
+	if header.Algorithm != "RS256" {
+		return claims, fmt.Errorf("%w: algorithm %q is not supported", ErrInvalidValidationToken, header.Algorithm)
+	}
+	v.mu.RLock()
+	publicKey, ok := v.keys[header.KeyID]
+	v.mu.RUnlock()
+	if !ok {
+		return claims, fmt.Errorf("%w: unknown key %q, the keys may have to be refreshed", ErrInvalidValidationToken, header.KeyID)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("%w: cannot base64 decode signature: %v", ErrInvalidValidationToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return claims, fmt.Errorf("%w: %v", ErrInvalidValidationToken, err)
+	}
+
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return claims, fmt.Errorf("%w: cannot decode claims: %v", ErrInvalidValidationToken, err)
+	}
+	tenantID := strings.ToLower(claims.TenantID)
+	switch {
+	case claims.Audience != v.applicationID:
+		return claims, fmt.Errorf("%w: audience %q is not the application %q", ErrInvalidValidationToken, claims.Audience, v.applicationID)
+	case claims.AuthorizedParty != ChangeNotificationPublisherID:
+		return claims, fmt.Errorf("%w: authorized party %q is not the change notification publisher", ErrInvalidValidationToken, claims.AuthorizedParty)
+	case tenantID == "" || (len(v.tenantIDs) > 0 && !v.tenantIDs[tenantID]):
+		return claims, fmt.Errorf("%w: tenant %q is not accepted", ErrInvalidValidationToken, claims.TenantID)
+	case claims.Issuer != "https://sts.windows.net/"+claims.TenantID+"/" && claims.Issuer != AzureADAuthEndpointGlobal+"/"+claims.TenantID+"/v2.0":
+		return claims, fmt.Errorf("%w: issuer %q is not the Microsoft identity platform", ErrInvalidValidationToken, claims.Issuer)
+	}
+	now := v.now()
+	if expiresAt := time.Unix(int64(claims.ExpiresAt), 0); now.After(expiresAt.Add(validationTokenClockSkew)) {
+		return claims, fmt.Errorf("%w: expired at %v", ErrInvalidValidationToken, expiresAt)
+	}
+	if notBefore := time.Unix(int64(claims.NotBefore), 0); now.Add(validationTokenClockSkew).Before(notBefore) {
+		return claims, fmt.Errorf("%w: not valid before %v", ErrInvalidValidationToken, notBefore)
+	}
+	return claims, nil
+}
+
+// ValidateCollection validates all validationTokens of the given ChangeNotificationCollection.
+// Collections with resource data must contain a validationToken for the tenant of each
+// notification. Returns an error wrapping ErrInvalidValidationToken otherwise.
+func (v *ValidationTokenValidator) ValidateCollection(collection ChangeNotificationCollection) error {
+	tenantIDs := map[string]bool{}
+	for _, token := range collection.ValidationTokens {
+		claims, err := v.validate(token)
+		if err != nil {
+			return err
+		}
+		tenantIDs[strings.ToLower(claims.TenantID)] = true
+	}
+	for _, n := range collection.Value {
+		if n.EncryptedContent == nil {
+			continue
+		}
+		if len(collection.ValidationTokens) == 0 {
+			return fmt.Errorf("%w: notifications with resource data but no validationTokens", ErrInvalidValidationToken)
+		}
+		if n.TenantID != "" && !tenantIDs[strings.ToLower(n.TenantID)] {
+			return fmt.Errorf("%w: no validationToken for tenant %q", ErrInvalidValidationToken, n.TenantID)
+		}
+	}
+	return nil
+}
+
+// decodeJWTPart json-unmarshals the base64url encoded part of a JWT into v
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,204 @@
+package msgraph
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testValidationAppID  = "11111111-2222-3333-4444-555555555555"
+	testValidationTenant = "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+)
+
+// newTestValidationToken returns a RS256 JWT with the given claims, signed with privateKey
+func newTestValidationToken(t *testing.T, privateKey *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// newTestValidationClaims returns valid claims of a validationToken, overridden by the given ones
+func newTestValidationClaims(overrides map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"aud": testValidationAppID,
+		"azp": ChangeNotificationPublisherID,
+		"iss": "https://sts.windows.net/" + testValidationTenant + "/",
+		"tid": testValidationTenant,
+		"nbf": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for key, value := range overrides {
+		claims[key] = value
+	}
+	return claims
+}
+
+// newTestJSONWebKeySet returns a JSONWebKeySet with the public key of privateKey as kid
+func newTestJSONWebKeySet(privateKey *rsa.PrivateKey, kid string) JSONWebKeySet {
+	return JSONWebKeySet{Keys: []JSONWebKey{
+		{KeyType: "EC", KeyID: "ignored"},
+		{
+			KeyType: "RSA",
+			KeyID:   kid,
+			Use:     "sig",
+			N:       base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+		},
+	}}
+}
+
+func TestValidationTokenValidator_Validate(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidationTokenValidator(testValidationAppID, newTestJSONWebKeySet(privateKey, "key-1"), strings.ToUpper(testValidationTenant))
+	if err != nil {
+		t.Fatalf("NewValidationTokenValidator() error = %v", err)
+	}
+
+	if _, err := NewValidationTokenValidator("", newTestJSONWebKeySet(privateKey, "key-1")); err == nil {
+		t.Errorf("NewValidationTokenValidator() without applicationID: error = nil, want an error")
+	}
+	provider := TokenProviderFunc(func(ctx context.Context) (Token, error) {
+		return Token{TokenType: "Bearer", ExpiresOn: time.Now().Add(time.Hour), AccessToken: "func-token"}, nil
+	})
+	if g, err := NewGraphClientWithTokenProvider(provider); err != nil {
+		t.Fatalf("NewGraphClientWithTokenProvider() error = %v", err)
+	} else if _, err := g.NewValidationTokenValidator(newTestJSONWebKeySet(privateKey, "key-1")); err == nil {
+		t.Errorf("GraphClient.NewValidationTokenValidator() without ApplicationID: error = nil, want an error")
+	}
+
+	valid := newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(nil))
+	parts := strings.Split(valid, ".")
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid v1 token", token: valid},
+		{name: "valid v2 token", token: newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{
+			"iss": AzureADAuthEndpointGlobal + "/" + testValidationTenant + "/v2.0"}))},
+		{name: "wrong audience", token: newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{"aud": "other-app"})), wantErr: true},
+		{name: "wrong authorized party", token: newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{"azp": "other-app"})), wantErr: true},
+		{name: "wrong issuer", token: newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{"iss": "https://example.com/"})), wantErr: true},
+		{name: "other tenant", token: newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{
+			"tid": "other-tenant", "iss": "https://sts.windows.net/other-tenant/"})), wantErr: true},
+		{name: "expired", token: newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), wantErr: true},
+		{name: "not yet valid", token: newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})), wantErr: true},
+		{name: "unknown key", token: newTestValidationToken(t, privateKey, "key-2", newTestValidationClaims(nil)), wantErr: true},
+		{name: "wrong signature", token: newTestValidationToken(t, otherKey, "key-1", newTestValidationClaims(nil)), wantErr: true},
+		{name: "tampered claims", token: parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"aud":"`+testValidationAppID+`"}`)) + "." + parts[2], wantErr: true},
+		{name: "unsigned", token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"key-1"}`)) + "." + parts[1] + ".", wantErr: true},
+		{name: "no JWT", token: "token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(tt.token)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidValidationToken)) {
+				t.Errorf("ValidationTokenValidator.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// the keys are rolled
+	if err := v.SetKeys(newTestJSONWebKeySet(otherKey, "key-2")); err != nil {
+		t.Fatalf("ValidationTokenValidator.SetKeys() error = %v", err)
+	}
+	if err := v.Validate(valid); err == nil {
+		t.Errorf("ValidationTokenValidator.Validate() with a removed key succeeded, want an error")
+	}
+	if err := v.Validate(newTestValidationToken(t, otherKey, "key-2", newTestValidationClaims(nil))); err != nil {
+		t.Errorf("ValidationTokenValidator.Validate() with a new key error = %v", err)
+	}
+}
+
+func TestSubscriptionHandler_validationTokens(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidationTokenValidator(testValidationAppID, newTestJSONWebKeySet(privateKey, "key-1"))
+	if err != nil {
+		t.Fatalf("NewValidationTokenValidator() error = %v", err)
+	}
+	var mu sync.Mutex
+	var users []User
+	var errs []error
+	h := NewSubscriptionHandler("secret",
+		HandlerWithValidationTokens(v),
+		HandlerWithErrorHandler(func(n ChangeNotification, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}),
+	)
+	h.Handle("users", func(ctx context.Context, n ChangeNotification) {
+		user, err := n.DecryptUser(privateKey)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		users = append(users, user)
+	})
+
+	notification := ChangeNotification{SubscriptionID: "s1", ClientState: "secret", Resource: "Users/u1", TenantID: testValidationTenant,
+		EncryptedContent: newTestEncryptedContent(t, &privateKey.PublicKey, []byte(`{"id": "u1", "displayName": "Alice"}`))}
+	post := func(tokens ...string) {
+		body, err := json.Marshal(ChangeNotificationCollection{Value: []ChangeNotification{notification}, ValidationTokens: tokens})
+		if err != nil {
+			t.Fatal(err)
+		}
+		postNotifications(t, h, string(body))
+	}
+	post()
+	post(newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{"aud": "other-app"})))
+	post(newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(map[string]interface{}{
+		"tid": "other-tenant", "iss": "https://sts.windows.net/other-tenant/"})))
+	post(newTestValidationToken(t, privateKey, "key-1", newTestValidationClaims(nil)))
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(users) != 1 || users[0].DisplayName != "Alice" {
+		t.Errorf("decrypted users = %v, want Alice of the request with a valid validationToken", users)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("errors = %v, want 3", errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrInvalidValidationToken) {
+			t.Errorf("error = %v, want %v", err, ErrInvalidValidationToken)
+		}
+	}
+}
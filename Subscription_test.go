@@ -0,0 +1,47 @@
+package msgraph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscriptionMaxLifetime(t *testing.T) {
+	tests := []struct {
+		resource            string
+		includeResourceData bool
+		want                time.Duration
+	}{
+		{resource: "users", want: 41760 * time.Minute},
+		{resource: "/groups", want: 41760 * time.Minute},
+		{resource: "users/alice@contoso.com", want: 41760 * time.Minute},
+		{resource: "users/alice@contoso.com/events", want: 4230 * time.Minute},
+		{resource: "me/mailFolders('Inbox')/messages?$filter=isRead eq false", want: 4230 * time.Minute},
+		{resource: "users/{id}/messages", includeResourceData: true, want: 1440 * time.Minute},
+		{resource: "groups/{id}/conversations", want: 4230 * time.Minute},
+		{resource: "me/drive/root", want: 42300 * time.Minute},
+		{resource: "security/alerts?$filter=status eq 'newAlert'", want: 43200 * time.Minute},
+		{resource: "chats/getAllMessages", want: 60 * time.Minute},
+		{resource: "teams/{id}/channels/{id}/messages", want: 60 * time.Minute},
+		{resource: "print/printers/{id}/jobs", want: DefaultSubscriptionLifetime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			if got := SubscriptionMaxLifetime(tt.resource, tt.includeResourceData); got != tt.want {
+				t.Errorf("SubscriptionMaxLifetime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSubscription(t *testing.T) {
+	sub := NewUserEventsSubscription("alice@contoso.com", ChangeTypeCreated+","+ChangeTypeUpdated, "https://example.com/notify", "secret")
+	if sub.Resource != "users/alice@contoso.com/events" || sub.ChangeType != "created,updated" || sub.ClientState != "secret" {
+		t.Errorf("NewUserEventsSubscription() = %v", sub)
+	}
+	if !sub.ExpirationDateTime.IsZero() {
+		t.Errorf("NewUserEventsSubscription() expires at %v, want no expiration until it is created", sub.ExpirationDateTime)
+	}
+	if _, err := sub.Renew(time.Now()); err != ErrNotGraphClientSourced {
+		t.Errorf("Subscription.Renew() error = %v, want %v", err, ErrNotGraphClientSourced)
+	}
+}
@@ -3,6 +3,8 @@ package msgraph
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +15,8 @@ type Token struct {
 	ExpiresOn   time.Time // time when the access token expires
 	Resource    string    // will most likely be https://graph.microsoft.*, hence the Service Root Endpoint
 	AccessToken string    // the access-token itself
+
+	RefreshToken string // the refresh-token of delegated flows, used to acquire a new Token without user interaction
 }
 
 func (t Token) String() string {
@@ -63,18 +67,22 @@ func (t Token) WantsToBeRefreshed() bool {
 	return !t.IsValid() || time.Now().After(t.ExpiresOn.Add(-10*time.Second))
 }
 
-// UnmarshalJSON implements the json unmarshal to be used by the json-library.
+// UnmarshalJSON implements the json unmarshal to be used by the json-library. Tokens of both the
+// TokenEndpointV1 and TokenEndpointV2 are supported: if expires_on is missing, ExpiresOn is calculated
+// from expires_in, and if not_before is missing, the token is valid immediately.
 //
 // Hint: the UnmarshalJSON also checks immediately if the token is valid, hence
 // the current time.Now() is after NotBefore and before ExpiresOn
 func (t *Token) UnmarshalJSON(data []byte) error {
 	tmp := struct {
-		TokenType   string `json:"token_type"`        // should normally be "Bearer"
-		ExpiresOn   int64  `json:"expires_on,string"` // = UNIX timestamp, parse to int64 immediately
-		NotBefore   int64  `json:"not_before,string"` // = UNIX timestamp, parse to int64 immediately
-		Resource    string `json:"resource"`          // will typically be https://graph.microsoft.com or wherever it came from
-		AccessToken string `json:"access_token"`      // the actual access token - veeery long string
-		//ExpiresIn   string `json:"expires_in"` // not used
+		TokenType   string    `json:"token_type"`   // should normally be "Bearer"
+		ExpiresOn   jsonInt64 `json:"expires_on"`   // = UNIX timestamp, only returned by the v1.0 endpoint
+		NotBefore   jsonInt64 `json:"not_before"`   // = UNIX timestamp, only returned by the v1.0 endpoint
+		ExpiresIn   jsonInt64 `json:"expires_in"`   // = seconds until the token expires
+		Resource    string    `json:"resource"`     // will typically be https://graph.microsoft.com or wherever it came from
+		AccessToken string    `json:"access_token"` // the actual access token - veeery long string
+
+		RefreshToken string `json:"refresh_token"` // only returned by delegated flows
 	}{}
 
 	// unmarshal to tmp-struct, return if error
@@ -82,11 +90,19 @@ func (t *Token) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("err on json.Unmarshal: %v | Data: %v", err, string(data))
 	}
 
+	now := time.Now()
 	t.TokenType = tmp.TokenType
-	t.ExpiresOn = time.Unix(tmp.ExpiresOn, 0)
-	t.NotBefore = time.Unix(tmp.NotBefore, 0)
+	t.ExpiresOn = time.Unix(int64(tmp.ExpiresOn), 0)
+	if tmp.ExpiresOn == 0 {
+		t.ExpiresOn = now.Add(time.Duration(tmp.ExpiresIn) * time.Second)
+	}
+	t.NotBefore = time.Unix(int64(tmp.NotBefore), 0)
+	if tmp.NotBefore == 0 {
+		t.NotBefore = now.Add(-time.Second) // valid immediately
+	}
 	t.Resource = tmp.Resource
 	t.AccessToken = tmp.AccessToken
+	t.RefreshToken = tmp.RefreshToken
 
 	if t.HasExpired() {
 		return fmt.Errorf("Access-Token ExpiresOn %v is before current system-time %v", t.ExpiresOn, time.Now())
@@ -97,3 +113,21 @@ func (t *Token) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// jsonInt64 is an int64 that can be json-unmarshalled from both a number and a string containing
+// a number, because the token endpoints are not consistent in that regard.
+type jsonInt64 int64
+
+func (i *jsonInt64) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	val, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("cannot parse %v as integer: %v", string(data), err)
+	}
+	*i = jsonInt64(val)
+	return nil
+}
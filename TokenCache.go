@@ -0,0 +1,295 @@
+package msgraph
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTokenNotCached is returned by TokenCache.Get if no Token is cached for the key
+var ErrTokenNotCached = errors.New("token is not cached")
+
+// TokenCache stores the Tokens of GraphClients, hence a still valid Token can be reused by a new
+// GraphClient instead of acquiring a new one, e.g. by short-lived processes. See WithTokenCache.
+//
+// Keys identify the flow, tenant, application, account and scopes of a Token, see GraphClient.TokenCacheKey.
+// Implementations must be safe for concurrent use.
+type TokenCache interface {
+	// Get returns the Token cached for key, which may have expired, or ErrTokenNotCached
+	Get(key string) (Token, error)
+	// Set caches the Token for key, replacing the cached one, if any
+	Set(key string, token Token) error
+}
+
+// WithTokenCache - look up the Token of the GraphClient in the given TokenCache before asking
+// the TokenProvider and cache every acquired Token. Caching is only possible for the client
+// credentials flow of NewGraphClient and the TokenProviders of this package. Tokens of delegated
+// flows are cached per account, see DelegatedWithAccount, and on-behalf-of Tokens per assertion.
+var WithTokenCache = func(cache TokenCache) GraphClientOption {
+	return func(g *GraphClient) {
+		g.tokenCache = cache
+	}
+}
+
+// Kinds of flows within a tokenIdentity, hence Tokens of different flows never share a cache entry
+const (
+	tokenKindClientCredentials = "client_credentials"
+	tokenKindDelegated         = "delegated"
+	tokenKindOnBehalfOf        = "on_behalf_of"
+	tokenKindManagedIdentity   = "managed_identity"
+)
+
+// tokenIdentity identifies whose Tokens a TokenProvider acquires
+type tokenIdentity struct {
+	kind          string // the flow, e.g. tokenKindDelegated
+	tenantID      string
+	applicationID string
+	account       string // the user of delegated flows or the hash of the on-behalf-of assertion
+}
+
+// tokenCacheIdentity is implemented by TokenProviders whose Tokens can be cached. It returns the
+// flow, tenant, application and account the Tokens are acquired for.
+type tokenCacheIdentity interface {
+	cacheIdentity() tokenIdentity
+}
+
+// tokenCacheSeeder is implemented by TokenProviders that can continue with a cached Token, e.g.
+// redeem its refresh-token instead of prompting the user again.
+type tokenCacheSeeder interface {
+	seedToken(token Token)
+}
+
+// TokenCacheKey returns the key of the Tokens of the GraphClient within a TokenCache, which
+// consists of the Azure AD authentication endpoint, the kind of flow, tenant, application, account
+// and the requested scopes, or the resource for TokenEndpointV1. Returns an empty string if the
+// Tokens cannot be cached, e.g. for a TokenProviderFunc.
+func (g *GraphClient) TokenCacheKey() string {
+	identity := tokenIdentity{kind: tokenKindClientCredentials, tenantID: g.TenantID, applicationID: g.ApplicationID}
+	if g.tokenProvider != nil {
+		provider, ok := g.tokenProvider.(tokenCacheIdentity)
+		if !ok {
+			return ""
+		}
+		identity = provider.cacheIdentity()
+	}
+	scope := g.serviceRootEndpoint
+	if g.getTokenEndpointVersion() == TokenEndpointV2 {
+		scope = strings.Join(g.getScopes(), " ")
+	}
+	return strings.Join([]string{strings.TrimSuffix(g.azureADAuthEndpoint, "/"), identity.kind, identity.tenantID,
+		identity.applicationID, identity.account, scope}, "|")
+}
+
+// NewMemoryTokenCache returns a TokenCache that keeps the Tokens in memory, e.g. to share them
+// between multiple GraphClients of the same process.
+func NewMemoryTokenCache() TokenCache {
+	return &memoryTokenCache{tokens: make(map[string]Token)}
+}
+
+// memoryTokenCache keeps the Tokens in a map
+type memoryTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]Token
+}
+
+func (c *memoryTokenCache) Get(key string) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	token, ok := c.tokens[key]
+	if !ok {
+		return Token{}, ErrTokenNotCached
+	}
+	return token, nil
+}
+
+func (c *memoryTokenCache) Set(key string, token Token) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, t := range c.tokens {
+		if t.HasExpired() && t.RefreshToken == "" {
+			delete(c.tokens, k)
+		}
+	}
+	c.tokens[key] = token
+	return nil
+}
+
+// NewFileTokenCache returns a TokenCache that stores the Tokens in the given file, encrypted with
+// AES-GCM and the given key, which must be 16, 24 or 32 bytes long to select AES-128, AES-192 or
+// AES-256. Access to the file is guarded by a lock file "<path>.lock", hence multiple processes
+// can share it. The file is created with the permissions 0600 if it does not exist yet.
+//
+// A file that cannot be decrypted with the key, e.g. because the key changed, is treated as empty
+// and replaced on the next Set. Expired Tokens without refresh-token are removed on every Set.
+func NewFileTokenCache(path string, key []byte) (TokenCache, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token cache key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &fileTokenCache{path: path, aead: aead}, nil
+}
+
+// fileLockTimeout is how long a fileTokenCache waits for the lock file before it is regarded as
+// stale, hence left behind by a crashed process, and removed.
+const fileLockTimeout = 10 * time.Second
+
+// fileTokenCache stores the Tokens AES-GCM encrypted in a file
+type fileTokenCache struct {
+	mu   sync.Mutex // guards the file within the process, the lock file guards it across processes
+	path string
+	aead cipher.AEAD
+}
+
+// cachedToken is the json representation of a Token within a fileTokenCache
+type cachedToken struct {
+	TokenType    string    `json:"tokenType"`
+	NotBefore    time.Time `json:"notBefore"`
+	ExpiresOn    time.Time `json:"expiresOn"`
+	Resource     string    `json:"resource,omitempty"`
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+}
+
+func (c *fileTokenCache) Get(key string) (Token, error) {
+	var tokens map[string]cachedToken
+	err := c.withLock(func() error {
+		var err error
+		tokens, err = c.read()
+		return err
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	cached, ok := tokens[key]
+	if !ok {
+		return Token{}, ErrTokenNotCached
+	}
+	return Token(cached), nil
+}
+
+func (c *fileTokenCache) Set(key string, token Token) error {
+	return c.withLock(func() error {
+		tokens, err := c.read()
+		if err != nil { // e.g. encrypted with another key, start over
+			tokens = make(map[string]cachedToken)
+		}
+		for k, t := range tokens {
+			if Token(t).HasExpired() && t.RefreshToken == "" {
+				delete(tokens, k)
+			}
+		}
+		tokens[key] = cachedToken(token)
+		return c.write(tokens)
+	})
+}
+
+// read returns the decrypted Tokens of the file, none if it does not exist
+func (c *fileTokenCache) read() (map[string]cachedToken, error) {
+	tokens := make(map[string]cachedToken)
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot read token cache: %w", err)
+	}
+	if len(data) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("cannot decrypt token cache %v: file is too short", c.path)
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(c.path))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt token cache %v: %w", c.path, err)
+	}
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, fmt.Errorf("cannot json-unmarshal token cache %v: %w", c.path, err)
+	}
+	return tokens, nil
+}
+
+// write encrypts the Tokens and replaces the file atomically
+func (c *fileTokenCache) write(tokens map[string]cachedToken) error {
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("cannot write token cache: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	if _, err := tmp.Write(c.aead.Seal(nonce, nonce, plaintext, []byte(c.path))); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write token cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write token cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("cannot write token cache: %w", err)
+	}
+	return nil
+}
+
+// withLock calls f while holding the lock file of the cache
+func (c *fileTokenCache) withLock(f func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lockPath := c.path + ".lock"
+	start := time.Now()
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			lock.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("cannot lock token cache: %w", err)
+		}
+		if time.Since(start) > fileLockTimeout {
+			if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > fileLockTimeout {
+				removeStaleLock(lockPath, info) // stale lock of a crashed process
+				continue
+			}
+			return fmt.Errorf("cannot lock token cache: %v is locked by another process", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer os.Remove(lockPath)
+	return f()
+}
+
+// removeStaleLock removes the lock file if it is still the stale one. Several processes may regard
+// it as stale at the same time, hence it is renamed first, which only one of them can do. If the
+// renamed file is not the stale one, another process has taken the lock meanwhile, which is restored.
+func removeStaleLock(lockPath string, stale os.FileInfo) {
+	suffix, err := randomBase64URL(8)
+	if err != nil {
+		return
+	}
+	stalePath := lockPath + ".stale-" + suffix
+	if os.Rename(lockPath, stalePath) != nil {
+		return // removed by another process already
+	}
+	// the inode of a removed file may be reused by a new one, hence its modification time is compared too
+	if info, err := os.Stat(stalePath); err == nil && (!os.SameFile(info, stale) || !info.ModTime().Equal(stale.ModTime())) {
+		os.Link(stalePath, lockPath) // fails only if yet another lock file has been created
+	}
+	os.Remove(stalePath)
+}
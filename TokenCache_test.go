@@ -0,0 +1,283 @@
+package msgraph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileTokenCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.bin")
+	key := bytes.Repeat([]byte{1}, 32)
+	cache, err := NewFileTokenCache(path, key)
+	if err != nil {
+		t.Fatalf("NewFileTokenCache() error = %v", err)
+	}
+	if _, err := NewFileTokenCache(path, []byte("short")); err == nil {
+		t.Errorf("NewFileTokenCache() with a 5 byte key: error = nil, want an error")
+	}
+
+	if _, err := cache.Get("tenant|app|scope"); !errors.Is(err, ErrTokenNotCached) {
+		t.Errorf("Get() of an empty cache: error = %v, want ErrTokenNotCached", err)
+	}
+	token := Token{TokenType: "Bearer", NotBefore: time.Now().Add(-time.Minute).Round(0), ExpiresOn: time.Now().Add(time.Hour).Round(0),
+		AccessToken: "secret-access-token", RefreshToken: "secret-refresh-token"}
+	expired := Token{TokenType: "Bearer", ExpiresOn: time.Now().Add(-time.Hour).Round(0), AccessToken: "expired"}
+	// the expired Token is set last, otherwise it would already be removed by the next Set
+	if err := cache.Set("tenant|app|scope", token); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := cache.Set("tenant|app|expired", expired); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// another process with the same key reads the Tokens
+	other, _ := NewFileTokenCache(path, key)
+	if got, err := other.Get("tenant|app|scope"); err != nil || !got.ExpiresOn.Equal(token.ExpiresOn) || got.AccessToken != token.AccessToken || got.RefreshToken != token.RefreshToken {
+		t.Errorf("Get() = %v, %v, want %v", got, err, token)
+	}
+	if got, err := other.Get("tenant|app|expired"); err != nil || got.AccessToken != "expired" {
+		t.Errorf("Get() of expired Token = %v, %v, want expired Token", got, err)
+	}
+	// expired Tokens without refresh-token are removed on Set
+	if err := other.Set("tenant|app|other", token); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, err := cache.Get("tenant|app|expired"); !errors.Is(err, ErrTokenNotCached) {
+		t.Errorf("Get() of removed Token: error = %v, want ErrTokenNotCached", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil || bytes.Contains(data, []byte("secret")) {
+		t.Errorf("token cache file is not encrypted: %q, %v", data, err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file has not been removed: %v", err)
+	}
+
+	// a cache with another key cannot decrypt the file, but replaces it
+	wrongKey, _ := NewFileTokenCache(path, bytes.Repeat([]byte{2}, 32))
+	if _, err := wrongKey.Get("tenant|app|scope"); err == nil || errors.Is(err, ErrTokenNotCached) {
+		t.Errorf("Get() with the wrong key: error = %v, want a decryption error", err)
+	}
+	if err := wrongKey.Set("tenant|app|scope", token); err != nil {
+		t.Errorf("Set() with the wrong key: error = %v", err)
+	}
+	if _, err := wrongKey.Get("tenant|app|scope"); err != nil {
+		t.Errorf("Get() after replacing the file: error = %v", err)
+	}
+}
+
+func TestRemoveStaleLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "tokens.bin.lock")
+	createLock := func(modTime time.Time) os.FileInfo {
+		if err := ioutil.WriteFile(lockPath, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(lockPath, modTime, modTime)
+		info, err := os.Stat(lockPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	stale := createLock(time.Now().Add(-time.Minute))
+	removeStaleLock(lockPath, stale)
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("stale lock file has not been removed: %v", err)
+	}
+
+	// another process removed the stale lock and took the lock meanwhile
+	stale = createLock(time.Now().Add(-time.Minute))
+	os.Remove(lockPath)
+	taken := createLock(time.Now())
+	removeStaleLock(lockPath, stale)
+	if info, err := os.Stat(lockPath); err != nil || !os.SameFile(info, taken) {
+		t.Errorf("lock file of another process has been removed: %v", err)
+	}
+	if matches, _ := filepath.Glob(lockPath + ".stale-*"); len(matches) != 0 {
+		t.Errorf("renamed lock files have not been removed: %v", matches)
+	}
+}
+
+func TestFileTokenCache_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.bin")
+	key := bytes.Repeat([]byte{1}, 16)
+	token := Token{TokenType: "Bearer", ExpiresOn: time.Now().Add(time.Hour), AccessToken: "token"}
+
+	// every goroutine uses its own cache, just like separate processes
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cache, _ := NewFileTokenCache(path, key)
+			if err := cache.Set(fmt.Sprintf("key-%v", i), token); err != nil {
+				t.Errorf("Set() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	cache, _ := NewFileTokenCache(path, key)
+	for i := 0; i < 10; i++ {
+		if _, err := cache.Get(fmt.Sprintf("key-%v", i)); err != nil {
+			t.Errorf("Get(key-%v) error = %v, lost update", i, err)
+		}
+	}
+}
+
+func TestGraphClient_WithTokenCache(t *testing.T) {
+	var mu sync.Mutex
+	var tokenRequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokenRequests++
+		mu.Unlock()
+		writeTestToken(w, r.FormValue("resource"), fmt.Sprintf("access-token-%v", tokenRequests))
+	}))
+	defer srv.Close()
+
+	fileCache, err := NewFileTokenCache(filepath.Join(t.TempDir(), "tokens.bin"), bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("NewFileTokenCache() error = %v", err)
+	}
+	for name, cache := range map[string]TokenCache{"memory": NewMemoryTokenCache(), "file": fileCache} {
+		t.Run(name, func(t *testing.T) {
+			tokenRequests = 0
+			newClient := func(applicationID string) *GraphClient {
+				g, err := NewGraphClientWithCustomEndpoint("test-tenant", applicationID, "test-secret", srv.URL, srv.URL, WithTokenCache(cache))
+				if err != nil {
+					t.Fatalf("NewGraphClientWithCustomEndpoint() error = %v", err)
+				}
+				return g
+			}
+			first, second := newClient("app-1"), newClient("app-1")
+			if tokenRequests != 1 || first.token.AccessToken != "access-token-1" || second.token.AccessToken != "access-token-1" {
+				t.Errorf("%v token requests for two GraphClients of the same application, want 1", tokenRequests)
+			}
+			if other := newClient("app-2"); tokenRequests != 2 || other.token.AccessToken != "access-token-2" {
+				t.Errorf("%v token requests for another application, want 2", tokenRequests)
+			}
+
+			// a Token that wants to be refreshed is not taken from the cache
+			expiring := first.token
+			expiring.ExpiresOn = time.Now().Add(5 * time.Second)
+			cache.Set(first.TokenCacheKey(), expiring)
+			if g := newClient("app-1"); tokenRequests != 3 || g.token.AccessToken != "access-token-3" {
+				t.Errorf("%v token requests after the cached Token expired, want 3", tokenRequests)
+			}
+			if cached, _ := cache.Get(first.TokenCacheKey()); cached.AccessToken != "access-token-3" {
+				t.Errorf("cached Token = %v, want the refreshed Token", cached.AccessToken)
+			}
+		})
+	}
+
+	// Tokens of a TokenProviderFunc are not cached
+	provider := TokenProviderFunc(func(ctx context.Context) (Token, error) {
+		return Token{TokenType: "Bearer", ExpiresOn: time.Now().Add(time.Hour), AccessToken: "func-token"}, nil
+	})
+	cache := NewMemoryTokenCache()
+	g, err := NewGraphClientWithTokenProvider(provider, WithTokenCache(cache), WithCustomEndpoint(srv.URL, srv.URL))
+	if err != nil || g.TokenCacheKey() != "" || len(cache.(*memoryTokenCache).tokens) != 0 {
+		t.Errorf("TokenProviderFunc: err = %v, TokenCacheKey() = %q, %v cached Tokens", err, g.TokenCacheKey(), len(cache.(*memoryTokenCache).tokens))
+	}
+}
+
+func TestGraphClient_WithTokenCacheDelegated(t *testing.T) {
+	handler := &delegatedHandler{t: t}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "tokens.bin")
+	var prompted int
+	newClient := func() *GraphClient {
+		provider := NewDeviceCodeProvider("delegated-tenant", "public-client", func(context.Context, DeviceCode) error {
+			prompted++
+			return nil
+		})
+		cache, _ := NewFileTokenCache(path, bytes.Repeat([]byte{1}, 32))
+		g, err := NewGraphClientWithTokenProvider(provider, WithTokenCache(cache), WithCustomEndpoint(srv.URL, srv.URL), WithTokenEndpointVersion(TokenEndpointV2))
+		if err != nil {
+			t.Fatalf("NewGraphClientWithTokenProvider() error = %v", err)
+		}
+		return g
+	}
+
+	first := newClient()
+	expireToken(first)
+	first.tokenCache.Set(first.TokenCacheKey(), first.token)
+
+	// the next process redeems the cached refresh-token instead of prompting the user again
+	second := newClient()
+	if prompted != 1 || second.token.AccessToken != "refreshed-access-token" || second.token.RefreshToken != "refresh-token-1" {
+		t.Errorf("prompted %v times, Token = %v, want the refreshed Token", prompted, second.token)
+	}
+}
+
+func TestGraphClient_WithTokenCacheIsolation(t *testing.T) {
+	handler := &delegatedHandler{t: t}
+	var appTokens int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") == "client_credentials" {
+			appTokens++
+			writeDelegatedToken(w, "app-access-token", "")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	cache := NewMemoryTokenCache()
+	newDelegatedClient := func(account string, prompted *int) *GraphClient {
+		provider := NewDeviceCodeProvider("delegated-tenant", "public-client", func(context.Context, DeviceCode) error {
+			*prompted++
+			return nil
+		}, DelegatedWithAccount(account))
+		g, err := NewGraphClientWithTokenProvider(provider, WithTokenCache(cache), WithCustomEndpoint(srv.URL, srv.URL), WithTokenEndpointVersion(TokenEndpointV2))
+		if err != nil {
+			t.Fatalf("NewGraphClientWithTokenProvider() error = %v", err)
+		}
+		return g
+	}
+	newSecretClient := func() *GraphClient {
+		g, err := NewGraphClientWithCustomEndpoint("delegated-tenant", "public-client", "test-secret", srv.URL, srv.URL,
+			WithTokenCache(cache), WithTokenEndpointVersion(TokenEndpointV2))
+		if err != nil {
+			t.Fatalf("NewGraphClientWithCustomEndpoint() error = %v", err)
+		}
+		return g
+	}
+
+	// the secret and the delegated client share tenant, application and scope, but not their Tokens
+	var alicePrompted, bobPrompted int
+	app := newSecretClient()
+	alice := newDelegatedClient("alice@contoso.com", &alicePrompted)
+	if app.token.AccessToken != "app-access-token" || alice.token.AccessToken != "device-access-token" || alicePrompted != 1 {
+		t.Errorf("app Token = %v, delegated Token = %v, prompted %v times, want separate Tokens", app.token.AccessToken, alice.token.AccessToken, alicePrompted)
+	}
+	if app.TokenCacheKey() == alice.TokenCacheKey() {
+		t.Errorf("TokenCacheKey() of the secret and the delegated client = %q, want different keys", app.TokenCacheKey())
+	}
+	if g := newSecretClient(); g.token.AccessToken != "app-access-token" || appTokens != 1 {
+		t.Errorf("second secret client Token = %v after %v token requests, want the cached app Token", g.token.AccessToken, appTokens)
+	}
+
+	// another user of the same public client is prompted instead of getting the cached Token
+	if bob := newDelegatedClient("bob@contoso.com", &bobPrompted); bobPrompted != 1 || bob.TokenCacheKey() == alice.TokenCacheKey() {
+		t.Errorf("other account prompted %v times with TokenCacheKey() %q, want its own Token", bobPrompted, bob.TokenCacheKey())
+	}
+	if newDelegatedClient("Alice@Contoso.com", &alicePrompted); alicePrompted != 1 {
+		t.Errorf("same account prompted %v times, want the cached Token", alicePrompted)
+	}
+}
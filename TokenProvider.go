@@ -0,0 +1,333 @@
+package msgraph
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenProvider acquires the Tokens a GraphClient uses to authenticate its API-calls. The
+// GraphClient caches the Token and only asks the TokenProvider for a new one when the current
+// Token wants to be refreshed, see Token.WantsToBeRefreshed.
+//
+// TokenProviders of this package request the Token from the Azure AD authentication endpoint,
+// using the endpoints and the http.Client of the GraphClient they are passed to with
+// WithTokenProvider. Do not pass one of them to multiple GraphClients with different settings.
+type TokenProvider interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// TokenProviderFunc is an adapter to allow the use of ordinary functions as TokenProvider, e.g. to
+// acquire Tokens from a vault.
+type TokenProviderFunc func(ctx context.Context) (Token, error)
+
+// Token calls f(ctx)
+func (f TokenProviderFunc) Token(ctx context.Context) (Token, error) {
+	return f(ctx)
+}
+
+// NewStaticTokenProvider returns a TokenProvider that always returns the given, pre-acquired
+// Token. API-calls fail as soon as the Token expired.
+func NewStaticTokenProvider(token Token) TokenProvider {
+	return TokenProviderFunc(func(ctx context.Context) (Token, error) {
+		if token.HasExpired() {
+			return Token{}, fmt.Errorf("static Token expired at %v", token.ExpiresOn)
+		}
+		return token, nil
+	})
+}
+
+// NewClientSecretProvider returns a TokenProvider that acquires Tokens with the client credentials
+// flow, authenticating the application with a client secret. This is what NewGraphClient uses.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth2-client-creds-grant-flow
+func NewClientSecretProvider(tenantID, applicationID, clientSecret string) TokenProvider {
+	return &clientCredentialsProvider{tenantID: tenantID, applicationID: applicationID, credential: clientSecretCredential(clientSecret)}
+}
+
+// NewClientCertificateProvider returns a TokenProvider that acquires Tokens with the client
+// credentials flow, authenticating the application with a client assertion: a JWT signed with the
+// privateKey of the given certificate, which has been uploaded to the app registration. The
+// privateKey must be a RSA key, e.g. a *rsa.PrivateKey. Use ParseCertificate to load both from a
+// PEM or PKCS#12 file. The client assertion is signed with RS256 unless CertificateWithPS256 is passed.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/active-directory-certificate-credentials
+func NewClientCertificateProvider(tenantID, applicationID string, certificate *x509.Certificate, privateKey crypto.Signer, opts ...CertificateOption) TokenProvider {
+	credential := &clientCertificateCredential{applicationID: applicationID, certificate: certificate, privateKey: privateKey}
+	for idx := range opts {
+		opts[idx](credential)
+	}
+	return &clientCredentialsProvider{tenantID: tenantID, applicationID: applicationID, credential: credential}
+}
+
+var (
+	// WithTokenProvider - acquire the Tokens of the GraphClient with the given TokenProvider instead of
+	// the client credentials flow with the TenantID, ApplicationID and ClientSecret.
+	WithTokenProvider = func(provider TokenProvider) GraphClientOption {
+		return func(g *GraphClient) {
+			g.tokenProvider = provider
+			if p, ok := provider.(interface{ setGraphClient(*GraphClient) }); ok {
+				p.setGraphClient(g)
+			}
+		}
+	}
+
+	// WithTokenEndpointVersion - request Tokens from the given version of the Azure AD token endpoint.
+	// Defaults to TokenEndpointV2 for AzureADAuthEndpointGlobal, AzureADAuthEndpointUSGov and
+	// AzureADAuthEndpointChina, and to TokenEndpointV1 for all others.
+	WithTokenEndpointVersion = func(version TokenEndpointVersion) GraphClientOption {
+		return func(g *GraphClient) {
+			g.tokenEndpointVersion = version
+		}
+	}
+
+	// WithScopes - request Tokens for the given scopes from the TokenEndpointV2. Defaults to the
+	// scope "<ServiceRootEndpoint>/.default", hence all permissions granted to the application, which
+	// is the only valid scope for the client credentials flow. Ignored for TokenEndpointV1.
+	WithScopes = func(scopes ...string) GraphClientOption {
+		return func(g *GraphClient) {
+			g.scopes = scopes
+		}
+	}
+
+	// WithCustomEndpoint - use the given Azure AD authentication endpoint and Microsoft Graph service
+	// root endpoint, e.g. AzureADAuthEndpointUSGov and ServiceRootEndpointUSGov. See
+	// NewGraphClientWithCustomEndpoint for available endpoints.
+	WithCustomEndpoint = func(azureADAuthEndpoint, serviceRootEndpoint string) GraphClientOption {
+		return func(g *GraphClient) {
+			g.azureADAuthEndpoint = azureADAuthEndpoint
+			g.serviceRootEndpoint = serviceRootEndpoint
+		}
+	}
+)
+
+// NewGraphClientWithTokenProvider creates a new GraphClient instance that acquires its Tokens with the
+// given TokenProvider and grabs a token. Returns an error if the token cannot be initialized. The
+// default ms graph API global endpoint is used unless another one is set with WithCustomEndpoint.
+func NewGraphClientWithTokenProvider(provider TokenProvider, opts ...GraphClientOption) (*GraphClient, error) {
+	return NewGraphClientWithCustomEndpoint("", "", "", AzureADAuthEndpointGlobal, ServiceRootEndpointGlobal,
+		append([]GraphClientOption{WithTokenProvider(provider)}, opts...)...)
+}
+
+// getTokenProvider returns the TokenProvider of the GraphClient. If none is set, Tokens are acquired
+// with the client credentials flow using the TenantID, ApplicationID and ClientSecret.
+func (g *GraphClient) getTokenProvider() TokenProvider {
+	if g.tokenProvider != nil {
+		return g.tokenProvider
+	}
+	return &clientCredentialsProvider{tenantID: g.TenantID, applicationID: g.ApplicationID, credential: clientSecretCredential(g.ClientSecret), g: g}
+}
+
+// defaultTokenEndpointVersions contains the TokenEndpointVersion used by default for the Azure AD
+// authentication endpoints, TokenEndpointV1 is used for all others.
+var defaultTokenEndpointVersions = map[string]TokenEndpointVersion{
+	AzureADAuthEndpointGlobal:  TokenEndpointV2,
+	AzureADAuthEndpointUSGov:   TokenEndpointV2,
+	AzureADAuthEndpointChina:   TokenEndpointV2,
+	AzureADAuthEndpointGermany: TokenEndpointV1,
+}
+
+// getTokenEndpointVersion returns the TokenEndpointVersion of the GraphClient, or the default one
+// of its Azure AD authentication endpoint if none is set.
+func (g *GraphClient) getTokenEndpointVersion() TokenEndpointVersion {
+	if g.tokenEndpointVersion != "" {
+		return g.tokenEndpointVersion
+	}
+	if version, ok := defaultTokenEndpointVersions[strings.TrimSuffix(g.azureADAuthEndpoint, "/")]; ok {
+		return version
+	}
+	return TokenEndpointV1
+}
+
+// getScopes returns the scopes set with WithScopes, "<ServiceRootEndpoint>/.default" if none are set.
+func (g *GraphClient) getScopes() []string {
+	if len(g.scopes) > 0 {
+		return g.scopes
+	}
+	return []string{strings.TrimSuffix(g.serviceRootEndpoint, "/") + "/.default"}
+}
+
+// clientCredential authenticates a confidential client application at the token endpoint
+type clientCredential interface {
+	// addTo adds the credential to the form data of a token request to tokenURL
+	addTo(data url.Values, tokenURL string) error
+}
+
+// clientSecretCredential authenticates with a client secret
+type clientSecretCredential string
+
+func (c clientSecretCredential) addTo(data url.Values, tokenURL string) error {
+	data.Set("client_secret", string(c))
+	return nil
+}
+
+// clientCertificateCredential authenticates with a client assertion signed by the private key of a certificate
+type clientCertificateCredential struct {
+	applicationID string
+	certificate   *x509.Certificate
+	privateKey    crypto.Signer
+	pss           bool // sign with PS256 instead of RS256, see CertificateWithPS256
+}
+
+func (c *clientCertificateCredential) addTo(data url.Values, tokenURL string) error {
+	assertion, err := c.clientAssertion(tokenURL)
+	if err != nil {
+		return err
+	}
+	data.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	data.Set("client_assertion", assertion)
+	return nil
+}
+
+// clientAssertion returns a JWT for the given audience, signed with RS256 and the SHA-1 thumbprint
+// of the certificate as x5t, or with PS256 and the SHA-256 thumbprint as x5t#S256.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/active-directory-certificate-credentials#assertion-format
+func (c *clientCertificateCredential) clientAssertion(audience string) (string, error) {
+	if c.certificate == nil || c.privateKey == nil {
+		return "", fmt.Errorf("certificate and private key are required for a client assertion")
+	}
+	var header = map[string]string{"typ": "JWT"}
+	var signerOpts crypto.SignerOpts = crypto.SHA256
+	if c.pss {
+		thumbprint := sha256.Sum256(c.certificate.Raw)
+		header["alg"] = "PS256"
+		header["x5t#S256"] = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+		signerOpts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	} else {
+		thumbprint := sha1.Sum(c.certificate.Raw)
+		header["alg"] = "RS256"
+		header["x5t"] = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": audience,
+		"iss": c.applicationID,
+		"sub": c.applicationID,
+		"jti": hex.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := c.privateKey.Sign(rand.Reader, digest[:], signerOpts)
+	if err != nil {
+		return "", fmt.Errorf("cannot sign client assertion: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// clientCredentialsProvider acquires Tokens with the client credentials flow
+type clientCredentialsProvider struct {
+	tenantID      string
+	applicationID string
+	credential    clientCredential
+	g             *GraphClient // the GraphClient whose endpoints and http.Client are used
+}
+
+func (p *clientCredentialsProvider) setGraphClient(g *GraphClient) {
+	p.g = g
+}
+
+func (p *clientCredentialsProvider) cacheIdentity() tokenIdentity {
+	return tokenIdentity{kind: tokenKindClientCredentials, tenantID: p.tenantID, applicationID: p.applicationID}
+}
+
+func (p *clientCredentialsProvider) Token(ctx context.Context) (Token, error) {
+	data := url.Values{}
+	data.Add("grant_type", "client_credentials")
+	data.Add("client_id", p.applicationID)
+	return tokenRequestClient(p.g).requestToken(ctx, p.tenantID, data, p.credential)
+}
+
+// tokenRequestClient returns g, or a GraphClient with the default settings if g is nil, hence if a
+// TokenProvider is used without GraphClient.
+func tokenRequestClient(g *GraphClient) *GraphClient {
+	if g == nil {
+		g = &GraphClient{}
+		g.makeSureURLsAreSet()
+	}
+	return g
+}
+
+// requestToken requests a Token from the Azure AD authentication endpoint of the given tenant. The
+// form data must contain the grant_type and its parameters, the scopes - or the resource of the
+// Service Root Endpoint for TokenEndpointV1 - are added unless set as well as the credential, if any.
+func (g *GraphClient) requestToken(ctx context.Context, tenantID string, data url.Values, credential clientCredential) (Token, error) {
+	tokenURL, err := g.oauth2URL(tenantID, "token")
+	if err != nil {
+		return Token{}, err
+	}
+	if g.getTokenEndpointVersion() == TokenEndpointV2 {
+		if data.Get("scope") == "" {
+			data.Set("scope", strings.Join(g.getScopes(), " "))
+		}
+	} else {
+		data.Set("resource", g.serviceRootEndpoint)
+	}
+	if credential != nil {
+		if err := credential.addTo(data, tokenURL); err != nil {
+			return Token{}, err
+		}
+	}
+
+	var token Token
+	err = g.postOAuth2Form(ctx, tokenURL, data, &token)
+	return token, err
+}
+
+// oauth2URL returns the absolute URL of the given OAuth 2.0 endpoint of the tenant, e.g. token or
+// devicecode, for the TokenEndpointVersion of the GraphClient.
+func (g *GraphClient) oauth2URL(tenantID, endpoint string) (string, error) {
+	if tenantID == "" {
+		return "", fmt.Errorf("tenant ID is empty")
+	}
+	u, err := url.ParseRequestURI(g.azureADAuthEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse URI: %v", err)
+	}
+	if g.getTokenEndpointVersion() == TokenEndpointV2 {
+		u.Path = fmt.Sprintf("/%v/oauth2/v2.0/%v", tenantID, endpoint)
+	} else {
+		u.Path = fmt.Sprintf("/%v/oauth2/%v", tenantID, endpoint)
+	}
+	return u.String(), nil
+}
+
+// postOAuth2Form posts the form data to the given OAuth 2.0 endpoint and json-unmarshals the response into v
+func (g *GraphClient) postOAuth2Form(ctx context.Context, endpointURL string, data url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewBufferString(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("HTTP Request Error: %v", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	return g.performRequest(req, v) // perform the prepared request
+}
@@ -0,0 +1,106 @@
+package msgraph
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// CertificateOption configures how the client assertion of NewClientCertificateProvider is signed
+type CertificateOption func(c *clientCertificateCredential)
+
+// CertificateWithPS256 - sign the client assertion with RSASSA-PSS (PS256) and identify the certificate
+// by its SHA-256 thumbprint (x5t#S256) instead of RS256 and the SHA-1 thumbprint (x5t).
+var CertificateWithPS256 = func() CertificateOption {
+	return func(c *clientCertificateCredential) {
+		c.pss = true
+	}
+}
+
+// NewGraphClientWithCertificate creates a new GraphClient instance that authenticates the application
+// with a certificate instead of a client secret, and grabs a token. certData contains the certificate
+// and its RSA private key, either PEM encoded or as PKCS#12 (.pfx) file protected by the given
+// password, see ParseCertificate. Returns an error if the certificate cannot be parsed or the token
+// cannot be initialized. The token is refreshed automatically, just like with NewGraphClient.
+//
+// The default ms graph API global endpoint is used unless another one is set with WithCustomEndpoint.
+// Use NewClientCertificateProvider with NewGraphClientWithTokenProvider to sign with PS256.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/active-directory-certificate-credentials
+func NewGraphClientWithCertificate(tenantID, applicationID string, certData []byte, password string, opts ...GraphClientOption) (*GraphClient, error) {
+	certificate, privateKey, err := ParseCertificate(certData, password)
+	if err != nil {
+		return nil, err
+	}
+	provider := NewClientCertificateProvider(tenantID, applicationID, certificate, privateKey)
+	return NewGraphClientWithCustomEndpoint(tenantID, applicationID, "", AzureADAuthEndpointGlobal, ServiceRootEndpointGlobal,
+		append([]GraphClientOption{WithTokenProvider(provider)}, opts...)...)
+}
+
+// ParseCertificate parses a certificate and its RSA private key, either from PEM blocks - with the
+// private key as unencrypted "PRIVATE KEY" or "RSA PRIVATE KEY" - or from a PKCS#12 (.pfx) file
+// protected by the given password. The password is ignored for PEM data. If multiple certificates
+// are given, the one matching the private key is returned.
+func ParseCertificate(data []byte, password string) (*x509.Certificate, crypto.Signer, error) {
+	var certificates []*x509.Certificate
+	var privateKey interface{}
+	var err error
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		certificates, privateKey, err = parsePEM(data)
+	} else {
+		var certificate *x509.Certificate
+		privateKey, certificate, _, err = pkcs12.DecodeChain(data, password)
+		certificates = []*x509.Certificate{certificate}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rsaKey, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("private key must be a RSA key, got %T", privateKey)
+	}
+	for _, certificate := range certificates {
+		if publicKey, ok := certificate.PublicKey.(*rsa.PublicKey); ok && publicKey.Equal(&rsaKey.PublicKey) {
+			return certificate, rsaKey, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no certificate matches the private key")
+}
+
+// parsePEM returns all certificates and the private key of the given PEM blocks
+func parsePEM(data []byte) ([]*x509.Certificate, interface{}, error) {
+	var certificates []*x509.Certificate
+	var privateKey interface{}
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		var err error
+		switch block.Type {
+		case "CERTIFICATE":
+			var certificate *x509.Certificate
+			if certificate, err = x509.ParseCertificate(block.Bytes); err == nil {
+				certificates = append(certificates, certificate)
+			}
+		case "PRIVATE KEY":
+			privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			err = fmt.Errorf("encrypted PEM private keys are not supported, use PKCS#12 instead")
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot parse PEM block %v: %w", block.Type, err)
+		}
+	}
+	if len(certificates) == 0 {
+		return nil, nil, fmt.Errorf("no PEM block CERTIFICATE found")
+	}
+	if privateKey == nil {
+		return nil, nil, fmt.Errorf("no PEM block PRIVATE KEY or RSA PRIVATE KEY found")
+	}
+	return certificates, privateKey, nil
+}
@@ -0,0 +1,126 @@
+package msgraph
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestParseCertificate(t *testing.T) {
+	cert, key := newTestCertificate(t)
+	otherCert, _ := newTestCertificate(t)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	otherCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherCert.Raw})
+	pkcs1PEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Cannot marshal PKCS#8 key: %v", err)
+	}
+	pkcs8PEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	ecPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER})
+	pfx, err := pkcs12.Encode(rand.Reader, key, cert, []*x509.Certificate{otherCert}, "pfx-password")
+	if err != nil {
+		t.Fatalf("Cannot encode PKCS#12: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		password string
+		wantErr  bool
+	}{
+		{name: "PEM PKCS#8 key before certificate", data: append(append([]byte{}, pkcs8PEM...), certPEM...)},
+		{name: "PEM PKCS#1 key with chain", data: append(append(append([]byte{}, otherCertPEM...), certPEM...), pkcs1PEM...)},
+		{name: "PKCS#12", data: pfx, password: "pfx-password"},
+		{name: "PKCS#12 wrong password", data: pfx, password: "wrong", wantErr: true},
+		{name: "PEM without key", data: certPEM, wantErr: true},
+		{name: "PEM without certificate", data: pkcs8PEM, wantErr: true},
+		{name: "PEM key does not match", data: append(append([]byte{}, otherCertPEM...), pkcs8PEM...), wantErr: true},
+		{name: "PEM EC key", data: append(append([]byte{}, certPEM...), ecPEM...), wantErr: true},
+		{name: "garbage", data: []byte("no certificate"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCert, gotKey, err := ParseCertificate(tt.data, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !gotCert.Equal(cert) {
+				t.Errorf("ParseCertificate() returned the wrong certificate %v", gotCert.Subject)
+			}
+			if rsaKey, ok := gotKey.(*rsa.PrivateKey); !ok || !rsaKey.Equal(key) {
+				t.Errorf("ParseCertificate() returned the wrong private key")
+			}
+		})
+	}
+}
+
+func TestNewGraphClientWithCertificate(t *testing.T) {
+	cert, key := newTestCertificate(t)
+	pfx, err := pkcs12.Encode(rand.Reader, key, cert, nil, "pfx-password")
+	if err != nil {
+		t.Fatalf("Cannot encode PKCS#12: %v", err)
+	}
+	var assertion string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion = r.FormValue("client_assertion")
+		writeTestToken(w, r.FormValue("resource"), "certificate-token")
+	}))
+	defer srv.Close()
+
+	g, err := NewGraphClientWithCertificate("cert-tenant", "cert-application", pfx, "pfx-password", WithCustomEndpoint(srv.URL, srv.URL))
+	if err != nil {
+		t.Fatalf("NewGraphClientWithCertificate() error = %v", err)
+	}
+	if g.TenantID != "cert-tenant" || g.ApplicationID != "cert-application" || g.ClientSecret != "" {
+		t.Errorf("NewGraphClientWithCertificate() = %v", g)
+	}
+	_, _, digest, signature := parseTestJWT(t, assertion)
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest, signature); err != nil {
+		t.Errorf("client assertion signature is invalid: %v", err)
+	}
+
+	if _, err := NewGraphClientWithCertificate("cert-tenant", "cert-application", pfx, "wrong", WithCustomEndpoint(srv.URL, srv.URL)); err == nil {
+		t.Errorf("NewGraphClientWithCertificate(wrong password) error = nil, want an error")
+	}
+}
+
+func TestCertificateWithPS256(t *testing.T) {
+	cert, key := newTestCertificate(t)
+	credential := &clientCertificateCredential{applicationID: "cert-application", certificate: cert, privateKey: key}
+	CertificateWithPS256()(credential)
+	assertion, err := credential.clientAssertion("https://login.example.com/cert-tenant/oauth2/token")
+	if err != nil {
+		t.Fatalf("clientAssertion() error = %v", err)
+	}
+	header, _, digest, signature := parseTestJWT(t, assertion)
+	thumbprint := sha256.Sum256(cert.Raw)
+	if header["alg"] != "PS256" || header["x5t#S256"] != base64.RawURLEncoding.EncodeToString(thumbprint[:]) || header["x5t"] != nil {
+		t.Errorf("client assertion header = %v, want PS256 with the x5t#S256 of the certificate", header)
+	}
+	pssOpts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	if err := rsa.VerifyPSS(&key.PublicKey, crypto.SHA256, digest, signature, pssOpts); err != nil {
+		t.Errorf("client assertion PS256 signature is invalid: %v", err)
+	}
+
+	provider := NewClientCertificateProvider("cert-tenant", "cert-application", cert, key, CertificateWithPS256())
+	if p, ok := provider.(*clientCredentialsProvider); !ok || !p.credential.(*clientCertificateCredential).pss {
+		t.Errorf("NewClientCertificateProvider(CertificateWithPS256()) does not sign with PS256")
+	}
+}
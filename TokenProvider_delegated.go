@@ -0,0 +1,309 @@
+package msgraph
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeviceCode contains the code the user has to enter at the VerificationURI to sign in with the
+// device code flow, see NewDeviceCodeProvider.
+type DeviceCode struct {
+	UserCode        string        // the code the user has to enter
+	VerificationURI string        // the URL where the user has to enter the code, e.g. https://microsoft.com/devicelogin
+	Message         string        // the instructions for the user, containing the UserCode and VerificationURI
+	ExpiresIn       time.Duration // how long the UserCode is valid, 15 minutes if the endpoint does not tell
+}
+
+// NewDeviceCodeProvider returns a TokenProvider that acquires delegated Tokens on behalf of a
+// signed-in user with the device code flow, hence for tools running without a browser. The given
+// prompt func must show the DeviceCode to the user, e.g. print its Message. The TokenProvider then
+// waits until the user signed in, the DeviceCode expired or the context is done.
+//
+// clientID is the application ID of an app registration that allows public client flows. The
+// Tokens contain a refresh-token, which is redeemed automatically when the Token wants to be
+// refreshed. The user is only prompted again if the refresh-token is not valid anymore. Tokens are
+// requested for the scopes set with WithScopes and offline_access.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth2-device-code
+func NewDeviceCodeProvider(tenantID, clientID string, prompt func(ctx context.Context, code DeviceCode) error, opts ...DelegatedOption) TokenProvider {
+	p := newDelegatedProvider(tenantID, clientID, opts)
+	p.acquire = func(ctx context.Context, g *GraphClient) (Token, error) {
+		return p.acquireWithDeviceCode(ctx, g, prompt)
+	}
+	return p
+}
+
+// NewAuthorizationCodeProvider returns a TokenProvider that acquires delegated Tokens on behalf of a
+// signed-in user with the authorization code flow and PKCE. The given authorize func must open the
+// authURL in a browser and return the URL the browser has been redirected to after the user signed
+// in, which starts with the redirectURI, e.g. by listening on http://localhost:<port>.
+//
+// clientID is the application ID of an app registration with the redirectURI as public client or
+// mobile and desktop redirect URI. The Tokens contain a refresh-token, which is redeemed
+// automatically when the Token wants to be refreshed. The user is only asked to sign in again if
+// the refresh-token is not valid anymore. Tokens are requested for the scopes set with WithScopes
+// and offline_access.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth2-auth-code-flow
+func NewAuthorizationCodeProvider(tenantID, clientID, redirectURI string, authorize func(ctx context.Context, authURL string) (redirectURL string, err error), opts ...DelegatedOption) TokenProvider {
+	p := newDelegatedProvider(tenantID, clientID, opts)
+	p.acquire = func(ctx context.Context, g *GraphClient) (Token, error) {
+		return p.acquireWithAuthorizationCode(ctx, g, redirectURI, authorize)
+	}
+	return p
+}
+
+// DelegatedOption configures optional settings of NewDeviceCodeProvider and NewAuthorizationCodeProvider
+type DelegatedOption func(p *delegatedProvider)
+
+// DelegatedWithAccount - cache the Tokens of the TokenProvider for the given account, e.g. the
+// userPrincipalName of the user that is expected to sign in, see WithTokenCache. Tokens of
+// different accounts never share a cache entry, hence set it whenever multiple users of the same
+// application share a TokenCache.
+var DelegatedWithAccount = func(account string) DelegatedOption {
+	return func(p *delegatedProvider) {
+		p.account = account
+	}
+}
+
+// newDelegatedProvider returns a delegatedProvider configured with opts, the interactive flow must be set by the caller
+func newDelegatedProvider(tenantID, clientID string, opts []DelegatedOption) *delegatedProvider {
+	p := &delegatedProvider{tenantID: tenantID, clientID: clientID}
+	for idx := range opts {
+		opts[idx](p)
+	}
+	return p
+}
+
+// delegatedProvider acquires Tokens on behalf of a user with an interactive flow and redeems the
+// refresh-token of the last Token afterwards.
+type delegatedProvider struct {
+	tenantID string
+	clientID string
+	account  string                                                   // the account the Tokens are cached for, see DelegatedWithAccount
+	acquire  func(ctx context.Context, g *GraphClient) (Token, error) // the interactive flow
+	g        *GraphClient                                             // the GraphClient whose endpoints and http.Client are used
+
+	mu    sync.Mutex
+	token Token // the last acquired Token
+}
+
+func (p *delegatedProvider) setGraphClient(g *GraphClient) {
+	p.g = g
+}
+
+func (p *delegatedProvider) cacheIdentity() tokenIdentity {
+	return tokenIdentity{kind: tokenKindDelegated, tenantID: p.tenantID, applicationID: p.clientID, account: strings.ToLower(p.account)}
+}
+
+// seedToken continues with a cached Token, hence its refresh-token is redeemed instead of prompting
+// the user, unless the provider acquired a Token itself already
+func (p *delegatedProvider) seedToken(token Token) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token.RefreshToken == "" {
+		p.token = token
+	}
+}
+
+func (p *delegatedProvider) Token(ctx context.Context) (Token, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	g := tokenRequestClient(p.g)
+
+	var token Token
+	var err error
+	if p.token.RefreshToken != "" {
+		token, err = p.redeemRefreshToken(ctx, g)
+		if isOAuthError(err, "invalid_grant") { // the refresh-token expired or has been revoked
+			g.log(LogLevelInfo, "msgraph refresh-token is not valid anymore, the user has to sign in again", "error", err)
+			token, err = p.acquire(ctx, g)
+		}
+	} else {
+		token, err = p.acquire(ctx, g)
+	}
+	if err != nil {
+		return Token{}, err
+	}
+	if token.RefreshToken == "" { // the refresh-token is not always rotated
+		token.RefreshToken = p.token.RefreshToken
+	}
+	p.token = token
+	return token, nil
+}
+
+// redeemRefreshToken acquires a new Token with the refresh-token of the last Token
+func (p *delegatedProvider) redeemRefreshToken(ctx context.Context, g *GraphClient) (Token, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("client_id", p.clientID)
+	data.Set("refresh_token", p.token.RefreshToken)
+	data.Set("scope", delegatedScopes(g))
+	return g.requestToken(ctx, p.tenantID, data, nil)
+}
+
+// acquireWithDeviceCode requests a DeviceCode, passes it to prompt and polls the token endpoint
+// until the user signed in.
+func (p *delegatedProvider) acquireWithDeviceCode(ctx context.Context, g *GraphClient, prompt func(ctx context.Context, code DeviceCode) error) (Token, error) {
+	deviceCodeURL, err := g.oauth2URL(p.tenantID, "devicecode")
+	if err != nil {
+		return Token{}, err
+	}
+	data := url.Values{}
+	data.Set("client_id", p.clientID)
+	if g.getTokenEndpointVersion() == TokenEndpointV2 {
+		data.Set("scope", delegatedScopes(g))
+	} else {
+		data.Set("resource", g.serviceRootEndpoint)
+	}
+	resp := struct {
+		DeviceCode      string    `json:"device_code"`
+		UserCode        string    `json:"user_code"`
+		VerificationURI string    `json:"verification_uri"`
+		VerificationURL string    `json:"verification_url"` // returned by the v1.0 endpoint
+		ExpiresIn       jsonInt64 `json:"expires_in"`
+		Interval        jsonInt64 `json:"interval"`
+		Message         string    `json:"message"`
+	}{}
+	if err := g.postOAuth2Form(ctx, deviceCodeURL, data, &resp); err != nil {
+		return Token{}, fmt.Errorf("cannot request device code: %w", err)
+	}
+
+	code := DeviceCode{
+		UserCode:        resp.UserCode,
+		VerificationURI: resp.VerificationURI,
+		Message:         resp.Message,
+		ExpiresIn:       time.Duration(resp.ExpiresIn) * time.Second,
+	}
+	if code.VerificationURI == "" {
+		code.VerificationURI = resp.VerificationURL
+	}
+	if code.ExpiresIn <= 0 { // the polling would end immediately otherwise
+		code.ExpiresIn = 15 * time.Minute
+	}
+	if err := prompt(ctx, code); err != nil {
+		return Token{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, code.ExpiresIn)
+	defer cancel()
+	interval := time.Duration(resp.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		if !sleepContext(ctx, interval) {
+			return Token{}, fmt.Errorf("device code flow did not complete: user did not sign in within %v or context is done", code.ExpiresIn)
+		}
+		data := url.Values{}
+		data.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+		data.Set("client_id", p.clientID)
+		data.Set("device_code", resp.DeviceCode)
+		token, err := g.requestToken(ctx, p.tenantID, data, nil)
+		switch {
+		case isOAuthError(err, "authorization_pending"):
+			continue // the user did not sign in yet
+		case isOAuthError(err, "slow_down"):
+			interval += 5 * time.Second
+			continue
+		}
+		return token, err
+	}
+}
+
+// acquireWithAuthorizationCode lets the user sign in with authorize and redeems the returned
+// authorization code, using PKCE to protect it.
+func (p *delegatedProvider) acquireWithAuthorizationCode(ctx context.Context, g *GraphClient, redirectURI string, authorize func(ctx context.Context, authURL string) (string, error)) (Token, error) {
+	authURL, err := g.oauth2URL(p.tenantID, "authorize")
+	if err != nil {
+		return Token{}, err
+	}
+	verifier, err := randomBase64URL(32)
+	if err != nil {
+		return Token{}, err
+	}
+	state, err := randomBase64URL(16)
+	if err != nil {
+		return Token{}, err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{}
+	query.Set("client_id", p.clientID)
+	query.Set("response_type", "code")
+	query.Set("redirect_uri", redirectURI)
+	query.Set("response_mode", "query")
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if g.getTokenEndpointVersion() == TokenEndpointV2 {
+		query.Set("scope", delegatedScopes(g))
+	} else {
+		query.Set("resource", g.serviceRootEndpoint)
+	}
+
+	redirectURL, err := authorize(ctx, authURL+"?"+query.Encode())
+	if err != nil {
+		return Token{}, err
+	}
+	redirected, err := url.Parse(redirectURL)
+	if err != nil {
+		return Token{}, fmt.Errorf("unable to parse redirect URL: %v", err)
+	}
+	result := redirected.Query()
+	if result.Get("error") != "" {
+		return Token{}, fmt.Errorf("authorization failed: %v: %v", result.Get("error"), result.Get("error_description"))
+	}
+	if result.Get("state") != state {
+		return Token{}, fmt.Errorf("authorization failed: the state of the redirect URL does not match")
+	}
+	if result.Get("code") == "" {
+		return Token{}, fmt.Errorf("authorization failed: the redirect URL does not contain a code")
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("client_id", p.clientID)
+	data.Set("code", result.Get("code"))
+	data.Set("redirect_uri", redirectURI)
+	data.Set("code_verifier", verifier)
+	if g.getTokenEndpointVersion() == TokenEndpointV2 {
+		data.Set("scope", delegatedScopes(g))
+	}
+	return g.requestToken(ctx, p.tenantID, data, nil)
+}
+
+// delegatedScopes returns the scopes of the GraphClient and offline_access, which is required to
+// receive a refresh-token.
+func delegatedScopes(g *GraphClient) string {
+	scopes := g.getScopes()
+	for _, scope := range scopes {
+		if scope == "offline_access" {
+			return strings.Join(scopes, " ")
+		}
+	}
+	return strings.Join(append(append([]string{}, scopes...), "offline_access"), " ")
+}
+
+// isOAuthError returns true if err is a GraphError with the given OAuth error code, e.g. invalid_grant
+func isOAuthError(err error, code string) bool {
+	var graphErr *GraphError
+	return errors.As(err, &graphErr) && graphErr.Code == code
+}
+
+// randomBase64URL returns numBytes random bytes, base64url encoded
+func randomBase64URL(numBytes int) (string, error) {
+	data := make([]byte, numBytes)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
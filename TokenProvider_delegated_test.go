@@ -0,0 +1,241 @@
+package msgraph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delegatedHandler simulates the v2.0 endpoints of the tenant "delegated-tenant" for the device
+// code flow, the authorization code flow and refresh-tokens as well as the /beta/me endpoint.
+type delegatedHandler struct {
+	t              *testing.T
+	pendingPolls   int    // the amount of device code polls answered with authorization_pending
+	codeChallenge  string // the code_challenge of the authorization request
+	mu             sync.Mutex
+	grants         []string // the grant_types of all token requests
+	refreshInvalid bool     // answer refresh-token requests with invalid_grant
+	omitExpiresIn  bool     // answer device code requests without expires_in
+}
+
+func (d *delegatedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r.ParseForm()
+	switch r.URL.Path {
+	case "/delegated-tenant/oauth2/v2.0/devicecode":
+		if r.FormValue("client_id") != "public-client" || !strings.Contains(r.FormValue("scope"), "offline_access") {
+			d.t.Errorf("unexpected device code request %v", r.PostForm)
+		}
+		expiresIn := `"expires_in":900,`
+		if d.omitExpiresIn {
+			expiresIn = ""
+		}
+		fmt.Fprint(w, `{"device_code":"device-code","user_code":"ABC-123","verification_uri":"https://microsoft.com/devicelogin",`+
+			expiresIn+`"interval":1,"message":"To sign in, enter the code ABC-123"}`)
+	case "/delegated-tenant/oauth2/v2.0/token":
+		grant := r.FormValue("grant_type")
+		d.grants = append(d.grants, grant)
+		switch grant {
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			if d.pendingPolls > 0 {
+				d.pendingPolls--
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"authorization_pending","error_description":"pending"}`)
+				return
+			}
+			writeDelegatedToken(w, "device-access-token", "refresh-token-1")
+		case "authorization_code":
+			challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+			if r.FormValue("code") != "auth-code" || base64.RawURLEncoding.EncodeToString(challenge[:]) != d.codeChallenge {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant","error_description":"code_verifier does not match"}`)
+				return
+			}
+			writeDelegatedToken(w, "auth-code-access-token", "refresh-token-1")
+		case "refresh_token":
+			if d.refreshInvalid || r.FormValue("refresh_token") != "refresh-token-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant","error_description":"refresh token expired"}`)
+				return
+			}
+			writeDelegatedToken(w, "refreshed-access-token", "")
+		}
+	case "/beta/me":
+		fmt.Fprintf(w, `{"id":"me-id","displayName":"%s"}`, r.Header.Get("Authorization"))
+	default:
+		d.t.Errorf("unexpected request %v", r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+func writeDelegatedToken(w http.ResponseWriter, accessToken, refreshToken string) {
+	fmt.Fprintf(w, `{"token_type":"Bearer","scope":"User.Read","expires_in":3599,"access_token":"%s","refresh_token":"%s"}`, accessToken, refreshToken)
+}
+
+// expireToken lets the current Token of g expire, hence the next API-call refreshes it
+func expireToken(g *GraphClient) {
+	g.tokenMu.Lock()
+	g.token.ExpiresOn = time.Now().Add(-time.Minute)
+	g.tokenMu.Unlock()
+}
+
+func TestNewDeviceCodeProvider(t *testing.T) {
+	handler := &delegatedHandler{t: t, pendingPolls: 1}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	var prompted []DeviceCode
+	provider := NewDeviceCodeProvider("delegated-tenant", "public-client", func(ctx context.Context, code DeviceCode) error {
+		prompted = append(prompted, code)
+		return nil
+	})
+	g, err := NewGraphClientWithTokenProvider(provider, WithCustomEndpoint(srv.URL, srv.URL),
+		WithTokenEndpointVersion(TokenEndpointV2), WithScopes("https://graph.microsoft.com/User.Read"))
+	if err != nil {
+		t.Fatalf("NewGraphClientWithTokenProvider() error = %v", err)
+	}
+	if len(prompted) != 1 || prompted[0].UserCode != "ABC-123" || prompted[0].VerificationURI != "https://microsoft.com/devicelogin" || prompted[0].ExpiresIn != 900*time.Second {
+		t.Errorf("prompted device codes = %+v", prompted)
+	}
+
+	me, err := g.GetMe()
+	if err != nil || me.ID != "me-id" || me.DisplayName != "Bearer device-access-token" {
+		t.Errorf("GraphClient.GetMe() = %v, %v", me, err)
+	}
+
+	// the refresh-token is redeemed without prompting the user again
+	expireToken(g)
+	if me, err = g.GetUser("me"); err != nil || me.DisplayName != "Bearer refreshed-access-token" {
+		t.Errorf("GraphClient.GetUser(me) after refresh = %v, %v", me, err)
+	}
+	// the refresh-token has not been rotated, hence the previous one is kept
+	expireToken(g)
+	if _, err = g.GetMe(); err != nil || len(prompted) != 1 {
+		t.Errorf("GraphClient.GetMe() after second refresh: err = %v, prompted %v times", err, len(prompted))
+	}
+
+	// an invalid refresh-token starts the device code flow again
+	handler.mu.Lock()
+	handler.refreshInvalid = true
+	handler.mu.Unlock()
+	expireToken(g)
+	if me, err = g.GetMe(); err != nil || me.DisplayName != "Bearer device-access-token" || len(prompted) != 2 {
+		t.Errorf("GraphClient.GetMe() with invalid refresh-token = %v, %v, prompted %v times", me, err, len(prompted))
+	}
+	want := "device_code,device_code,refresh_token,refresh_token,refresh_token,device_code"
+	if got := strings.Replace(strings.Join(handler.grants, ","), "urn:ietf:params:oauth:grant-type:", "", -1); got != want {
+		t.Errorf("token requests = %v, want %v", got, want)
+	}
+}
+
+func TestNewDeviceCodeProvider_Cancel(t *testing.T) {
+	handler := &delegatedHandler{t: t, pendingPolls: 1000}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	provider := NewDeviceCodeProvider("delegated-tenant", "public-client", func(context.Context, DeviceCode) error {
+		cancel() // e.g. the user aborted the sign in
+		return nil
+	})
+	g := &GraphClient{azureADAuthEndpoint: srv.URL, serviceRootEndpoint: srv.URL, tokenEndpointVersion: TokenEndpointV2}
+	WithTokenProvider(provider)(g)
+	if _, err := provider.Token(ctx); err == nil {
+		t.Errorf("Token() error = nil, want an error after the context has been cancelled")
+	}
+}
+
+func TestNewDeviceCodeProvider_WithoutExpiresIn(t *testing.T) {
+	handler := &delegatedHandler{t: t, omitExpiresIn: true}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	var prompted DeviceCode
+	provider := NewDeviceCodeProvider("delegated-tenant", "public-client", func(ctx context.Context, code DeviceCode) error {
+		prompted = code
+		return nil
+	})
+	g := &GraphClient{azureADAuthEndpoint: srv.URL, serviceRootEndpoint: srv.URL, tokenEndpointVersion: TokenEndpointV2}
+	WithTokenProvider(provider)(g)
+	token, err := provider.Token(context.Background())
+	if err != nil || token.AccessToken != "device-access-token" {
+		t.Errorf("Token() = %v, error = %v, want the device code Token", token, err)
+	}
+	if prompted.ExpiresIn != 15*time.Minute {
+		t.Errorf("DeviceCode.ExpiresIn = %v, want the default of 15m", prompted.ExpiresIn)
+	}
+}
+
+func TestNewAuthorizationCodeProvider(t *testing.T) {
+	handler := &delegatedHandler{t: t}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	const redirectURI = "http://localhost:8400/callback"
+	tests := []struct {
+		name      string
+		authorize func(authURL *url.URL) string
+		wantErr   bool
+	}{
+		{
+			name: "authorization code with PKCE",
+			authorize: func(authURL *url.URL) string {
+				return redirectURI + "?code=auth-code&state=" + url.QueryEscape(authURL.Query().Get("state"))
+			},
+		}, {
+			name: "state does not match",
+			authorize: func(authURL *url.URL) string {
+				return redirectURI + "?code=auth-code&state=forged"
+			},
+			wantErr: true,
+		}, {
+			name: "user declined",
+			authorize: func(authURL *url.URL) string {
+				return redirectURI + "?error=access_denied&error_description=declined&state=" + url.QueryEscape(authURL.Query().Get("state"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewAuthorizationCodeProvider("delegated-tenant", "public-client", redirectURI, func(ctx context.Context, authURL string) (string, error) {
+				u, err := url.Parse(authURL)
+				if err != nil {
+					return "", err
+				}
+				query := u.Query()
+				if u.Path != "/delegated-tenant/oauth2/v2.0/authorize" || query.Get("client_id") != "public-client" || query.Get("response_type") != "code" ||
+					query.Get("redirect_uri") != redirectURI || query.Get("code_challenge_method") != "S256" || !strings.Contains(query.Get("scope"), "offline_access") {
+					t.Errorf("unexpected authorization URL %v", authURL)
+				}
+				handler.mu.Lock()
+				handler.codeChallenge = query.Get("code_challenge")
+				handler.mu.Unlock()
+				return tt.authorize(u), nil
+			})
+			g, err := NewGraphClientWithTokenProvider(provider, WithCustomEndpoint(srv.URL, srv.URL), WithTokenEndpointVersion(TokenEndpointV2))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGraphClientWithTokenProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if me, err := g.GetMe(); err != nil || me.DisplayName != "Bearer auth-code-access-token" {
+				t.Errorf("GraphClient.GetMe() = %v, %v", me, err)
+			}
+			expireToken(g)
+			if me, err := g.GetMe(); err != nil || me.DisplayName != "Bearer refreshed-access-token" {
+				t.Errorf("GraphClient.GetMe() after refresh = %v, %v", me, err)
+			}
+		})
+	}
+}
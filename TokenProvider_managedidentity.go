@@ -0,0 +1,123 @@
+package msgraph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const (
+	// ManagedIdentityEndpointIMDS is the endpoint of the Azure Instance Metadata Service, which issues
+	// the Tokens of managed identities on Azure VMs, VM scale sets and container instances.
+	//
+	// See https://docs.microsoft.com/en-us/azure/active-directory/managed-identities-azure-resources/how-to-use-vm-token
+	ManagedIdentityEndpointIMDS string = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	imdsAPIVersion       = "2018-02-01"
+	appServiceAPIVersion = "2019-08-01"
+)
+
+// ManagedIdentityOption configures the TokenProvider returned by NewManagedIdentityProvider
+type ManagedIdentityOption func(p *managedIdentityProvider)
+
+var (
+	// ManagedIdentityWithClientID - acquire Tokens for the user-assigned managed identity with the given
+	// client ID instead of the system-assigned managed identity.
+	ManagedIdentityWithClientID = func(clientID string) ManagedIdentityOption {
+		return func(p *managedIdentityProvider) {
+			p.clientID = clientID
+		}
+	}
+
+	// ManagedIdentityWithEndpoint - request Tokens from the given IMDS endpoint instead of
+	// ManagedIdentityEndpointIMDS, e.g. a local stub. The App Service endpoint is not used anymore.
+	ManagedIdentityWithEndpoint = func(endpoint string) ManagedIdentityOption {
+		return func(p *managedIdentityProvider) {
+			p.endpoint = endpoint
+			p.identityHeader = ""
+		}
+	}
+
+	// ManagedIdentityWithAppServiceEndpoint - request Tokens from the given App Service endpoint and
+	// authenticate with the given identity header instead of the values of the environment variables
+	// IDENTITY_ENDPOINT and IDENTITY_HEADER.
+	ManagedIdentityWithAppServiceEndpoint = func(endpoint, identityHeader string) ManagedIdentityOption {
+		return func(p *managedIdentityProvider) {
+			p.endpoint = endpoint
+			p.identityHeader = identityHeader
+		}
+	}
+)
+
+// NewManagedIdentityProvider returns a TokenProvider that acquires the Tokens of the managed identity
+// of the Azure resource the program runs on, hence no credentials are required. Tokens are requested
+// for the resource of the Service Root Endpoint of the GraphClient.
+//
+// If the environment variables IDENTITY_ENDPOINT and IDENTITY_HEADER are set, e.g. on App Service,
+// Azure Functions or Container Apps, Tokens are requested from that endpoint. Otherwise the Azure
+// Instance Metadata Service is used, see ManagedIdentityEndpointIMDS. The system-assigned managed
+// identity is used unless ManagedIdentityWithClientID is passed.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/managed-identities-azure-resources/overview
+func NewManagedIdentityProvider(opts ...ManagedIdentityOption) TokenProvider {
+	p := &managedIdentityProvider{endpoint: ManagedIdentityEndpointIMDS}
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		p.endpoint, p.identityHeader = endpoint, header
+	}
+	for idx := range opts {
+		opts[idx](p)
+	}
+	return p
+}
+
+// managedIdentityProvider acquires the Tokens of a managed identity from the IMDS or App Service endpoint
+type managedIdentityProvider struct {
+	endpoint       string
+	identityHeader string       // the secret of the App Service endpoint, empty for IMDS
+	clientID       string       // the client ID of a user-assigned managed identity
+	g              *GraphClient // the GraphClient whose Service Root Endpoint and http.Client are used
+}
+
+func (p *managedIdentityProvider) setGraphClient(g *GraphClient) {
+	p.g = g
+}
+
+func (p *managedIdentityProvider) cacheIdentity() tokenIdentity {
+	return tokenIdentity{kind: tokenKindManagedIdentity, applicationID: p.clientID}
+}
+
+func (p *managedIdentityProvider) Token(ctx context.Context) (Token, error) {
+	g := tokenRequestClient(p.g)
+	u, err := url.ParseRequestURI(p.endpoint)
+	if err != nil {
+		return Token{}, fmt.Errorf("unable to parse URI: %v", err)
+	}
+	query := u.Query()
+	query.Set("resource", g.serviceRootEndpoint)
+	if p.clientID != "" {
+		query.Set("client_id", p.clientID)
+	}
+	if p.identityHeader != "" {
+		query.Set("api-version", appServiceAPIVersion)
+	} else {
+		query.Set("api-version", imdsAPIVersion)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Token{}, fmt.Errorf("HTTP Request Error: %v", err)
+	}
+	if p.identityHeader != "" {
+		req.Header.Set("X-IDENTITY-HEADER", p.identityHeader)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+	var token Token
+	if err := g.performRequest(req, &token); err != nil {
+		return Token{}, fmt.Errorf("cannot acquire managed identity Token: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,113 @@
+package msgraph
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewManagedIdentityProvider(t *testing.T) {
+	expiresOn := time.Now().Add(time.Hour).Unix()
+	var lastRequest *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastRequest = r
+		switch r.URL.Path {
+		case "/metadata/identity/oauth2/token", "/msi/token":
+			if r.URL.Query().Get("client_id") == "unknown-identity" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_request","error_description":"Identity not found"}`)
+				return
+			}
+			// expires_on and expires_in are returned as strings
+			fmt.Fprintf(w, `{"access_token":"mi-token","expires_on":"%v","expires_in":"3599","resource":"%v","token_type":"Bearer"}`,
+				expiresOn, r.URL.Query().Get("resource"))
+		case "/beta/users":
+			fmt.Fprintf(w, `{"value":[{"id":"%s"}]}`, r.Header.Get("Authorization"))
+		default:
+			t.Errorf("unexpected request %v", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		opts        []ManagedIdentityOption
+		wantPath    string
+		wantHeaders map[string]string
+		wantQuery   map[string]string
+		wantErr     bool
+	}{
+		{
+			name:        "system-assigned IMDS",
+			opts:        []ManagedIdentityOption{ManagedIdentityWithEndpoint(srv.URL + "/metadata/identity/oauth2/token")},
+			wantPath:    "/metadata/identity/oauth2/token",
+			wantHeaders: map[string]string{"Metadata": "true", "X-IDENTITY-HEADER": ""},
+			wantQuery:   map[string]string{"api-version": "2018-02-01", "resource": srv.URL, "client_id": ""},
+		}, {
+			name:        "user-assigned IMDS",
+			opts:        []ManagedIdentityOption{ManagedIdentityWithEndpoint(srv.URL + "/metadata/identity/oauth2/token"), ManagedIdentityWithClientID("user-identity")},
+			wantPath:    "/metadata/identity/oauth2/token",
+			wantHeaders: map[string]string{"Metadata": "true"},
+			wantQuery:   map[string]string{"api-version": "2018-02-01", "client_id": "user-identity"},
+		}, {
+			name:        "App Service environment variables",
+			env:         map[string]string{"IDENTITY_ENDPOINT": srv.URL + "/msi/token", "IDENTITY_HEADER": "identity-secret"},
+			opts:        []ManagedIdentityOption{ManagedIdentityWithClientID("user-identity")},
+			wantPath:    "/msi/token",
+			wantHeaders: map[string]string{"X-IDENTITY-HEADER": "identity-secret", "Metadata": ""},
+			wantQuery:   map[string]string{"api-version": "2019-08-01", "resource": srv.URL, "client_id": "user-identity"},
+		}, {
+			name:        "App Service option",
+			opts:        []ManagedIdentityOption{ManagedIdentityWithAppServiceEndpoint(srv.URL+"/msi/token", "other-secret")},
+			wantPath:    "/msi/token",
+			wantHeaders: map[string]string{"X-IDENTITY-HEADER": "other-secret"},
+			wantQuery:   map[string]string{"api-version": "2019-08-01"},
+		}, {
+			name:    "unknown identity",
+			opts:    []ManagedIdentityOption{ManagedIdentityWithEndpoint(srv.URL + "/metadata/identity/oauth2/token"), ManagedIdentityWithClientID("unknown-identity")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+				defer os.Unsetenv(key)
+			}
+			g, err := NewGraphClientWithTokenProvider(NewManagedIdentityProvider(tt.opts...), WithCustomEndpoint(srv.URL, srv.URL))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGraphClientWithTokenProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !isOAuthError(err, "invalid_request") {
+					t.Errorf("NewGraphClientWithTokenProvider() error = %v, want invalid_request", err)
+				}
+				return
+			}
+			if lastRequest.URL.Path != tt.wantPath {
+				t.Errorf("token request path = %v, want %v", lastRequest.URL.Path, tt.wantPath)
+			}
+			for key, want := range tt.wantHeaders {
+				if got := lastRequest.Header.Get(key); got != want {
+					t.Errorf("token request header %v = %q, want %q", key, got, want)
+				}
+			}
+			for key, want := range tt.wantQuery {
+				if got := lastRequest.URL.Query().Get(key); got != want {
+					t.Errorf("token request query %v = %q, want %q", key, got, want)
+				}
+			}
+			if g.token.AccessToken != "mi-token" || g.token.ExpiresOn.Unix() != expiresOn {
+				t.Errorf("Token = %v, expires on %v, want mi-token expiring on %v", g.token.AccessToken, g.token.ExpiresOn, expiresOn)
+			}
+			if users, err := g.ListUsers(); err != nil || len(users) != 1 || users[0].ID != "Bearer mi-token" {
+				t.Errorf("GraphClient.ListUsers() = %v, %v", users, err)
+			}
+		})
+	}
+}
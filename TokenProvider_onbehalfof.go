@@ -0,0 +1,119 @@
+package msgraph
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// OnBehalfOf exchanges the access tokens a middle-tier API receives from its callers for Tokens of
+// the ms graph API with the on-behalf-of flow, hence API-calls are performed as the calling user.
+// The application authenticates with a client secret or certificate. Tokens are cached per incoming
+// access token, keyed by its SHA-256 hash, and shared by all TokenProviders of the OnBehalfOf.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth2-on-behalf-of-flow
+type OnBehalfOf struct {
+	tenantID      string
+	applicationID string
+	credential    clientCredential
+
+	mu     sync.Mutex
+	tokens map[string]Token // the acquired Tokens by the hash of the incoming access token and the scopes
+}
+
+// NewOnBehalfOfWithSecret returns an OnBehalfOf that authenticates the application with a client secret
+func NewOnBehalfOfWithSecret(tenantID, applicationID, clientSecret string) *OnBehalfOf {
+	return &OnBehalfOf{tenantID: tenantID, applicationID: applicationID, credential: clientSecretCredential(clientSecret)}
+}
+
+// NewOnBehalfOfWithCertificate returns an OnBehalfOf that authenticates the application with a
+// client assertion signed by the privateKey of the given certificate, see NewClientCertificateProvider.
+func NewOnBehalfOfWithCertificate(tenantID, applicationID string, certificate *x509.Certificate, privateKey crypto.Signer, opts ...CertificateOption) *OnBehalfOf {
+	credential := &clientCertificateCredential{applicationID: applicationID, certificate: certificate, privateKey: privateKey}
+	for idx := range opts {
+		opts[idx](credential)
+	}
+	return &OnBehalfOf{tenantID: tenantID, applicationID: applicationID, credential: credential}
+}
+
+// TokenProvider returns a TokenProvider that acquires Tokens on behalf of the user the given
+// assertion - the access token the middle-tier API received, without "Bearer " - has been issued
+// to. Pass it to NewGraphClientWithTokenProvider for every incoming request, e.g.:
+//
+//	graphClient, err := msgraph.NewGraphClientWithTokenProvider(obo.TokenProvider(incomingToken))
+//
+// The assertion must have been issued for the application of the OnBehalfOf. A cached Token is
+// returned as long as it does not want to be refreshed.
+func (o *OnBehalfOf) TokenProvider(assertion string) TokenProvider {
+	hash := sha256.Sum256([]byte(strings.TrimPrefix(assertion, "Bearer ")))
+	return &onBehalfOfProvider{o: o, assertion: strings.TrimPrefix(assertion, "Bearer "), hash: hex.EncodeToString(hash[:])}
+}
+
+// cachedToken returns the cached Token for key, if any
+func (o *OnBehalfOf) cachedToken(key string) (Token, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	token, ok := o.tokens[key]
+	return token, ok && !token.WantsToBeRefreshed()
+}
+
+// cacheToken caches the Token for key and removes all expired Tokens
+func (o *OnBehalfOf) cacheToken(key string, token Token) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.tokens == nil {
+		o.tokens = make(map[string]Token)
+	}
+	for k, t := range o.tokens {
+		if t.HasExpired() {
+			delete(o.tokens, k)
+		}
+	}
+	o.tokens[key] = token
+}
+
+// onBehalfOfProvider acquires Tokens on behalf of the user of the assertion
+type onBehalfOfProvider struct {
+	o         *OnBehalfOf
+	assertion string
+	hash      string       // the hex encoded SHA-256 hash of the assertion
+	g         *GraphClient // the GraphClient whose endpoints and http.Client are used
+}
+
+func (p *onBehalfOfProvider) setGraphClient(g *GraphClient) {
+	p.g = g
+}
+
+func (p *onBehalfOfProvider) cacheIdentity() tokenIdentity {
+	return tokenIdentity{kind: tokenKindOnBehalfOf, tenantID: p.o.tenantID, applicationID: p.o.applicationID, account: p.hash}
+}
+
+func (p *onBehalfOfProvider) Token(ctx context.Context) (Token, error) {
+	g := tokenRequestClient(p.g)
+	key := p.hash
+	if g.getTokenEndpointVersion() == TokenEndpointV2 {
+		key += " " + strings.Join(g.getScopes(), " ")
+	} else {
+		key += " " + g.serviceRootEndpoint
+	}
+	if token, ok := p.o.cachedToken(key); ok {
+		return token, nil
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	data.Set("client_id", p.o.applicationID)
+	data.Set("assertion", p.assertion)
+	data.Set("requested_token_use", "on_behalf_of")
+	token, err := g.requestToken(ctx, p.o.tenantID, data, p.o.credential)
+	if err != nil {
+		return Token{}, err
+	}
+	p.o.cacheToken(key, token)
+	return token, nil
+}
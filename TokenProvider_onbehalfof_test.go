@@ -0,0 +1,75 @@
+package msgraph
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnBehalfOf_TokenProvider(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string // the assertions of all token requests
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/obo-tenant/oauth2/v2.0/token":
+			if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.FormValue("requested_token_use") != "on_behalf_of" ||
+				r.FormValue("client_id") != "middle-tier" || r.FormValue("client_secret") != "secret" || r.FormValue("scope") != "https://graph.microsoft.com/.default" {
+				t.Errorf("unexpected on-behalf-of token request %v", r.PostForm)
+			}
+			mu.Lock()
+			requests = append(requests, r.FormValue("assertion"))
+			mu.Unlock()
+			if r.FormValue("assertion") == "revoked-user-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant","error_description":"AADSTS50013: Assertion failed signature validation."}`)
+				return
+			}
+			fmt.Fprintf(w, `{"token_type":"Bearer","expires_in":3599,"access_token":"graph-%s"}`, r.FormValue("assertion"))
+		case "/beta/me":
+			fmt.Fprintf(w, `{"id":"%s"}`, r.Header.Get("Authorization"))
+		default:
+			t.Errorf("unexpected request %v", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	obo := NewOnBehalfOfWithSecret("obo-tenant", "middle-tier", "secret")
+	newClient := func(assertion string) (*GraphClient, error) {
+		return NewGraphClientWithTokenProvider(obo.TokenProvider(assertion), WithCustomEndpoint(srv.URL, srv.URL),
+			WithTokenEndpointVersion(TokenEndpointV2), WithScopes("https://graph.microsoft.com/.default"))
+	}
+
+	// every incoming request creates a new GraphClient, Tokens are only acquired once per user
+	for _, assertion := range []string{"user-token-1", "Bearer user-token-1", "user-token-2", "user-token-1"} {
+		g, err := newClient(assertion)
+		if err != nil {
+			t.Fatalf("NewGraphClientWithTokenProvider(%v) error = %v", assertion, err)
+		}
+		me, err := g.GetMe()
+		if want := "Bearer graph-" + strings.TrimPrefix(assertion, "Bearer "); err != nil || me.ID != want {
+			t.Errorf("GraphClient.GetMe() for %v = %v, %v, want %v", assertion, me.ID, err, want)
+		}
+	}
+	if want := []string{"user-token-1", "user-token-2"}; fmt.Sprint(requests) != fmt.Sprint(want) {
+		t.Errorf("on-behalf-of token requests = %v, want %v", requests, want)
+	}
+
+	if _, err := newClient("revoked-user-token"); !isOAuthError(err, "invalid_grant") {
+		t.Errorf("NewGraphClientWithTokenProvider(revoked-user-token) error = %v, want invalid_grant", err)
+	}
+
+	// expired Tokens are not returned from the cache
+	for key, token := range obo.tokens {
+		token.ExpiresOn = time.Now().Add(-time.Minute)
+		obo.tokens[key] = token
+	}
+	if _, err := newClient("user-token-2"); err != nil || len(requests) != 4 || len(obo.tokens) != 1 {
+		t.Errorf("NewGraphClientWithTokenProvider() with expired cache: err = %v, %v token requests, want 4", err, len(requests))
+	}
+}
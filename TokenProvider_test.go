@@ -0,0 +1,310 @@
+package msgraph
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestCertificate creates a self-signed certificate with a new RSA private key
+func newTestCertificate(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Cannot generate RSA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-msgraph test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Cannot create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Cannot parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+// parseTestJWT splits the given JWT and returns its decoded header, claims and the signed digest
+func parseTestJWT(t *testing.T, jwt string) (header map[string]interface{}, claims map[string]interface{}, digest []byte, signature []byte) {
+	t.Helper()
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT %q does not consist of 3 parts", jwt)
+	}
+	for i, v := range []*map[string]interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatalf("Cannot decode JWT part %v: %v", i, err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("Cannot unmarshal JWT part %v: %v", i, err)
+		}
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("Cannot decode JWT signature: %v", err)
+	}
+	return header, claims, sum[:], signature
+}
+
+func TestWithTokenProvider(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	provider := TokenProviderFunc(func(ctx context.Context) (Token, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return Token{TokenType: "Bearer", AccessToken: "vault-token", NotBefore: time.Now().Add(-time.Minute), ExpiresOn: time.Now().Add(time.Hour)}, nil
+	})
+	var gotAuthorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuthorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"value":[]}`))
+	}))
+	defer srv.Close()
+
+	g, err := NewGraphClientWithTokenProvider(provider, WithCustomEndpoint(srv.URL, srv.URL))
+	if err != nil {
+		t.Fatalf("NewGraphClientWithTokenProvider() error = %v", err)
+	}
+	if _, err := g.ListUsers(); err != nil {
+		t.Fatalf("GraphClient.ListUsers() error = %v", err)
+	}
+	if gotAuthorization != "Bearer vault-token" || calls != 1 {
+		t.Errorf("Authorization = %q after %v calls of the TokenProvider, want the token of the TokenProvider", gotAuthorization, calls)
+	}
+}
+
+func TestNewStaticTokenProvider(t *testing.T) {
+	valid := Token{TokenType: "Bearer", AccessToken: "static", NotBefore: time.Now().Add(-time.Minute), ExpiresOn: time.Now().Add(time.Hour)}
+	if token, err := NewStaticTokenProvider(valid).Token(context.Background()); err != nil || token != valid {
+		t.Errorf("NewStaticTokenProvider(valid).Token() = %v, %v, want %v", token, err, valid)
+	}
+	expired := Token{TokenType: "Bearer", AccessToken: "static", ExpiresOn: time.Now().Add(-time.Minute)}
+	if _, err := NewGraphClientWithTokenProvider(NewStaticTokenProvider(expired)); err == nil {
+		t.Errorf("NewGraphClientWithTokenProvider(expired) error = nil, want an error")
+	}
+}
+
+func TestNewClientSecretProvider(t *testing.T) {
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vault-tenant/oauth2/token" {
+			t.Errorf("unexpected token request path %v", r.URL.Path)
+		}
+		r.ParseForm()
+		form = r.PostForm
+		writeTestToken(w, r.FormValue("resource"), "secret-token")
+	}))
+	defer srv.Close()
+
+	g, err := NewGraphClientWithTokenProvider(NewClientSecretProvider("vault-tenant", "vault-application", "vault-secret"),
+		WithCustomEndpoint(srv.URL, srv.URL))
+	if err != nil {
+		t.Fatalf("NewGraphClientWithTokenProvider() error = %v", err)
+	}
+	want := map[string]string{"grant_type": "client_credentials", "client_id": "vault-application", "client_secret": "vault-secret", "resource": srv.URL}
+	for key, val := range want {
+		if got := strings.Join(form[key], ","); got != val {
+			t.Errorf("token request %v = %q, want %q", key, got, val)
+		}
+	}
+	if token, _ := g.getToken(context.Background()); token.AccessToken != "secret-token" {
+		t.Errorf("GraphClient token = %v, want secret-token", token)
+	}
+}
+
+func TestNewClientCertificateProvider(t *testing.T) {
+	cert, key := newTestCertificate(t)
+	var assertion, assertionType, tokenURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenURL = "http://" + r.Host + r.URL.Path
+		assertion, assertionType = r.FormValue("client_assertion"), r.FormValue("client_assertion_type")
+		if r.FormValue("client_secret") != "" {
+			t.Errorf("token request contains a client_secret")
+		}
+		writeTestToken(w, r.FormValue("resource"), "certificate-token")
+	}))
+	defer srv.Close()
+
+	_, err := NewGraphClientWithTokenProvider(NewClientCertificateProvider("cert-tenant", "cert-application", cert, key),
+		WithCustomEndpoint(srv.URL, srv.URL))
+	if err != nil {
+		t.Fatalf("NewGraphClientWithTokenProvider() error = %v", err)
+	}
+	if assertionType != "urn:ietf:params:oauth:client-assertion-type:jwt-bearer" {
+		t.Errorf("client_assertion_type = %q", assertionType)
+	}
+
+	header, claims, digest, signature := parseTestJWT(t, assertion)
+	thumbprint := sha1.Sum(cert.Raw)
+	if header["alg"] != "RS256" || header["x5t"] != base64.RawURLEncoding.EncodeToString(thumbprint[:]) {
+		t.Errorf("client assertion header = %v, want RS256 with the x5t of the certificate", header)
+	}
+	if claims["aud"] != tokenURL || claims["iss"] != "cert-application" || claims["sub"] != "cert-application" || claims["jti"] == "" {
+		t.Errorf("client assertion claims = %v, want aud %v", claims, tokenURL)
+	}
+	if exp, _ := claims["exp"].(float64); time.Unix(int64(exp), 0).Before(time.Now()) {
+		t.Errorf("client assertion expired at %v", exp)
+	}
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest, signature); err != nil {
+		t.Errorf("client assertion signature is invalid: %v", err)
+	}
+}
+
+func TestToken_UnmarshalJSON(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name          string
+		data          string
+		wantExpiresOn time.Time
+		wantErr       bool
+	}{
+		{
+			name:          "v1.0 token with strings",
+			data:          fmt.Sprintf(`{"token_type":"Bearer","expires_in":"3599","expires_on":"%d","not_before":"%d","access_token":"a"}`, now.Add(time.Hour).Unix(), now.Add(-time.Minute).Unix()),
+			wantExpiresOn: time.Unix(now.Add(time.Hour).Unix(), 0),
+		}, {
+			name:          "v2.0 token with expires_in number",
+			data:          `{"token_type":"Bearer","expires_in":3599,"ext_expires_in":3599,"access_token":"a"}`,
+			wantExpiresOn: now.Add(3599 * time.Second),
+		}, {
+			name:          "expires_in string",
+			data:          `{"token_type":"Bearer","expires_in":"60","access_token":"a"}`,
+			wantExpiresOn: now.Add(60 * time.Second),
+		}, {
+			name:    "expired",
+			data:    `{"token_type":"Bearer","expires_in":0,"access_token":"a"}`,
+			wantErr: true,
+		}, {
+			name:    "not yet valid",
+			data:    fmt.Sprintf(`{"token_type":"Bearer","expires_on":%d,"not_before":%d,"access_token":"a"}`, now.Add(time.Hour).Unix(), now.Add(time.Minute).Unix()),
+			wantErr: true,
+		}, {
+			name:    "invalid expires_in",
+			data:    `{"token_type":"Bearer","expires_in":"soon","access_token":"a"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var token Token
+			err := json.Unmarshal([]byte(tt.data), &token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Token.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := token.ExpiresOn.Sub(tt.wantExpiresOn); diff < -time.Second || diff > time.Second {
+				t.Errorf("Token.ExpiresOn = %v, want %v", token.ExpiresOn, tt.wantExpiresOn)
+			}
+			if !token.IsValid() || token.AccessToken != "a" {
+				t.Errorf("Token = %v, want a valid token", token)
+			}
+		})
+	}
+}
+
+func TestGraphClient_TokenEndpointVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []GraphClientOption
+		wantPath  string
+		wantForm  map[string]string
+		wantScope bool
+	}{
+		{
+			name:     "custom endpoint defaults to v1.0",
+			wantPath: "/test-tenant/oauth2/token",
+			wantForm: map[string]string{"resource": "SERVER", "scope": ""},
+		}, {
+			name:     "v2.0",
+			opts:     []GraphClientOption{WithTokenEndpointVersion(TokenEndpointV2)},
+			wantPath: "/test-tenant/oauth2/v2.0/token",
+			wantForm: map[string]string{"resource": "", "scope": "SERVER/.default"},
+		}, {
+			name:     "v2.0 with scopes",
+			opts:     []GraphClientOption{WithTokenEndpointVersion(TokenEndpointV2), WithScopes("https://graph.microsoft.com/User.Read", "offline_access")},
+			wantPath: "/test-tenant/oauth2/v2.0/token",
+			wantForm: map[string]string{"resource": "", "scope": "https://graph.microsoft.com/User.Read offline_access"},
+		}, {
+			name:     "v1.0 ignores scopes",
+			opts:     []GraphClientOption{WithTokenEndpointVersion(TokenEndpointV1), WithScopes("offline_access")},
+			wantPath: "/test-tenant/oauth2/token",
+			wantForm: map[string]string{"resource": "SERVER", "scope": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			var gotForm url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				r.ParseForm()
+				gotForm = r.PostForm
+				fmt.Fprint(w, `{"token_type":"Bearer","expires_in":3599,"access_token":"v2-token"}`)
+			}))
+			defer srv.Close()
+
+			g, err := NewGraphClientWithCustomEndpoint("test-tenant", "test-application", "test-secret", srv.URL, srv.URL, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewGraphClientWithCustomEndpoint() error = %v", err)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("token request path = %v, want %v", gotPath, tt.wantPath)
+			}
+			for key, val := range tt.wantForm {
+				if want := strings.Replace(val, "SERVER", srv.URL, 1); gotForm.Get(key) != want {
+					t.Errorf("token request %v = %q, want %q", key, gotForm.Get(key), want)
+				}
+			}
+			if token, _ := g.getToken(context.Background()); token.AccessToken != "v2-token" || !token.IsValid() {
+				t.Errorf("GraphClient token = %v, want a valid v2-token", token)
+			}
+		})
+	}
+}
+
+func TestGraphClient_getTokenEndpointVersion(t *testing.T) {
+	tests := []struct {
+		azureADAuthEndpoint string
+		want                TokenEndpointVersion
+	}{
+		{AzureADAuthEndpointGlobal, TokenEndpointV2},
+		{AzureADAuthEndpointGlobal + "/", TokenEndpointV2},
+		{AzureADAuthEndpointUSGov, TokenEndpointV2},
+		{AzureADAuthEndpointChina, TokenEndpointV2},
+		{AzureADAuthEndpointGermany, TokenEndpointV1},
+		{"https://login.example.com", TokenEndpointV1},
+	}
+	for _, tt := range tests {
+		g := &GraphClient{azureADAuthEndpoint: tt.azureADAuthEndpoint}
+		if got := g.getTokenEndpointVersion(); got != tt.want {
+			t.Errorf("getTokenEndpointVersion() for %v = %v, want %v", tt.azureADAuthEndpoint, got, tt.want)
+		}
+	}
+}
@@ -34,6 +34,7 @@ type User struct {
 	MailboxSettings            MailboxSettings   `json:"mailboxSettings"`
 	LastPasswordChangeDateTime string            `json:"lastPasswordChangeDateTime"`
 	PasswordPolicies           string            `json:"passwordPolicies"`
+	Removed                    *Removed          `json:"@removed,omitempty"` // only set by ListUsersDelta if the user has been removed
 
 	activePhone string       // private cache for the active phone number
 	graphClient *GraphClient // the graphClient that called the user
@@ -1,6 +1,7 @@
 package msgraph
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -80,3 +81,78 @@ Outer:
 	}
 	return len(u) == len(other) // if we reach this, all users have been found, now return if len of the users are equal
 }
+
+// UserIterator iterates over a collection of users that is loaded page by page, hence only
+// one page is held in memory at a time. Create it with e.g. GraphClient.IterateUsers.
+//
+// Use Next to iterate the users one by one or NextPage to process them page by page:
+//
+//	it := graphClient.IterateUsers()
+//	for it.Next(ctx) {
+//		user := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle the error
+//	}
+type UserIterator struct {
+	pages *pageIterator
+	page  Users
+	index int
+	err   error
+}
+
+// Next advances to the next user and loads the next page if the current one is exhausted.
+// Returns false if there are no more users or an error occurred, see Err.
+func (it *UserIterator) Next(ctx context.Context) bool {
+	for it.index+1 >= len(it.page) {
+		if !it.NextPage(ctx) {
+			return false
+		}
+	}
+	it.index++
+	return true
+}
+
+// NextPage loads the next page, skipping all users of the current page that have not been
+// returned by Next yet. Returns false if there are no more pages or an error occurred, see Err.
+func (it *UserIterator) NextPage(ctx context.Context) bool {
+	items, ok := it.pages.next(ctx)
+	if !ok {
+		return false
+	}
+	var page Users
+	if it.err = unmarshalItems(items, &page); it.err != nil {
+		return false
+	}
+	it.page = page.setGraphClient(it.pages.g)
+	it.index = -1
+	return true
+}
+
+// Value returns the current user, hence the user Next advanced to.
+func (it *UserIterator) Value() User {
+	if it.index < 0 || it.index >= len(it.page) {
+		return User{}
+	}
+	return it.page[it.index]
+}
+
+// Page returns all users of the current page.
+func (it *UserIterator) Page() Users {
+	return it.page
+}
+
+// Err returns the first error that occurred during the iteration, if any.
+func (it *UserIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.pages.err
+}
+
+// NextLink returns the @odata.nextLink of the page following the current page, or an empty
+// string if the current page is the last one. Save it after a page has been processed completely
+// to resume the iteration later on with ListWithNextLink.
+func (it *UserIterator) NextLink() string {
+	return it.pages.nextLink
+}
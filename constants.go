@@ -62,13 +62,48 @@ const (
 	ServiceRootEndpointChina string = "https://microsoftgraph.chinacloudapi.cn"
 )
 
-// APIVersion represents the APIVersion of msgraph used by this implementation
-//const APIVersion string = "v1.0"
-const APIVersion string = "beta"
+// TokenEndpointVersion is the version of the Azure AD token endpoint used to acquire Tokens, see
+// WithTokenEndpointVersion
+type TokenEndpointVersion string
 
-// MaxPageSize is the maximum Page size for an API-call. This will be rewritten to use paging some day. Currently limits environments to 999 entries (e.g. Users, CalendarEvents etc.)
+const (
+	// TokenEndpointV1 is the legacy Azure AD v1.0 endpoint /{tenant}/oauth2/token, which issues Tokens
+	// for the resource of the Service Root Endpoint. Used by default for AzureADAuthEndpointGermany and
+	// custom Azure AD authentication endpoints.
+	TokenEndpointV1 TokenEndpointVersion = "v1.0"
+
+	// TokenEndpointV2 is the Microsoft identity platform v2.0 endpoint /{tenant}/oauth2/v2.0/token,
+	// which issues Tokens for scopes. Used by default for AzureADAuthEndpointGlobal,
+	// AzureADAuthEndpointUSGov and AzureADAuthEndpointChina.
+	//
+	// See https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-overview
+	TokenEndpointV2 TokenEndpointVersion = "v2.0"
+)
+
+const (
+	// APIVersionV1 is the generally available version of the msgraph API, which is supported for
+	// production use.
+	//
+	// See https://docs.microsoft.com/en-us/graph/versioning-and-support
+	APIVersionV1 string = "v1.0"
+
+	// APIVersionBeta is the preview version of the msgraph API, which contains APIs that are not
+	// generally available yet, e.g. many Intune APIs. APIs within beta may change at any time.
+	APIVersionBeta string = "beta"
+)
+
+// APIVersion represents the APIVersion of msgraph used by default, see WithAPIVersion to change it
+// per GraphClient and GetWithAPIVersion etc. to change it per API-call.
+const APIVersion string = APIVersionBeta
+
+// MaxPageSize is the maximum Page size for an API-call. Collections are loaded page by page by following
+// the @odata.nextLink, hence this only limits the amount of entries per page, see ListWithPageSize.
 const MaxPageSize int = 999
 
+// MaxBatchSize is the maximum amount of sub-requests within a single JSON batch request. Larger
+// Batches are split into multiple JSON batch requests, see GraphClient.Batch.
+const MaxBatchSize int = 20
+
 var (
 	// ErrFindUser is returned on any func that tries to find a user with the given parameters that cannot be found
 	ErrFindUser = errors.New("unable to find user")
@@ -76,6 +111,20 @@ var (
 	ErrFindGroup = errors.New("unable to find group")
 	// ErrFindCalendar is returned on any func that tries to find a calendar with the given parameters that cannot be found
 	ErrFindCalendar = errors.New("unable to find calendar")
+	// ErrFindSubscription is returned on any func that tries to find a subscription with the given parameters that cannot be found
+	ErrFindSubscription = errors.New("unable to find subscription")
+	// ErrClientStateMismatch is returned by SubscriptionHandler for notifications whose clientState
+	// does not match, hence they have not been sent by Microsoft for one of our Subscriptions.
+	ErrClientStateMismatch = errors.New("clientState of notification does not match")
+	// ErrUnhandledNotification is returned by SubscriptionHandler for notifications without a
+	// NotificationFunc for their resource type.
+	ErrUnhandledNotification = errors.New("no NotificationFunc for notification")
+	// ErrInvalidDataSignature is returned if the dataSignature of the EncryptedContent of a notification
+	// does not match, hence the resource data has been tampered with or the private key is wrong.
+	ErrInvalidDataSignature = errors.New("dataSignature of encryptedContent does not match")
+	// ErrInvalidValidationToken is returned if a validationToken of a ChangeNotificationCollection
+	// cannot be validated, hence its notifications have not been sent by Microsoft.
+	ErrInvalidValidationToken = errors.New("invalid validationToken")
 	// ErrNotGraphClientSourced is returned if e.g. a ListMembers() is called but the Group has not been created by a graphClient query
 	ErrNotGraphClientSourced = errors.New("instance is not created from a GraphClient API-Call, cannot directly get further information")
 )
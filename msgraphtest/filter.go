@@ -0,0 +1,632 @@
+package msgraphtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// filterNode is a node of a parsed $filter expression
+type filterNode interface {
+	eval(obj Object, vars map[string]interface{}) (bool, error)
+}
+
+// filterExpr is a parsed $filter expression
+type filterExpr struct {
+	root filterNode
+}
+
+func (f filterExpr) matches(obj Object) (bool, error) {
+	return f.root.eval(obj, nil)
+}
+
+// parseFilter parses a $filter expression with the logical operators and, or and not, the
+// comparison operators eq, ne, gt, ge, lt, le and in, the functions startswith, endswith and
+// contains and the lambda operators any and all.
+func parseFilter(expr string) (filterExpr, error) {
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return filterExpr{}, err
+	}
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return filterExpr{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return filterExpr{}, fmt.Errorf("unexpected %q at the end of the expression", p.tokens[p.pos].text)
+	}
+	return filterExpr{root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord   tokenKind = iota // a property path, operator, keyword or unquoted literal
+	tokenString                  // a quoted string literal
+	tokenSymbol                  // one of ( ) , :
+)
+
+type filterToken struct {
+	kind tokenKind
+	text string
+}
+
+// lexFilter splits a $filter expression into tokens
+func lexFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == ',' || r == ':':
+			tokens = append(tokens, filterToken{kind: tokenSymbol, text: string(r)})
+			i++
+		case r == '\'':
+			var sb strings.Builder
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' { // escaped quote
+						sb.WriteRune('\'')
+						i++
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string literal")
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, text: sb.String()})
+		default:
+			// date and time literals start with a digit and contain colons
+			numeric := unicode.IsDigit(r)
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("(),'", runes[i]) && (numeric || runes[i] != ':') {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: tokenWord, text: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	token, ok := p.peek()
+	if !ok {
+		return filterToken{}, fmt.Errorf("unexpected end of the expression")
+	}
+	p.pos++
+	return token, nil
+}
+
+// acceptWord consumes the next token if it is the given keyword
+func (p *filterParser) acceptWord(keyword string) bool {
+	if token, ok := p.peek(); ok && token.kind == tokenWord && strings.EqualFold(token.text, keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expectSymbol(symbol string) error {
+	token, err := p.next()
+	if err != nil {
+		return err
+	}
+	if token.kind != tokenSymbol || token.text != symbol {
+		return fmt.Errorf("expected %q, got %q", symbol, token.text)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptWord("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptWord("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.acceptWord("not") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if token.kind == tokenSymbol && token.text == "(" {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expectSymbol(")")
+	}
+	if token.kind != tokenWord {
+		return nil, fmt.Errorf("unexpected %q", token.text)
+	}
+
+	if next, ok := p.peek(); ok && next.kind == tokenSymbol && next.text == "(" {
+		p.pos++
+		lower := strings.ToLower(token.text)
+		switch {
+		case lower == "startswith" || lower == "endswith" || lower == "contains":
+			return p.parseFunction(lower)
+		case strings.HasSuffix(lower, "/any") || strings.HasSuffix(lower, "/all"):
+			sep := strings.LastIndex(token.text, "/")
+			return p.parseLambda(token.text[:sep], lower[sep+1:])
+		}
+		return nil, fmt.Errorf("unsupported function %q", token.text)
+	}
+
+	path := strings.Split(token.text, "/")
+	operator, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch op := strings.ToLower(operator.text); op {
+	case "eq", "ne", "gt", "ge", "lt", "le":
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{path: path, operator: op, value: value}, nil
+	case "in":
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		var values []interface{}
+		for {
+			value, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if token, err := p.next(); err != nil {
+				return nil, err
+			} else if token.text == ")" {
+				return inNode{path: path, values: values}, nil
+			} else if token.text != "," {
+				return nil, fmt.Errorf("expected \",\" or \")\", got %q", token.text)
+			}
+		}
+	}
+	return nil, fmt.Errorf("unsupported operator %q", operator.text)
+}
+
+// parseFunction parses the arguments of startswith, endswith or contains after the opening parenthesis
+func (p *filterParser) parseFunction(name string) (filterNode, error) {
+	property, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if property.kind != tokenWord {
+		return nil, fmt.Errorf("expected a property as first argument of %v, got %q", name, property.text)
+	}
+	if err := p.expectSymbol(","); err != nil {
+		return nil, err
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if value.kind != tokenString {
+		return nil, fmt.Errorf("expected a string as second argument of %v, got %q", name, value.text)
+	}
+	return functionNode{name: name, path: strings.Split(property.text, "/"), value: value.text}, p.expectSymbol(")")
+}
+
+// parseLambda parses the lambda expression of any or all after the opening parenthesis
+func (p *filterParser) parseLambda(collection, operator string) (filterNode, error) {
+	node := lambdaNode{path: strings.Split(collection, "/"), all: operator == "all"}
+	if token, ok := p.peek(); ok && token.text == ")" {
+		p.pos++
+		return node, nil
+	}
+	variable, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if variable.kind != tokenWord {
+		return nil, fmt.Errorf("expected a lambda variable, got %q", variable.text)
+	}
+	if err := p.expectSymbol(":"); err != nil {
+		return nil, err
+	}
+	node.variable = variable.text
+	if node.condition, err = p.parseOr(); err != nil {
+		return nil, err
+	}
+	return node, p.expectSymbol(")")
+}
+
+// parseLiteral parses a string, number, boolean, null, date and time or GUID literal
+func (p *filterParser) parseLiteral() (interface{}, error) {
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case token.kind == tokenString:
+		return token.text, nil
+	case token.kind != tokenWord:
+		return nil, fmt.Errorf("expected a literal, got %q", token.text)
+	case token.text == "null":
+		return nil, nil
+	case token.text == "true" || token.text == "false":
+		return token.text == "true", nil
+	}
+	if n, err := strconv.ParseFloat(token.text, 64); err == nil {
+		return n, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, token.text); err == nil {
+		return t, nil
+	}
+	return token.text, nil // e.g. a GUID
+}
+
+type orNode struct{ left, right filterNode }
+
+func (n orNode) eval(obj Object, vars map[string]interface{}) (bool, error) {
+	ok, err := n.left.eval(obj, vars)
+	if err != nil || ok {
+		return ok, err
+	}
+	return n.right.eval(obj, vars)
+}
+
+type andNode struct{ left, right filterNode }
+
+func (n andNode) eval(obj Object, vars map[string]interface{}) (bool, error) {
+	ok, err := n.left.eval(obj, vars)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return n.right.eval(obj, vars)
+}
+
+type notNode struct{ operand filterNode }
+
+func (n notNode) eval(obj Object, vars map[string]interface{}) (bool, error) {
+	ok, err := n.operand.eval(obj, vars)
+	return !ok, err
+}
+
+type compareNode struct {
+	path     []string
+	operator string
+	value    interface{}
+}
+
+func (n compareNode) eval(obj Object, vars map[string]interface{}) (bool, error) {
+	cmp := compareValues(resolve(obj, vars, n.path), n.value)
+	switch n.operator {
+	case "eq":
+		return cmp == 0, nil
+	case "ne":
+		return cmp != 0, nil
+	case "gt":
+		return cmp == 1, nil
+	case "ge":
+		return cmp == 0 || cmp == 1, nil
+	case "lt":
+		return cmp == -1, nil
+	default: // le
+		return cmp == 0 || cmp == -1, nil
+	}
+}
+
+type inNode struct {
+	path   []string
+	values []interface{}
+}
+
+func (n inNode) eval(obj Object, vars map[string]interface{}) (bool, error) {
+	value := resolve(obj, vars, n.path)
+	for _, candidate := range n.values {
+		if compareValues(value, candidate) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type functionNode struct {
+	name  string
+	path  []string
+	value string
+}
+
+func (n functionNode) eval(obj Object, vars map[string]interface{}) (bool, error) {
+	s, ok := resolve(obj, vars, n.path).(string)
+	if !ok {
+		return false, nil
+	}
+	s, value := strings.ToLower(s), strings.ToLower(n.value)
+	switch n.name {
+	case "startswith":
+		return strings.HasPrefix(s, value), nil
+	case "endswith":
+		return strings.HasSuffix(s, value), nil
+	default: // contains
+		return strings.Contains(s, value), nil
+	}
+}
+
+type lambdaNode struct {
+	path      []string
+	all       bool
+	variable  string
+	condition filterNode // nil for "collection/any()"
+}
+
+func (n lambdaNode) eval(obj Object, vars map[string]interface{}) (bool, error) {
+	items, _ := resolve(obj, vars, n.path).([]interface{})
+	if n.condition == nil {
+		return len(items) > 0, nil
+	}
+	for _, item := range items {
+		scope := map[string]interface{}{n.variable: item}
+		for key, value := range vars {
+			if key != n.variable {
+				scope[key] = value
+			}
+		}
+		ok, err := n.condition.eval(obj, scope)
+		if err != nil {
+			return false, err
+		}
+		if ok != n.all { // any: first match, all: first mismatch
+			return ok, nil
+		}
+	}
+	return n.all, nil
+}
+
+// resolve returns the value of the property path, whose first element may be a lambda variable
+func resolve(obj Object, vars map[string]interface{}, path []string) interface{} {
+	if value, ok := vars[path[0]]; ok {
+		return lookup(value, path[1:])
+	}
+	return lookup(obj, path)
+}
+
+// compareValues compares a and b and returns -1, 0 or 1. Strings are compared case-insensitively,
+// strings are compared as date and time if the other value is a time.Time. Values of different
+// types are never equal.
+func compareValues(a, b interface{}) int {
+	if t, ok := b.(time.Time); ok {
+		if s, isString := a.(string); isString {
+			if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				a = parsed
+			}
+		}
+		if at, isTime := a.(time.Time); isTime {
+			switch {
+			case at.Before(t):
+				return -1
+			case at.After(t):
+				return 1
+			}
+			return 0
+		}
+	}
+	switch av := a.(type) {
+	case nil:
+		if b == nil {
+			return 0
+		}
+		return -1
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(strings.ToLower(av), strings.ToLower(bv))
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case bv:
+				return -1
+			}
+			return 1
+		}
+	}
+	if b == nil {
+		return 1
+	}
+	return 2 // not comparable, neither equal, less nor greater
+}
+
+// searchExpr is a parsed $search expression
+type searchExpr struct {
+	property, value string      // a clause "property:value"
+	operator        string      // AND or OR, empty for a clause
+	left, right     *searchExpr // the operands of AND or OR
+}
+
+// parseSearch parses a $search expression of quoted clauses "property:value" combined with AND,
+// OR and parentheses
+func parseSearch(expr string) (*searchExpr, error) {
+	p := &searchParser{input: expr}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q", p.input[p.pos:])
+	}
+	return node, nil
+}
+
+// matches returns true if any word of the property starts with the value of the clause
+func (e *searchExpr) matches(obj Object) bool {
+	switch e.operator {
+	case "AND":
+		return e.left.matches(obj) && e.right.matches(obj)
+	case "OR":
+		return e.left.matches(obj) || e.right.matches(obj)
+	}
+	s, _ := obj[e.property].(string)
+	value := strings.ToLower(e.value)
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if strings.HasPrefix(word, value) {
+			return true
+		}
+	}
+	return strings.HasPrefix(strings.ToLower(s), value)
+}
+
+type searchParser struct {
+	input string
+	pos   int
+}
+
+func (p *searchParser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *searchParser) acceptKeyword(keyword string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], keyword+" ") {
+		p.pos += len(keyword)
+		return true
+	}
+	return false
+}
+
+func (p *searchParser) parseOr() (*searchExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &searchExpr{operator: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *searchParser) parseAnd() (*searchExpr, error) {
+	left, err := p.parseClause()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseClause()
+		if err != nil {
+			return nil, err
+		}
+		left = &searchExpr{operator: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *searchParser) parseClause() (*searchExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of the expression")
+	}
+	if p.input[p.pos] == '(' {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	}
+	if p.input[p.pos] != '"' {
+		return nil, fmt.Errorf("expected a quoted clause \"property:value\" at %q", p.input[p.pos:])
+	}
+	var sb strings.Builder
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		switch c := p.input[p.pos]; {
+		case c == '\\' && p.pos+1 < len(p.input):
+			p.pos++
+			sb.WriteByte(p.input[p.pos])
+		case c == '"':
+			p.pos++
+			clause := sb.String()
+			sep := strings.Index(clause, ":")
+			if sep < 0 {
+				return nil, fmt.Errorf("clause %q is not of the form \"property:value\"", clause)
+			}
+			return &searchExpr{property: clause[:sep], value: clause[sep+1:]}, nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return nil, fmt.Errorf("unterminated clause")
+}
@@ -0,0 +1,170 @@
+package msgraphtest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 999
+)
+
+// listObjects applies the query options of the request to objs and returns the requested page
+func (s *Server) listObjects(r *http.Request, objs []Object) (interface{}, error) {
+	query := r.URL.Query()
+	items := make([]Object, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, clone(obj))
+	}
+
+	if expr := query.Get("$filter"); expr != "" {
+		filter, err := parseFilter(expr)
+		if err != nil {
+			return nil, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: fmt.Sprintf("Invalid filter clause: %v", err)}
+		}
+		items, err = filterObjects(items, filter.matches)
+		if err != nil {
+			return nil, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: fmt.Sprintf("Invalid filter clause: %v", err)}
+		}
+	}
+	if expr := query.Get("$search"); expr != "" {
+		if !strings.EqualFold(r.Header.Get("ConsistencyLevel"), "eventual") {
+			return nil, &routeError{status: http.StatusBadRequest, code: "Request_UnsupportedQuery", message: "Request with $search query parameter only works through MSGraph with a special request header: 'ConsistencyLevel: eventual'"}
+		}
+		search, err := parseSearch(expr)
+		if err != nil {
+			return nil, &routeError{status: http.StatusBadRequest, code: "Request_UnsupportedQuery", message: fmt.Sprintf("Syntax error: %v", err)}
+		}
+		items, _ = filterObjects(items, func(obj Object) (bool, error) { return search.matches(obj), nil })
+	}
+	if orderBy := query.Get("$orderby"); orderBy != "" {
+		sortObjects(items, orderBy)
+	}
+
+	pageSize := defaultPageSize
+	if top := query.Get("$top"); top != "" {
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 0 || n > maxPageSize {
+			return nil, &routeError{status: http.StatusBadRequest, code: "Request_BadRequest", message: fmt.Sprintf("Invalid page size specified: '%v'. Must be between 1 and %v inclusive.", top, maxPageSize)}
+		}
+		pageSize = n
+	}
+	offset, err := strconv.Atoi(query.Get("$skiptoken"))
+	if err != nil {
+		offset, _ = strconv.Atoi(query.Get("$skip"))
+	}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + pageSize
+	if end > len(items) {
+		end = len(items)
+	}
+
+	page := make([]Object, 0, end-offset)
+	for _, item := range items[offset:end] {
+		selected, err := selectProperties(item, query.Get("$select"))
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, selected)
+	}
+	resp := Object{"value": page}
+	if query.Get("$count") == "true" {
+		resp["@odata.count"] = len(items)
+	}
+	if end < len(items) {
+		next := query
+		next.Del("$skip")
+		next.Set("$skiptoken", strconv.Itoa(end))
+		resp["@odata.nextLink"] = s.URL + r.URL.Path + "?" + next.Encode()
+	}
+	return resp, nil
+}
+
+// filterObjects returns the objs that match
+func filterObjects(objs []Object, match func(obj Object) (bool, error)) ([]Object, error) {
+	var matching = []Object{}
+	for _, obj := range objs {
+		ok, err := match(obj)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matching = append(matching, obj)
+		}
+	}
+	return matching, nil
+}
+
+// selectProperties returns a copy of obj that only contains the comma separated properties of
+// $select and the annotations like @odata.type. All properties are returned if $select is empty.
+func selectProperties(obj Object, selectParam string) (Object, error) {
+	if selectParam == "" {
+		return clone(obj), nil
+	}
+	selected := Object{}
+	for key, value := range obj {
+		if strings.HasPrefix(key, "@") {
+			selected[key] = value
+		}
+	}
+	for _, property := range strings.Split(selectParam, ",") {
+		property = strings.TrimSpace(property)
+		if property == "" {
+			return nil, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: "Invalid $select properties."}
+		}
+		if value, ok := obj[property]; ok {
+			selected[property] = value
+		}
+	}
+	return clone(selected), nil
+}
+
+// sortObjects sorts objs by the comma separated properties of $orderby, each optionally followed
+// by asc or desc
+func sortObjects(objs []Object, orderBy string) {
+	type order struct {
+		path []string
+		desc bool
+	}
+	var orders []order
+	for _, clause := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(clause)
+		if len(fields) == 0 {
+			continue
+		}
+		orders = append(orders, order{path: strings.Split(fields[0], "/"), desc: len(fields) > 1 && strings.EqualFold(fields[1], "desc")})
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		for _, o := range orders {
+			cmp := compareValues(lookup(objs[i], o.path), lookup(objs[j], o.path))
+			if cmp == 0 {
+				continue
+			}
+			return (cmp < 0) != o.desc
+		}
+		return false
+	})
+}
+
+// lookup returns the value of the property path of value, e.g. ["assignedLicenses", "skuId"]
+func lookup(value interface{}, path []string) interface{} {
+	for _, property := range path {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			if o, isObject := value.(Object); isObject {
+				obj, ok = o, true
+			}
+		}
+		if !ok {
+			return nil
+		}
+		value = obj[property]
+	}
+	return value
+}
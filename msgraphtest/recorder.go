@@ -0,0 +1,431 @@
+package msgraphtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// RecorderMode is the mode of a Recorder, either ModeReplay or ModeRecord
+type RecorderMode int
+
+const (
+	// ModeReplay - answer all requests with the interactions of the cassette, without any network access
+	ModeReplay RecorderMode = iota
+	// ModeRecord - perform all requests and record the sanitized interactions, see Recorder.Save
+	ModeRecord
+)
+
+// Redacted replaces secrets like access tokens, client secrets and SAS signatures in cassettes
+const Redacted = "REDACTED"
+
+// Recorder is a http.RoundTripper that records the requests of a GraphClient to a real tenant in a
+// cassette file and replays them offline, e.g. in go test:
+//
+//	mode := msgraphtest.ModeReplay
+//	if os.Getenv("MSGraphRecord") != "" {
+//		mode = msgraphtest.ModeRecord
+//	}
+//	rec, err := msgraphtest.NewRecorder("testdata/users.json", mode)
+//	defer rec.Save()
+//	graphClient, err := msgraph.NewGraphClient(tenantID, applicationID, clientSecret, msgraph.WithRoundTripper(rec))
+//
+// Recorded interactions are sanitized: access and refresh tokens, client secrets and assertions,
+// SAS signatures of the Azure storage, the Authorization header and secret properties of JSON
+// bodies like passwords and encryption keys are replaced by Redacted, the tenant ID and
+// application ID of token requests by DefaultTenantID and DefaultApplicationID.
+// Further values can be replaced with RecorderWithReplacement.
+//
+// Requests are matched on their method, path and normalized query, hence the order of the query
+// parameters and their encoding do not matter, but e.g. different $filter or $select values do.
+// Interactions with the same request are replayed in the order they have been recorded, the last
+// one is repeated once all of them have been used.
+type Recorder struct {
+	cassette  string
+	mode      RecorderMode
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	replacements []replacement // values that are replaced in all recorded requests and responses
+	interactions []*interaction
+	used         map[*interaction]bool // the interactions that have already been replayed
+}
+
+// RecorderOption configures optional settings of a Recorder, see NewRecorder
+type RecorderOption func(r *Recorder)
+
+var (
+	// RecorderWithTransport - perform the requests in ModeRecord with the given http.RoundTripper,
+	// defaults to http.DefaultTransport.
+	RecorderWithTransport = func(transport http.RoundTripper) RecorderOption {
+		return func(r *Recorder) {
+			r.transport = transport
+		}
+	}
+
+	// RecorderWithReplacement - replace value by placeholder in all recorded URLs, headers and
+	// bodies, e.g. the domain or userPrincipalNames of the tenant. Requests are replayed with the
+	// same replacement, hence the tests may use either of them.
+	RecorderWithReplacement = func(value, placeholder string) RecorderOption {
+		return func(r *Recorder) {
+			r.addReplacement(value, placeholder)
+		}
+	}
+)
+
+// replacement replaces value by placeholder
+type replacement struct {
+	value       string
+	placeholder string
+}
+
+// interaction is a recorded request and its response as stored in the cassette
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   recordedBody `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int          `json:"statusCode"`
+	Header     http.Header  `json:"header,omitempty"`
+	Body       recordedBody `json:"body,omitempty"`
+}
+
+// recordedBody is stored as string if it is valid UTF-8, otherwise base64 encoded, e.g. photos
+type recordedBody []byte
+
+func (b recordedBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *recordedBody) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = []byte(s)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	*b = decoded
+	return err
+}
+
+var (
+	// oauthPath matches the endpoints of the Azure AD authentication endpoint, the first submatch is the tenant
+	oauthPath = regexp.MustCompile(`^/([^/]+)/oauth2/(?:v2\.0/)?(?:token|devicecode|authorize)$`)
+	// sasSignature matches the signature of SAS URIs of the Azure storage, in URLs and JSON bodies
+	sasSignature = regexp.MustCompile(`((?:[?&]|&amp;|\\u0026)sig=)[^&"\s\\]+`)
+	// secretFormFields are the form fields of token requests that contain secrets
+	secretFormFields = []string{"client_secret", "client_assertion", "assertion", "refresh_token", "code", "device_code", "password"}
+	// secretTokenFields are the fields of token responses that contain secrets
+	secretTokenFields = []string{"access_token", "refresh_token", "id_token"}
+	// secretJSONProperties are the properties of JSON bodies that contain secrets, at any depth, e.g.
+	// the passwordProfile of CreateUser, added client secrets and the fileEncryptionInfo of Intune
+	secretJSONProperties = map[string]bool{
+		"password":        true,
+		"currentPassword": true,
+		"newPassword":     true,
+		"secretText":      true,
+		"encryptionKey":   true,
+		"macKey":          true,
+	}
+)
+
+// NewRecorder creates a new Recorder for the given cassette file. In ModeReplay the cassette is
+// loaded and an error is returned if it does not exist, in ModeRecord it is written by Save.
+func NewRecorder(cassette string, mode RecorderMode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		cassette:  cassette,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		used:      map[*interaction]bool{},
+	}
+	for idx := range opts {
+		opts[idx](r)
+	}
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := ioutil.ReadFile(cassette)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.interactions); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal cassette %v: %w", cassette, err)
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper. In ModeRecord the request is performed and recorded, in
+// ModeReplay the recorded response of the first matching and not yet replayed interaction is returned.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read request body: %w", err)
+		}
+	}
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+// record performs req and records the sanitized interaction
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if req.Body != nil {
+		out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response body: %w", err)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recorded := r.sanitizeRequest(req, body)
+	r.interactions = append(r.interactions, &interaction{
+		Request: recorded,
+		Response: recordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.sanitizeHeader(resp.Header),
+			Body:       r.sanitizeResponseBody(respBody),
+		},
+	})
+	return resp, nil
+}
+
+// replay returns the response of the first matching interaction that has not been replayed yet
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := matchKey(r.sanitizeRequest(req, body))
+	var match *interaction
+	for _, i := range r.interactions {
+		if matchKey(i.Request) != key {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no interaction recorded in cassette %v for %v", r.cassette, key)
+	}
+	r.used[match] = true
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", match.Response.StatusCode, http.StatusText(match.Response.StatusCode)),
+		StatusCode:    match.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        match.Response.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(match.Response.Body)),
+		ContentLength: int64(len(match.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded interactions to the cassette file in ModeRecord, the directory is
+// created if necessary. Save does nothing in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.cassette), 0755); err != nil {
+		return fmt.Errorf("cannot create directory of cassette: %w", err)
+	}
+	return ioutil.WriteFile(r.cassette, data, 0644)
+}
+
+// addReplacement adds a replacement of value by placeholder, unless it is empty or already known.
+// The query escaped value is replaced too, e.g. secrets in form encoded bodies.
+func (r *Recorder) addReplacement(value, placeholder string) {
+	if value == "" || value == placeholder {
+		return
+	}
+	for _, rep := range r.replacements {
+		if rep.value == value {
+			return
+		}
+	}
+	r.replacements = append(r.replacements, replacement{value: value, placeholder: placeholder})
+	if escaped := url.QueryEscape(value); escaped != value {
+		r.replacements = append(r.replacements, replacement{value: escaped, placeholder: url.QueryEscape(placeholder)})
+	}
+	// replace longer values first, hence values that contain other values are replaced completely
+	sort.SliceStable(r.replacements, func(i, j int) bool {
+		return len(r.replacements[i].value) > len(r.replacements[j].value)
+	})
+}
+
+// replace applies all replacements and redacts SAS signatures
+func (r *Recorder) replace(s string) string {
+	for _, rep := range r.replacements {
+		s = strings.ReplaceAll(s, rep.value, rep.placeholder)
+	}
+	return sasSignature.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// sanitizeRequest returns the sanitized request. The tenant ID, application ID and secrets of token
+// requests are added to the replacements first, hence they are scrubbed from all interactions.
+func (r *Recorder) sanitizeRequest(req *http.Request, body []byte) recordedRequest {
+	if m := oauthPath.FindStringSubmatch(req.URL.Path); m != nil {
+		switch strings.ToLower(m[1]) {
+		case "common", "organizations", "consumers":
+		default:
+			r.addReplacement(m[1], DefaultTenantID)
+		}
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			r.addReplacement(form.Get("client_id"), DefaultApplicationID)
+			for _, field := range secretFormFields {
+				r.addReplacement(form.Get(field), Redacted)
+			}
+		}
+	}
+	return recordedRequest{
+		Method: req.Method,
+		URL:    r.replace(req.URL.String()),
+		Header: r.sanitizeHeader(req.Header),
+		Body:   recordedBody(r.replace(string(redactJSON(body)))),
+	}
+}
+
+// sanitizeHeader returns a copy of header without cookies and with a redacted Authorization header.
+// Content-Length is removed, because sanitizing changes the length of the body.
+func (r *Recorder) sanitizeHeader(header http.Header) http.Header {
+	sanitized := http.Header{}
+	for key, values := range header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Cookie", "Set-Cookie", "Content-Length":
+			continue
+		case "Authorization":
+			sanitized.Set(key, "Bearer "+Redacted)
+			continue
+		}
+		for _, value := range values {
+			sanitized.Add(key, r.replace(value))
+		}
+	}
+	if len(sanitized) == 0 {
+		return nil
+	}
+	return sanitized
+}
+
+// sanitizeResponseBody redacts the tokens of token responses and removes their expires_on and
+// not_before, hence replayed tokens are valid for expires_in from the time they are replayed.
+func (r *Recorder) sanitizeResponseBody(body []byte) recordedBody {
+	var token map[string]interface{}
+	if json.Unmarshal(body, &token) == nil && token["access_token"] != nil {
+		for _, field := range secretTokenFields {
+			if _, ok := token[field]; ok {
+				token[field] = Redacted
+			}
+		}
+		delete(token, "expires_on")
+		delete(token, "not_before")
+		if sanitized, err := json.Marshal(token); err == nil {
+			body = sanitized
+		}
+	}
+	return recordedBody(r.replace(string(redactJSON(body))))
+}
+
+// redactJSON replaces the string values of secretJSONProperties in a JSON body by Redacted. Bodies
+// that are no JSON or do not contain any of these properties are returned unchanged.
+func redactJSON(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // keep large numbers as they are
+	var v interface{}
+	if dec.Decode(&v) != nil || !redactSecrets(v) {
+		return body
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return body
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// redactSecrets redacts the secretJSONProperties of the decoded JSON value v in place and returns
+// true if any of them has been found
+func redactSecrets(v interface{}) bool {
+	var redacted bool
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, ok := value.(string); ok && secretJSONProperties[key] {
+				v[key] = Redacted
+				redacted = true
+			} else {
+				redacted = redactSecrets(value) || redacted
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			redacted = redactSecrets(item) || redacted
+		}
+	}
+	return redacted
+}
+
+// matchKey returns the method, path and normalized query of req, by which requests are matched
+func matchKey(req recordedRequest) string {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return req.Method + " " + req.URL
+	}
+	key := req.Method + " " + u.Path
+	if query := u.Query(); len(query) > 0 {
+		key += "?" + query.Encode() // sorted by key, with a consistent encoding
+	}
+	return key
+}
@@ -0,0 +1,113 @@
+package msgraphtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	s := NewServer()
+	s.TenantID = "contoso-tenant"
+	s.ClientSecret = "se~cr+et/value"
+	app, _ := s.Add("/deviceAppManagement/mobileApps", Object{"displayName": "App"})
+	s.Add("/users", Object{"displayName": "Alice", "userPrincipalName": "alice@contoso.com"})
+	s.Add("/users", Object{"displayName": "Bob", "userPrincipalName": "bob@contoso.com"})
+	version, _ := s.Add("/deviceAppManagement/mobileApps/"+app["id"].(string)+"/microsoft.graph.win32LobApp/contentVersions", Object{})
+	filesPath := "/beta/deviceAppManagement/mobileApps/" + app["id"].(string) + "/microsoft.graph.win32LobApp/contentVersions/" + version["id"].(string) + "/files"
+	cassette := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+
+	// run performs the same requests in both modes and returns the response bodies
+	run := func(rec *Recorder) []string {
+		client := &http.Client{Transport: rec}
+		resp, err := client.PostForm(s.URL+"/contoso-tenant/oauth2/token", url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {s.ApplicationID},
+			"client_secret": {s.ClientSecret},
+			"resource":      {s.URL},
+		})
+		if err != nil {
+			t.Fatalf("token request error = %v", err)
+		}
+		var token struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(resp.Body).Decode(&token)
+		resp.Body.Close()
+
+		var bodies []string
+		for _, path := range []string{
+			"/v1.0/users?$select=displayName&$filter=" + url.QueryEscape("displayName eq 'Alice'"),
+			"/v1.0/users?$filter=" + url.QueryEscape("displayName eq 'Bob'") + "&$select=displayName",
+			filesPath,
+		} {
+			req, _ := http.NewRequest(http.MethodGet, s.URL+path, nil)
+			req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+			if path == filesPath {
+				req.Method = http.MethodPost
+				req.Body = ioutil.NopCloser(strings.NewReader(`{"name":"app.intunewin","fileEncryptionInfo":{"encryptionKey":"enc-key=","macKey":"mac-key=","fileDigestAlgorithm":"SHA256"}}`))
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request %v error = %v", path, err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			bodies = append(bodies, string(body))
+		}
+		return bodies
+	}
+
+	rec, err := NewRecorder(cassette, ModeRecord)
+	if err != nil {
+		t.Fatalf("NewRecorder(ModeRecord) error = %v", err)
+	}
+	recorded := run(rec)
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := ioutil.ReadFile(cassette)
+	if err != nil {
+		t.Fatalf("cannot read cassette: %v", err)
+	}
+	for _, secret := range []string{"contoso-tenant", s.ClientSecret, url.QueryEscape(s.ClientSecret), "sig=msgraphtest", "expires_on", "enc-key=", "mac-key="} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q:\n%s", secret, data)
+		}
+	}
+	for _, want := range []string{DefaultTenantID, "sig=" + Redacted, `\"access_token\":\"` + Redacted + `\"`, `\"macKey\":\"` + Redacted + `\"`, "fileDigestAlgorithm"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("cassette does not contain %q:\n%s", want, data)
+		}
+	}
+
+	// replay the same requests with the query parameters in another order and without network access
+	s.Close()
+	rec, err = NewRecorder(cassette, ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder(ModeReplay) error = %v", err)
+	}
+	replayed := run(rec)
+	for i := range recorded {
+		if replayed[i] != recorded[i] && i < 2 {
+			t.Errorf("replayed response %d = %v, want %v", i, replayed[i], recorded[i])
+		}
+	}
+	if !strings.Contains(replayed[0], "Alice") || !strings.Contains(replayed[1], "Bob") {
+		t.Errorf("replayed responses %v do not match their $filter", replayed[:2])
+	}
+	if !strings.Contains(replayed[2], "sig="+Redacted) {
+		t.Errorf("replayed azureStorageUri is not redacted: %v", replayed[2])
+	}
+
+	if _, err := (&http.Client{Transport: rec}).Get(s.URL + "/v1.0/groups"); err == nil || !strings.Contains(err.Error(), "GET /v1.0/groups") {
+		t.Errorf("replay of an unknown request error = %v, want no interaction recorded", err)
+	}
+	if _, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay); err == nil {
+		t.Errorf("NewRecorder(ModeReplay) of a missing cassette error = nil")
+	}
+}
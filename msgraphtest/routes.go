@@ -0,0 +1,397 @@
+package msgraphtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	tokenPath         = regexp.MustCompile(`^/([^/]+)/oauth2/(v2\.0/)?token$`)
+	apiPath           = regexp.MustCompile(`^/(v1\.0|beta)(/.*)$`)
+	membersPath       = regexp.MustCompile(`^/groups/([^/]+)/members$`)
+	memberRefPath     = regexp.MustCompile(`^/groups/([^/]+)/members/\$ref$`)
+	calendarViewPath  = regexp.MustCompile(`^(/users/[^/]+)/calendar/(?i:calendarView)$`)
+	timeZonesPath     = regexp.MustCompile(`^/users/[^/]+/outlook/supportedTimeZones$`)
+	contentFileAction = regexp.MustCompile(`^(/deviceAppManagement/mobileApps/[^/]+/microsoft\.graph\.win32LobApp/contentVersions/[^/]+/files/[^/]+)/(renewUpload|commit)$`)
+	blobPath          = regexp.MustCompile(`^/blob/([^/]+)$`)
+)
+
+// routeError is an error of the msgraph API that is written as OData error
+type routeError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *routeError) Error() string {
+	return fmt.Sprintf("%v: %v", e.code, e.message)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	s.requestLock.Lock()
+	s.requests = append(s.requests, r)
+	s.requestLock.Unlock()
+
+	w.Header().Set("request-id", newGUID())
+	switch {
+	case tokenPath.MatchString(r.URL.Path):
+		s.serveToken(w, r)
+	case blobPath.MatchString(r.URL.Path):
+		s.serveBlob(w, r, body)
+	case apiPath.MatchString(r.URL.Path):
+		m := apiPath.FindStringSubmatch(r.URL.Path)
+		if err := s.authenticate(r); err != nil {
+			writeError(w, err)
+			return
+		}
+		s.serveAPI(w, r, m[1], m[2], body)
+	default:
+		writeError(w, &routeError{status: http.StatusNotFound, code: "NotFound", message: fmt.Sprintf("Path %v is not served by msgraphtest.", r.URL.Path)})
+	}
+}
+
+// authenticate checks the bearer token of the request
+func (s *Server) authenticate(r *http.Request) error {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	expiresOn, ok := s.tokens[token]
+	s.mu.Unlock()
+	switch {
+	case token == "":
+		return &routeError{status: http.StatusUnauthorized, code: "InvalidAuthenticationToken", message: "Access token is empty."}
+	case !ok:
+		return &routeError{status: http.StatusUnauthorized, code: "InvalidAuthenticationToken", message: "Access token validation failure. Invalid audience."}
+	case time.Now().After(expiresOn):
+		return &routeError{status: http.StatusUnauthorized, code: "InvalidAuthenticationToken", message: "Lifetime validation failed, the token is expired."}
+	}
+	return nil
+}
+
+// serveAPI serves a request of the msgraph API with the given apiVersion and path, e.g. "/users"
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request, apiVersion, path string, body []byte) {
+	s.mu.Lock()
+	handler, custom := s.handlers[r.Method+" "+path]
+	s.mu.Unlock()
+	if custom {
+		handler(w, r)
+		return
+	}
+	if path == "/$batch" && r.Method == http.MethodPost {
+		s.serveBatch(w, r, apiVersion, body)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var status int
+	var resp interface{}
+	var err error
+	switch {
+	case path == "/me":
+		err = &routeError{status: http.StatusBadRequest, code: "BadRequest", message: "/me request is only valid with delegated authentication flow."}
+	case membersPath.MatchString(path) && r.Method == http.MethodGet:
+		resp, err = s.listMembers(r, membersPath.FindStringSubmatch(path)[1])
+	case memberRefPath.MatchString(path) && r.Method == http.MethodPost:
+		status, err = s.addMemberRef(memberRefPath.FindStringSubmatch(path)[1], body)
+	case calendarViewPath.MatchString(path) && r.Method == http.MethodGet:
+		resp, err = s.listCalendarView(r, calendarViewPath.FindStringSubmatch(path)[1])
+	case timeZonesPath.MatchString(path) && r.Method == http.MethodGet:
+		resp, err = s.listObjects(r, supportedTimeZones())
+	case contentFileAction.MatchString(path) && r.Method == http.MethodPost:
+		m := contentFileAction.FindStringSubmatch(path)
+		status, err = s.contentFileAction(m[1], m[2])
+	default:
+		status, resp, err = s.serveObjects(r, apiVersion, path, body)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, resp)
+}
+
+// serveObjects serves the generic collections: GET and POST of a collection, GET, PATCH and
+// DELETE of an Object.
+func (s *Server) serveObjects(r *http.Request, apiVersion, path string, body []byte) (int, interface{}, error) {
+	if colPath, colType, err := s.resolveCollection(path); err == nil {
+		switch r.Method {
+		case http.MethodGet:
+			resp, err := s.listObjects(r, s.collections[colPath])
+			return http.StatusOK, resp, err
+		case http.MethodPost:
+			var obj Object
+			if err := json.Unmarshal(body, &obj); err != nil {
+				return 0, nil, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: "Invalid request body: " + err.Error()}
+			}
+			created, err := s.create(colPath, colType, obj)
+			if err != nil {
+				return 0, nil, err
+			}
+			created["@odata.context"] = s.odataContext(apiVersion, colPath)
+			return http.StatusCreated, created, nil
+		}
+		return 0, nil, errMethodNotAllowed(r.Method)
+	}
+
+	colPath, idx, colType, err := s.resolveObject(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	switch r.Method {
+	case http.MethodGet:
+		obj, err := selectProperties(s.collections[colPath][idx], r.URL.Query().Get("$select"))
+		if err != nil {
+			return 0, nil, err
+		}
+		obj["@odata.context"] = s.odataContext(apiVersion, colPath)
+		return http.StatusOK, obj, nil
+	case http.MethodPatch:
+		var update Object
+		if err := json.Unmarshal(body, &update); err != nil {
+			return 0, nil, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: "Invalid request body: " + err.Error()}
+		}
+		obj := s.collections[colPath][idx]
+		for key, value := range update {
+			if key != "id" && !strings.HasPrefix(key, "@") {
+				obj[key] = value
+			}
+		}
+		if colType.patchReturns {
+			updated := clone(obj)
+			updated["@odata.context"] = s.odataContext(apiVersion, colPath)
+			return http.StatusOK, updated, nil
+		}
+		return http.StatusNoContent, nil, nil
+	case http.MethodDelete:
+		s.delete(colPath, idx)
+		return http.StatusNoContent, nil, nil
+	}
+	return 0, nil, errMethodNotAllowed(r.Method)
+}
+
+// listMembers lists the users and groups that are members of the group
+func (s *Server) listMembers(r *http.Request, groupID string) (interface{}, error) {
+	groupPath, _, _, err := s.resolveObjectPath("/groups/" + groupID)
+	if err != nil {
+		return nil, err
+	}
+	var members []Object
+	for _, memberID := range s.members[strings.TrimPrefix(groupPath, "/groups/")] {
+		if member := s.findDirectoryObject(memberID); member != nil {
+			members = append(members, member)
+		}
+	}
+	return s.listObjects(r, members)
+}
+
+// addMemberRef adds the directory object referenced by the @odata.id of the body to the group
+func (s *Server) addMemberRef(groupID string, body []byte) (int, error) {
+	var ref struct {
+		ODataID string `json:"@odata.id"`
+	}
+	if err := json.Unmarshal(body, &ref); err != nil || ref.ODataID == "" {
+		return 0, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: "The @odata.id of the member is missing."}
+	}
+	groupPath, _, _, err := s.resolveObjectPath("/groups/" + groupID)
+	if err != nil {
+		return 0, err
+	}
+	groupID = strings.TrimPrefix(groupPath, "/groups/")
+	memberID := ref.ODataID[strings.LastIndex(ref.ODataID, "/")+1:]
+	if s.findDirectoryObject(memberID) == nil {
+		return 0, &routeError{status: http.StatusNotFound, code: "Request_ResourceNotFound", message: fmt.Sprintf("Resource '%v' does not exist or one of its queried reference-property objects are not present.", memberID)}
+	}
+	for _, existing := range s.members[groupID] {
+		if existing == memberID {
+			return 0, &routeError{status: http.StatusBadRequest, code: "Request_BadRequest", message: "One or more added object references already exist for the following modified properties: 'members'."}
+		}
+	}
+	s.members[groupID] = append(s.members[groupID], memberID)
+	return http.StatusNoContent, nil
+}
+
+// listCalendarView lists the events of the user that overlap the startDateTime and endDateTime of the query
+func (s *Server) listCalendarView(r *http.Request, userPath string) (interface{}, error) {
+	query := lowerKeys(r.URL.Query())
+	start, errStart := parseDateTime(query.Get("startdatetime"))
+	end, errEnd := parseDateTime(query.Get("enddatetime"))
+	if errStart != nil || errEnd != nil {
+		return nil, &routeError{status: http.StatusBadRequest, code: "ErrorInvalidParameter", message: "This request requires a time window specified by the query string parameters StartDateTime and EndDateTime."}
+	}
+	colPath, _, err := s.resolveCollection(userPath + "/events")
+	if err != nil {
+		return nil, err
+	}
+	var events []Object
+	for _, event := range s.collections[colPath] {
+		eventStart, errStart := parseEventTime(event["start"])
+		eventEnd, errEnd := parseEventTime(event["end"])
+		if errStart == nil && errEnd == nil && eventStart.Before(end) && eventEnd.After(start) {
+			events = append(events, event)
+		}
+	}
+	return s.listObjects(r, events)
+}
+
+// contentFileAction performs the renewUpload or commit action of a mobileAppContentFile
+func (s *Server) contentFileAction(filePath, action string) (int, error) {
+	colPath, idx, _, err := s.resolveObject(filePath)
+	if err != nil {
+		return 0, err
+	}
+	file := s.collections[colPath][idx]
+	switch action {
+	case "renewUpload":
+		file["azureStorageUriExpirationDateTime"] = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		file["uploadState"] = "azureStorageUriRenewalSuccess"
+	case "commit":
+		if b := s.blobs[fmt.Sprint(file["id"])]; b != nil && b.committed {
+			file["uploadState"] = "commitFileSuccess"
+			file["isCommitted"] = true
+		} else {
+			file["uploadState"] = "commitFileFailed"
+		}
+	}
+	return http.StatusNoContent, nil
+}
+
+// batchRequest is a request of a JSON batch, see https://docs.microsoft.com/en-us/graph/json-batching
+type batchRequest struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// serveBatch performs the requests of a JSON batch one after the other
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request, apiVersion string, body []byte) {
+	var batch struct {
+		Requests []batchRequest `json:"requests"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		writeError(w, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: "Invalid batch payload: " + err.Error()})
+		return
+	}
+	if len(batch.Requests) > 20 {
+		writeError(w, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: "The number of batch requests exceeds the maximum of 20."})
+		return
+	}
+
+	type batchResponse struct {
+		ID      string            `json:"id"`
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    json.RawMessage   `json:"body,omitempty"`
+	}
+	var responses = []batchResponse{}
+	for _, item := range batch.Requests {
+		req := httptest.NewRequest(item.Method, "/"+apiVersion+"/"+strings.TrimPrefix(item.URL, "/"), bytes.NewReader(item.Body))
+		req.Header.Set("Authorization", r.Header.Get("Authorization"))
+		for key, value := range item.Headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		s.serveAPI(rec, req, apiVersion, req.URL.Path[len(apiVersion)+1:], item.Body)
+
+		resp := batchResponse{ID: item.ID, Status: rec.Code, Headers: map[string]string{}}
+		for key := range rec.Header() {
+			resp.Headers[key] = rec.Header().Get(key)
+		}
+		if rec.Body.Len() > 0 {
+			resp.Body = rec.Body.Bytes()
+		}
+		responses = append(responses, resp)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"responses": responses})
+}
+
+// writeJSON writes v as JSON response, no content is written for 204 - No Content
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	if status == http.StatusNoContent || v == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as OData error of the msgraph API
+func writeError(w http.ResponseWriter, err error) {
+	routeErr, ok := err.(*routeError)
+	if !ok {
+		routeErr = &routeError{status: http.StatusInternalServerError, code: "InternalServerError", message: err.Error()}
+	}
+	writeJSON(w, routeErr.status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    routeErr.code,
+			"message": routeErr.message,
+			"innerError": map[string]string{
+				"date":       time.Now().UTC().Format("2006-01-02T15:04:05"),
+				"request-id": w.Header().Get("request-id"),
+			},
+		},
+	})
+}
+
+func errMethodNotAllowed(method string) error {
+	return &routeError{status: http.StatusMethodNotAllowed, code: "Request_BadRequest", message: fmt.Sprintf("The HTTP method %v is not supported for this resource.", method)}
+}
+
+// lowerKeys returns the query with lowercase keys, because some parameters are case-insensitive
+func lowerKeys(query url.Values) url.Values {
+	lower := url.Values{}
+	for key, values := range query {
+		lower[strings.ToLower(key)] = values
+	}
+	return lower
+}
+
+// parseDateTime parses a date and time with or without offset, times without offset are UTC
+func parseDateTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02T15:04:05.999999999", value)
+}
+
+// parseEventTime parses the dateTimeTimeZone of an event, e.g. its start
+func parseEventTime(value interface{}) (time.Time, error) {
+	dateTimeTimeZone, _ := value.(map[string]interface{})
+	dateTime, _ := dateTimeTimeZone["dateTime"].(string)
+	t, err := parseDateTime(dateTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	timeZone, _ := dateTimeTimeZone["timeZone"].(string)
+	if loc, err := time.LoadLocation(timeZone); err == nil && timeZone != "" {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	}
+	return t, nil
+}
+
+// supportedTimeZones returns the time zones of the outlook/supportedTimeZones function
+func supportedTimeZones() []Object {
+	return []Object{
+		{"alias": "UTC", "displayName": "(UTC) Coordinated Universal Time"},
+		{"alias": "GMT Standard Time", "displayName": "(UTC+00:00) Dublin, Edinburgh, Lisbon, London"},
+		{"alias": "W. Europe Standard Time", "displayName": "(UTC+01:00) Amsterdam, Berlin, Bern, Rome, Stockholm, Vienna"},
+		{"alias": "Central European Standard Time", "displayName": "(UTC+01:00) Sarajevo, Skopje, Warsaw, Zagreb"},
+		{"alias": "Eastern Standard Time", "displayName": "(UTC-05:00) Eastern Time (US & Canada)"},
+		{"alias": "Pacific Standard Time", "displayName": "(UTC-08:00) Pacific Time (US & Canada)"},
+	}
+}
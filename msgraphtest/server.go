@@ -0,0 +1,355 @@
+// Package msgraphtest provides an in-process fake of the Microsoft Graph API and the Azure AD
+// authentication endpoint for offline tests. A Server keeps users, groups and their members,
+// calendars and events, subscriptions, Intune mobileApps with their contentVersions and files,
+// and the blobs uploaded to the Azure storage in memory:
+//
+//	srv := msgraphtest.NewServer()
+//	defer srv.Close()
+//	user, _ := srv.Add("/users", msgraphtest.Object{"displayName": "Alice", "userPrincipalName": "alice@contoso.com"})
+//
+//	graphClient, err := msgraph.NewGraphClientWithCustomEndpoint(srv.TenantID, srv.ApplicationID, srv.ClientSecret, srv.URL, srv.URL)
+//	alice, err := graphClient.GetUser("alice@contoso.com")
+//
+// Both the v1.0 and beta API versions serve the same objects. Collections support the query
+// options $select, $filter, $search, $orderby, $top, $skip and $count and are paged with an
+// @odata.nextLink. $expand is ignored. The package does not depend on the msgraph package, hence
+// it can be used by the tests of msgraph itself.
+//
+// A Recorder records the requests to a real tenant in a cassette file instead and replays them offline.
+package msgraphtest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default credentials of a Server, which are accepted by its token endpoint
+const (
+	DefaultTenantID      = "00000000-0000-0000-0000-00000000000a"
+	DefaultApplicationID = "00000000-0000-0000-0000-00000000000b"
+	DefaultClientSecret  = "msgraphtest-client-secret"
+)
+
+// Object is a JSON object of the msgraph API, e.g. a user, as it is stored by the Server
+type Object map[string]interface{}
+
+// Server is a fake of the msgraph API and the Azure AD authentication endpoint, which are both
+// served by the embedded httptest.Server. A Server is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	// the credentials accepted by the token endpoint, set to the defaults by NewServer. They may be
+	// changed before the first Token is requested.
+	TenantID      string
+	ApplicationID string
+	ClientSecret  string
+
+	mu          sync.Mutex
+	tokens      map[string]time.Time // the issued access tokens and their expiry
+	collections map[string][]Object  // the objects of each collection, keyed by the canonical collection path
+	members     map[string][]string  // the IDs of the members of each group, keyed by the group ID
+	blobs       map[string]*blob     // the blobs of the Azure storage, keyed by the ID of the mobileAppContentFile
+	handlers    map[string]RouteFunc // custom routes, see Handle
+	requests    []*http.Request      // all requests received by the Server, see Requests
+	requestLock sync.Mutex           // guards requests, which are recorded before mu is held
+}
+
+// RouteFunc handles a request of a custom route, see Server.Handle
+type RouteFunc func(w http.ResponseWriter, r *http.Request)
+
+// NewServer starts a new Server without any objects, which must be closed after use
+func NewServer() *Server {
+	s := &Server{
+		TenantID:      DefaultTenantID,
+		ApplicationID: DefaultApplicationID,
+		ClientSecret:  DefaultClientSecret,
+		tokens:        map[string]time.Time{},
+		collections:   map[string][]Object{},
+		members:       map[string][]string{},
+		blobs:         map[string]*blob{},
+		handlers:      map[string]RouteFunc{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Handle registers fn for all requests whose method and API path - without the API version, e.g.
+// "/security/alerts" - match exactly. Custom routes take precedence over the routes of the Server
+// and are only called for authenticated requests.
+func (s *Server) Handle(method, path string, fn RouteFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method+" "+path] = fn
+}
+
+// Requests returns all requests the Server has received so far, including token requests. The
+// bodies of the requests have already been read.
+func (s *Server) Requests() []*http.Request {
+	s.requestLock.Lock()
+	defer s.requestLock.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// collectionType describes a collection of the Server
+type collectionType struct {
+	pattern       *regexp.Regexp // matches the path of the collection, the first submatch is the path of the parent object, if any
+	sequentialIDs bool           // the IDs of new objects are sequential numbers instead of GUIDs, e.g. contentVersions
+	patchReturns  bool           // PATCH responds with the updated object instead of 204 - No Content, e.g. subscriptions
+}
+
+var collectionTypes = []collectionType{
+	{pattern: regexp.MustCompile(`^/users$`)},
+	{pattern: regexp.MustCompile(`^/groups$`)},
+	{pattern: regexp.MustCompile(`^/subscriptions$`), patchReturns: true},
+	{pattern: regexp.MustCompile(`^(/users/[^/]+)/calendars$`)},
+	{pattern: regexp.MustCompile(`^(/users/[^/]+)/events$`)},
+	{pattern: regexp.MustCompile(`^/deviceAppManagement/mobileApps$`)},
+	{pattern: regexp.MustCompile(`^(/deviceAppManagement/mobileApps/[^/]+)/assignments$`)},
+	{pattern: regexp.MustCompile(`^(/deviceAppManagement/mobileApps/[^/]+)/microsoft\.graph\.win32LobApp/contentVersions$`), sequentialIDs: true},
+	{pattern: regexp.MustCompile(`^(/deviceAppManagement/mobileApps/[^/]+/microsoft\.graph\.win32LobApp/contentVersions/[^/]+)/files$`)},
+}
+
+// Add stores a copy of obj in the collection with the given path, e.g. "/users",
+// "/users/{id}/calendars", "/users/{id}/events" or "/deviceAppManagement/mobileApps", and returns
+// the stored Object. An id and the createdDateTime are set if obj does not contain them.
+// Users can be referenced by their userPrincipalName within the path.
+func (s *Server) Add(path string, obj Object) (Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	colPath, colType, err := s.resolveCollection(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.create(colPath, colType, obj)
+}
+
+// AddMember adds the directory object with the given ID, e.g. a user, to the members of the group
+func (s *Server) AddMember(groupID, memberID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, _, _, err := s.resolveObject("/groups/" + groupID); err != nil {
+		return err
+	}
+	if s.findDirectoryObject(memberID) == nil {
+		return fmt.Errorf("directory object %v does not exist", memberID)
+	}
+	s.members[groupID] = append(s.members[groupID], memberID)
+	return nil
+}
+
+// Get returns a copy of the Object with the given path, e.g. "/users/{id}", and false if it does not exist
+func (s *Server) Get(path string) (Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	colPath, idx, _, err := s.resolveObject(path)
+	if err != nil {
+		return nil, false
+	}
+	return clone(s.collections[colPath][idx]), true
+}
+
+// List returns copies of all Objects of the collection with the given path, e.g. "/users"
+func (s *Server) List(path string) []Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	colPath, _, err := s.resolveCollection(path)
+	if err != nil {
+		return nil
+	}
+	var objs []Object
+	for _, obj := range s.collections[colPath] {
+		objs = append(objs, clone(obj))
+	}
+	return objs
+}
+
+// resolveCollection returns the canonical path of the collection with the given path, hence with
+// the IDs of the parent objects instead of e.g. userPrincipalNames.
+func (s *Server) resolveCollection(path string) (string, *collectionType, error) {
+	for idx := range collectionTypes {
+		m := collectionTypes[idx].pattern.FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+		if len(m) == 1 {
+			return path, &collectionTypes[idx], nil
+		}
+		parentPath, _, _, err := s.resolveObjectPath(m[1])
+		if err != nil {
+			return "", nil, err
+		}
+		return parentPath + path[len(m[1]):], &collectionTypes[idx], nil
+	}
+	return "", nil, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: fmt.Sprintf("Resource not found for the segment '%v'.", path)}
+}
+
+// resolveObject returns the canonical path of the collection of the Object with the given path
+// and its index within that collection.
+func (s *Server) resolveObject(path string) (colPath string, idx int, colType *collectionType, err error) {
+	sep := strings.LastIndex(path, "/")
+	if sep <= 0 {
+		return "", 0, nil, &routeError{status: http.StatusBadRequest, code: "BadRequest", message: fmt.Sprintf("Resource not found for the segment '%v'.", path)}
+	}
+	colPath, colType, err = s.resolveCollection(path[:sep])
+	if err != nil {
+		return "", 0, nil, err
+	}
+	id := path[sep+1:]
+	for idx, obj := range s.collections[colPath] {
+		if obj["id"] == id || (colPath == "/users" && strings.EqualFold(fmt.Sprint(obj["userPrincipalName"]), id)) {
+			return colPath, idx, colType, nil
+		}
+	}
+	code := "Request_ResourceNotFound"
+	if !strings.HasPrefix(colPath, "/users") && !strings.HasPrefix(colPath, "/groups") {
+		code = "ResourceNotFound"
+	}
+	return "", 0, nil, &routeError{status: http.StatusNotFound, code: code, message: fmt.Sprintf("Resource '%v' does not exist or one of its queried reference-property objects are not present.", id)}
+}
+
+// resolveObjectPath returns the canonical path of the Object with the given path
+func (s *Server) resolveObjectPath(path string) (string, string, int, error) {
+	colPath, idx, _, err := s.resolveObject(path)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return colPath + "/" + fmt.Sprint(s.collections[colPath][idx]["id"]), colPath, idx, nil
+}
+
+// create stores a copy of obj in the collection with the canonical colPath and returns it
+func (s *Server) create(colPath string, colType *collectionType, obj Object) (Object, error) {
+	obj = clone(obj)
+	if obj == nil {
+		obj = Object{}
+	}
+	if id, ok := obj["id"].(string); !ok || id == "" {
+		obj["id"] = newGUID()
+		if colType.sequentialIDs {
+			obj["id"] = strconv.Itoa(s.nextSequentialID(colPath))
+		}
+	}
+	for _, existing := range s.collections[colPath] {
+		if existing["id"] == obj["id"] {
+			return nil, &routeError{status: http.StatusBadRequest, code: "Request_BadRequest", message: fmt.Sprintf("An object with id %v already exists.", obj["id"])}
+		}
+	}
+	switch {
+	case colPath == "/users":
+		upn, _ := obj["userPrincipalName"].(string)
+		if upn == "" {
+			return nil, &routeError{status: http.StatusBadRequest, code: "Request_BadRequest", message: "Property userPrincipalName is required."}
+		}
+		for _, existing := range s.collections[colPath] {
+			if strings.EqualFold(fmt.Sprint(existing["userPrincipalName"]), upn) {
+				return nil, &routeError{status: http.StatusBadRequest, code: "Request_BadRequest", message: "Another object with the same value for property userPrincipalName already exists."}
+			}
+		}
+		delete(obj, "passwordProfile") // never returned by the msgraph API
+	case strings.HasSuffix(colPath, "/files"):
+		s.initContentFile(obj)
+	}
+	if _, ok := obj["createdDateTime"]; !ok && !strings.HasSuffix(colPath, "/contentVersions") {
+		obj["createdDateTime"] = time.Now().UTC().Format(time.RFC3339)
+	}
+	s.collections[colPath] = append(s.collections[colPath], obj)
+	return clone(obj), nil
+}
+
+// nextSequentialID returns the next free sequential ID of the collection with the canonical colPath
+func (s *Server) nextSequentialID(colPath string) int {
+	next := 1
+	for _, obj := range s.collections[colPath] {
+		if id, err := strconv.Atoi(fmt.Sprint(obj["id"])); err == nil && id >= next {
+			next = id + 1
+		}
+	}
+	return next
+}
+
+// delete removes the Object with the given index and all Objects of its nested collections
+func (s *Server) delete(colPath string, idx int) {
+	objs := s.collections[colPath]
+	id := fmt.Sprint(objs[idx]["id"])
+	s.collections[colPath] = append(objs[:idx:idx], objs[idx+1:]...)
+
+	prefix := colPath + "/" + id + "/"
+	for path := range s.collections {
+		if strings.HasPrefix(path, prefix) {
+			delete(s.collections, path)
+		}
+	}
+	delete(s.members, id)
+	for groupID, memberIDs := range s.members {
+		for i := len(memberIDs) - 1; i >= 0; i-- {
+			if memberIDs[i] == id {
+				memberIDs = append(memberIDs[:i:i], memberIDs[i+1:]...)
+			}
+		}
+		s.members[groupID] = memberIDs
+	}
+	delete(s.blobs, id)
+}
+
+// findDirectoryObject returns the user or group with the given ID and its @odata.type, nil if none exists
+func (s *Server) findDirectoryObject(id string) Object {
+	for _, colPath := range []string{"/users", "/groups"} {
+		for _, obj := range s.collections[colPath] {
+			if obj["id"] == id {
+				obj = clone(obj)
+				obj["@odata.type"] = "#microsoft.graph." + strings.TrimSuffix(colPath[1:], "s")
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// odataContext returns the @odata.context of an Object of the collection with the canonical colPath, e.g.
+// "https://graph.microsoft.com/beta/$metadata#deviceAppManagement/mobileApps('{id}')/microsoft.graph.win32LobApp/contentVersions('1')/files/$entity"
+func (s *Server) odataContext(apiVersion, colPath string) string {
+	return s.URL + "/" + apiVersion + "/$metadata#" + odataContextPath(colPath) + "/$entity"
+}
+
+func odataContextPath(colPath string) string {
+	for _, colType := range collectionTypes {
+		m := colType.pattern.FindStringSubmatch(colPath)
+		if len(m) != 2 {
+			continue
+		}
+		sep := strings.LastIndex(m[1], "/")
+		parentColPath, parentID := m[1][:sep], m[1][sep+1:]
+		return odataContextPath(parentColPath) + "('" + parentID + "')" + colPath[len(m[1]):]
+	}
+	return strings.TrimPrefix(colPath, "/")
+}
+
+// clone returns a deep copy of obj
+func clone(obj Object) Object {
+	if obj == nil {
+		return nil
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		panic(fmt.Sprintf("msgraphtest: Object cannot be json encoded: %v", err))
+	}
+	var copied Object
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+// newGUID returns a random GUID in its lowercase string representation
+func newGUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,312 @@
+package msgraphtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestToken requests a new access token with the v2.0 client credentials flow
+func newTestToken(t *testing.T, s *Server) string {
+	t.Helper()
+	resp, err := http.PostForm(s.URL+"/"+s.TenantID+"/oauth2/v2.0/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.ApplicationID},
+		"client_secret": {s.ClientSecret},
+		"scope":         {s.URL + "/.default"},
+	})
+	if err != nil {
+		t.Fatalf("Cannot request a token: %v", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		t.Fatalf("Cannot decode the token response (status %v): %v", resp.StatusCode, err)
+	}
+	return token.AccessToken
+}
+
+// doTestRequest performs an authenticated request and decodes the JSON response into v, if any
+func doTestRequest(t *testing.T, s *Server, token, method, path string, header http.Header, body string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Cannot create request %v %v: %v", method, path, err)
+	}
+	for key := range header {
+		req.Header.Set(key, header.Get(key))
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Cannot perform request %v %v: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	if v != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("Cannot decode the response of %v %v: %v\n%s", method, path, err, data)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServer_token(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		form      url.Values
+		wantCode  int
+		wantError string
+	}{
+		{name: "v1", path: "/" + s.TenantID + "/oauth2/token", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {s.ApplicationID}, "client_secret": {s.ClientSecret}, "resource": {s.URL + "/"}}, wantCode: http.StatusOK},
+		{name: "v2 with assertion", path: "/" + s.TenantID + "/oauth2/v2.0/token", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {s.ApplicationID}, "client_assertion": {"eyJ.eyJ.sig"}, "scope": {s.URL + "/.default"}}, wantCode: http.StatusOK},
+		{name: "unknown tenant", path: "/other/oauth2/token", form: url.Values{"grant_type": {"client_credentials"}}, wantCode: http.StatusBadRequest, wantError: "invalid_request"},
+		{name: "unknown client", path: "/" + s.TenantID + "/oauth2/token", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"other"}}, wantCode: http.StatusBadRequest, wantError: "unauthorized_client"},
+		{name: "wrong secret", path: "/" + s.TenantID + "/oauth2/token", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {s.ApplicationID}, "client_secret": {"wrong"}}, wantCode: http.StatusUnauthorized, wantError: "invalid_client"},
+		{name: "wrong resource", path: "/" + s.TenantID + "/oauth2/token", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {s.ApplicationID}, "client_secret": {s.ClientSecret}, "resource": {"https://graph.microsoft.com"}}, wantCode: http.StatusBadRequest, wantError: "invalid_resource"},
+		{name: "wrong scope", path: "/" + s.TenantID + "/oauth2/v2.0/token", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {s.ApplicationID}, "client_secret": {s.ClientSecret}, "scope": {"https://graph.microsoft.com/.default"}}, wantCode: http.StatusBadRequest, wantError: "invalid_scope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.PostForm(s.URL+tt.path, tt.form)
+			if err != nil {
+				t.Fatalf("PostForm() error = %v", err)
+			}
+			defer resp.Body.Close()
+			var body map[string]interface{}
+			json.NewDecoder(resp.Body).Decode(&body)
+			if resp.StatusCode != tt.wantCode || (tt.wantError != "" && body["error"] != tt.wantError) {
+				t.Errorf("token status = %v, error = %v, want %v and %q", resp.StatusCode, body["error"], tt.wantCode, tt.wantError)
+			}
+		})
+	}
+
+	token := newTestToken(t, s)
+	if code := doTestRequest(t, s, token, http.MethodGet, "/v1.0/users", nil, "", nil); code != http.StatusOK {
+		t.Errorf("GET /users with a valid token = %v, want 200", code)
+	}
+	s.ExpireTokens()
+	if code := doTestRequest(t, s, token, http.MethodGet, "/v1.0/users", nil, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /users with an expired token = %v, want 401", code)
+	}
+}
+
+func TestServer_objects(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	token := newTestToken(t, s)
+
+	var created Object
+	if code := doTestRequest(t, s, token, http.MethodPost, "/beta/users", nil, `{"displayName":"Alice","userPrincipalName":"alice@contoso.com","passwordProfile":{"password":"secret"}}`, &created); code != http.StatusCreated {
+		t.Fatalf("POST /users = %v, want 201", code)
+	}
+	if created["id"] == nil || created["passwordProfile"] != nil || !strings.HasSuffix(created["@odata.context"].(string), "#users/$entity") {
+		t.Errorf("POST /users = %v, want an id and @odata.context without passwordProfile", created)
+	}
+	if code := doTestRequest(t, s, token, http.MethodPost, "/beta/users", nil, `{"displayName":"Alice","userPrincipalName":"ALICE@contoso.com"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("POST /users with a duplicate userPrincipalName = %v, want 400", code)
+	}
+
+	if code := doTestRequest(t, s, token, http.MethodPatch, "/beta/users/alice@contoso.com", nil, `{"jobTitle":"Engineer"}`, nil); code != http.StatusNoContent {
+		t.Errorf("PATCH /users/{upn} = %v, want 204", code)
+	}
+	var selected Object
+	doTestRequest(t, s, token, http.MethodGet, "/v1.0/users/"+created["id"].(string)+"?$select=jobTitle", nil, "", &selected)
+	if selected["jobTitle"] != "Engineer" || selected["displayName"] != nil {
+		t.Errorf("GET /users/{id}?$select=jobTitle = %v, want only jobTitle Engineer", selected)
+	}
+
+	group, _ := s.Add("/groups", Object{"displayName": "Engineers"})
+	if code := doTestRequest(t, s, token, http.MethodPost, "/v1.0/groups/"+group["id"].(string)+"/members/$ref", nil, `{"@odata.id":"`+s.URL+`/v1.0/directoryObjects/`+created["id"].(string)+`"}`, nil); code != http.StatusNoContent {
+		t.Errorf("POST /groups/{id}/members/$ref = %v, want 204", code)
+	}
+	var members struct{ Value []Object }
+	doTestRequest(t, s, token, http.MethodGet, "/v1.0/groups/"+group["id"].(string)+"/members", nil, "", &members)
+	if len(members.Value) != 1 || members.Value[0]["@odata.type"] != "#microsoft.graph.user" {
+		t.Errorf("GET /groups/{id}/members = %v, want the user", members.Value)
+	}
+
+	if code := doTestRequest(t, s, token, http.MethodDelete, "/v1.0/users/"+created["id"].(string), nil, "", nil); code != http.StatusNoContent {
+		t.Errorf("DELETE /users/{id} = %v, want 204", code)
+	}
+	if _, ok := s.Get("/users/" + created["id"].(string)); ok {
+		t.Errorf("Get() after DELETE found the user")
+	}
+	if members := s.List("/groups/" + group["id"].(string) + "/members"); len(members) != 0 {
+		t.Errorf("List() members after DELETE = %v, want none", members)
+	}
+	var graphErr struct {
+		Error struct{ Code string }
+	}
+	if code := doTestRequest(t, s, token, http.MethodGet, "/v1.0/users/"+created["id"].(string), nil, "", &graphErr); code != http.StatusNotFound || graphErr.Error.Code == "" {
+		t.Errorf("GET deleted user = %v %v, want 404 with an error code", code, graphErr)
+	}
+}
+
+func TestServer_query(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	token := newTestToken(t, s)
+	for _, name := range []string{"Carol", "alice", "Bob", "Dave", "Eve"} {
+		s.Add("/users", Object{"displayName": name, "userPrincipalName": strings.ToLower(name) + "@contoso.com", "accountEnabled": name != "Eve"})
+	}
+
+	var page struct {
+		Value    []Object
+		Count    int    `json:"@odata.count"`
+		NextLink string `json:"@odata.nextLink"`
+	}
+	doTestRequest(t, s, token, http.MethodGet, "/v1.0/users?$orderby=displayName&$top=2&$count=true&$select=displayName", nil, "", &page)
+	var names []string
+	for {
+		for _, user := range page.Value {
+			names = append(names, user["displayName"].(string))
+		}
+		if page.NextLink == "" {
+			break
+		}
+		path := strings.TrimPrefix(page.NextLink, s.URL)
+		page.NextLink = ""
+		doTestRequest(t, s, token, http.MethodGet, path, nil, "", &page)
+	}
+	if strings.Join(names, ",") != "alice,Bob,Carol,Dave,Eve" || page.Count != 5 {
+		t.Errorf("paged users = %v (count %v), want all users ordered by displayName", names, page.Count)
+	}
+
+	doTestRequest(t, s, token, http.MethodGet, "/v1.0/users?$filter="+url.QueryEscape("accountEnabled eq true and startswith(displayName,'a') or displayName in ('Eve')"), nil, "", &page)
+	if len(page.Value) != 2 {
+		t.Errorf("filtered users = %v, want alice and Eve", page.Value)
+	}
+
+	search := "/v1.0/users?$search=" + url.QueryEscape(`"displayName:da" OR "displayName:car"`)
+	if code := doTestRequest(t, s, token, http.MethodGet, search, nil, "", nil); code != http.StatusBadRequest {
+		t.Errorf("$search without ConsistencyLevel = %v, want 400", code)
+	}
+	doTestRequest(t, s, token, http.MethodGet, search, http.Header{"ConsistencyLevel": {"eventual"}}, "", &page)
+	if len(page.Value) != 2 {
+		t.Errorf("searched users = %v, want Carol and Dave", page.Value)
+	}
+
+	var batch struct {
+		Responses []struct {
+			ID     string
+			Status int
+		}
+	}
+	doTestRequest(t, s, token, http.MethodPost, "/v1.0/$batch", nil, `{"requests":[{"id":"1","method":"GET","url":"/users/bob@contoso.com"},{"id":"2","method":"GET","url":"/users/nobody@contoso.com"}]}`, &batch)
+	if len(batch.Responses) != 2 || batch.Responses[0].Status != http.StatusOK || batch.Responses[1].Status != http.StatusNotFound {
+		t.Errorf("$batch responses = %v, want 200 and 404", batch.Responses)
+	}
+}
+
+func TestServer_Handle(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	token := newTestToken(t, s)
+	s.Handle(http.MethodGet, "/security/alerts", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	if code := doTestRequest(t, s, token, http.MethodGet, "/beta/security/alerts", nil, "", nil); code != http.StatusTeapot {
+		t.Errorf("custom route = %v, want 418", code)
+	}
+	if code := doTestRequest(t, s, "invalid", http.MethodGet, "/beta/security/alerts", nil, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("custom route without a valid token = %v, want 401", code)
+	}
+	if n := len(s.Requests()); n != 3 {
+		t.Errorf("Requests() = %v requests, want 3", n)
+	}
+}
+
+func TestServer_blob(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	app, _ := s.Add("/deviceAppManagement/mobileApps", Object{"displayName": "App"})
+	version, _ := s.Add("/deviceAppManagement/mobileApps/"+app["id"].(string)+"/microsoft.graph.win32LobApp/contentVersions", Object{})
+	if version["id"] != "1" {
+		t.Errorf("contentVersion id = %v, want 1", version["id"])
+	}
+	file, _ := s.Add("/deviceAppManagement/mobileApps/"+app["id"].(string)+"/microsoft.graph.win32LobApp/contentVersions/1/files", Object{"name": "app.intunewin"})
+	uri := file["azureStorageUri"].(string)
+
+	put := func(query, body string) int {
+		req, _ := http.NewRequest(http.MethodPut, uri+"&"+query, bytes.NewReader([]byte(body)))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT %v error = %v", query, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	put("comp=block&blockid=YQ==", "hello ")
+	put("comp=block&blockid=Yg==", "world")
+	if _, ok := s.Blob(file["id"].(string)); ok {
+		t.Errorf("Blob() before commit returned content")
+	}
+	if code := put("comp=blocklist", `<BlockList><Latest>Yw==</Latest></BlockList>`); code != http.StatusBadRequest {
+		t.Errorf("PUT unknown block list = %v, want 400", code)
+	}
+	if code := put("comp=blocklist", `<BlockList><Latest>YQ==</Latest><Latest>Yg==</Latest></BlockList>`); code != http.StatusCreated {
+		t.Errorf("PUT block list = %v, want 201", code)
+	}
+	if content, _ := s.Blob(file["id"].(string)); string(content) != "hello world" {
+		t.Errorf("Blob() = %q, want %q", content, "hello world")
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	obj := Object{
+		"displayName":     "O'Brien",
+		"mail":            "obrien@contoso.com",
+		"accountEnabled":  true,
+		"age":             42.0,
+		"createdDateTime": "2021-03-01T10:00:00Z",
+		"groupTypes":      []interface{}{"Unified"},
+		"assignedLicenses": []interface{}{
+			map[string]interface{}{"skuId": "sku-1"},
+		},
+	}
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{expr: "displayName eq 'o''brien'", want: true},
+		{expr: "accountEnabled eq true and age gt 40", want: true},
+		{expr: "age lt 40 or mail eq 'obrien@contoso.com'", want: true},
+		{expr: "not (age ge 42)", want: false},
+		{expr: "createdDateTime ge 2021-01-01T00:00:00Z", want: true},
+		{expr: "mail in ('a@contoso.com', 'obrien@contoso.com')", want: true},
+		{expr: "endswith(mail,'@contoso.com') and contains(displayName,'brie')", want: true},
+		{expr: "groupTypes/any(c:c eq 'Unified')", want: true},
+		{expr: "assignedLicenses/any(l:l/skuId eq 'sku-2')", want: false},
+		{expr: "assignedLicenses/any()", want: true},
+		{expr: "displayName eq", wantErr: true},
+		{expr: "(age gt 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := parseFilter(tt.expr)
+			if err == nil {
+				var got bool
+				got, err = f.matches(obj)
+				if err == nil && got != tt.want {
+					t.Errorf("matches() = %v, want %v", got, tt.want)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
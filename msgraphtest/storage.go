@@ -0,0 +1,98 @@
+package msgraphtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// blob is a block blob of the Azure storage, to which the content of a mobileAppContentFile is uploaded
+type blob struct {
+	blocks    map[string][]byte // the uploaded, uncommitted blocks by block ID
+	content   []byte            // the content of the committed block list
+	committed bool
+}
+
+// initContentFile initializes a new mobileAppContentFile, whose Azure storage is ready right away
+func (s *Server) initContentFile(file Object) {
+	id := fmt.Sprint(file["id"])
+	s.blobs[id] = &blob{blocks: map[string][]byte{}}
+	file["azureStorageUri"] = s.URL + "/blob/" + id + "?sv=2019-02-02&sr=b&sp=rcw&sig=msgraphtest"
+	file["azureStorageUriExpirationDateTime"] = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	file["uploadState"] = "azureStorageUriRequestSuccess"
+	file["isCommitted"] = false
+}
+
+// Blob returns the committed content that has been uploaded to the Azure storage of the
+// mobileAppContentFile with the given ID, false if no block list has been committed yet.
+func (s *Server) Blob(contentFileID string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.blobs[contentFileID]
+	if b == nil || !b.committed {
+		return nil, false
+	}
+	return append([]byte(nil), b.content...), true
+}
+
+// serveBlob serves the Put Block and Put Block List operations of the Azure storage, see
+// https://docs.microsoft.com/en-us/rest/api/storageservices/put-block-list
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request, body []byte) {
+	id := blobPath.FindStringSubmatch(r.URL.Path)[1]
+	query := r.URL.Query()
+	if query.Get("sig") == "" {
+		writeStorageError(w, http.StatusForbidden, "AuthenticationFailed", "Server failed to authenticate the request.")
+		return
+	}
+	if r.Method != http.MethodPut {
+		writeStorageError(w, http.StatusMethodNotAllowed, "UnsupportedHttpVerb", "The resource doesn't support specified Http Verb.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.blobs[id]
+	if b == nil {
+		writeStorageError(w, http.StatusNotFound, "ResourceNotFound", "The specified resource does not exist.")
+		return
+	}
+	switch query.Get("comp") {
+	case "block":
+		if query.Get("blockid") == "" {
+			writeStorageError(w, http.StatusBadRequest, "InvalidQueryParameterValue", "Value for one of the query parameters specified in the request URI is invalid.")
+			return
+		}
+		b.blocks[query.Get("blockid")] = body
+	case "blocklist":
+		var blockList struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.Unmarshal(body, &blockList); err != nil {
+			writeStorageError(w, http.StatusBadRequest, "InvalidXmlDocument", "XML specified is not syntactically valid.")
+			return
+		}
+		var content []byte
+		for _, blockID := range blockList.Latest {
+			block, ok := b.blocks[blockID]
+			if !ok {
+				writeStorageError(w, http.StatusBadRequest, "InvalidBlockList", "The specified block list is invalid.")
+				return
+			}
+			content = append(content, block...)
+		}
+		b.content, b.committed, b.blocks = content, true, map[string][]byte{}
+	default:
+		writeStorageError(w, http.StatusBadRequest, "InvalidQueryParameterValue", "Value for one of the query parameters specified in the request URI is invalid.")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// writeStorageError writes an error of the Azure storage
+func writeStorageError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("x-ms-error-code", code)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%v</Code><Message>%v</Message></Error>`, code, message)
+}
@@ -0,0 +1,96 @@
+package msgraphtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenLifetime is the lifetime of the access tokens issued by the Server
+const tokenLifetime = time.Hour
+
+// serveToken serves the v1.0 and v2.0 token endpoint of the Azure AD authentication endpoint with
+// the client credentials flow. Client assertions are accepted without verifying their signature.
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	m := tokenPath.FindStringSubmatch(r.URL.Path)
+	tenantID, v2 := m[1], m[2] != ""
+	if r.Method != http.MethodPost {
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "AADSTS900561: The endpoint only accepts POST requests.")
+		return
+	}
+	r.ParseForm()
+
+	switch {
+	case tenantID != s.TenantID:
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("AADSTS90002: Tenant '%v' not found.", tenantID))
+	case r.PostForm.Get("grant_type") != "client_credentials":
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("AADSTS70003: The app requested an unsupported grant type '%v'.", r.PostForm.Get("grant_type")))
+	case r.PostForm.Get("client_id") != s.ApplicationID:
+		writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", fmt.Sprintf("AADSTS700016: Application with identifier '%v' was not found in the directory '%v'.", r.PostForm.Get("client_id"), tenantID))
+	case r.PostForm.Get("client_assertion") == "" && r.PostForm.Get("client_secret") != s.ClientSecret:
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", fmt.Sprintf("AADSTS7000215: Invalid client secret provided. Ensure the secret being sent in the request is the client secret value, not the client secret ID, for a secret added to app '%v'.", s.ApplicationID))
+	case !v2 && strings.TrimSuffix(r.PostForm.Get("resource"), "/") != s.URL:
+		writeOAuthError(w, http.StatusBadRequest, "invalid_resource", fmt.Sprintf("AADSTS500011: The resource principal named %v was not found in the tenant named %v.", r.PostForm.Get("resource"), tenantID))
+	case v2 && !s.validScopes(r.PostForm.Get("scope")):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", fmt.Sprintf("AADSTS70011: The provided value for the input parameter 'scope' is not valid. The scope %v is not valid.", r.PostForm.Get("scope")))
+	default:
+		s.issueToken(w, r, v2)
+	}
+}
+
+// validScopes returns true if all scopes belong to the resource of the Server, e.g. "{URL}/.default"
+func (s *Server) validScopes(scopes string) bool {
+	for _, scope := range strings.Fields(scopes) {
+		if !strings.HasPrefix(scope, s.URL+"/") {
+			return false
+		}
+	}
+	return scopes != ""
+}
+
+// issueToken writes a new access token in the format of the v1.0 or v2.0 token endpoint
+func (s *Server) issueToken(w http.ResponseWriter, r *http.Request, v2 bool) {
+	accessToken := newGUID()
+	now := time.Now()
+	s.mu.Lock()
+	s.tokens[accessToken] = now.Add(tokenLifetime)
+	s.mu.Unlock()
+
+	resp := map[string]string{
+		"token_type":   "Bearer",
+		"access_token": accessToken,
+		"expires_in":   strconv.Itoa(int(tokenLifetime.Seconds())),
+	}
+	if !v2 {
+		resp["expires_on"] = strconv.FormatInt(now.Add(tokenLifetime).Unix(), 10)
+		resp["not_before"] = strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)
+		resp["resource"] = r.PostForm.Get("resource")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ExpireTokens expires all access tokens issued so far, hence the next request of a client is
+// rejected with 401 - Unauthorized until it acquires a new token.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.tokens {
+		s.tokens[token] = time.Now()
+	}
+}
+
+// writeOAuthError writes an OAuth error of the Azure AD authentication endpoint
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error":             code,
+		"error_description": description,
+		"error_codes":       []int{},
+		"timestamp":         time.Now().UTC().Format("2006-01-02 15:04:05Z"),
+		"trace_id":          w.Header().Get("request-id"),
+		"correlation_id":    newGUID(),
+	})
+}